@@ -0,0 +1,100 @@
+package connection
+
+import (
+	"fmt"
+
+	"github.com/lib/pq"
+
+	"github.com/noctusha/tender/models"
+)
+
+// AmendTender stores the previous terms of a published tender as a version, applies the
+// updated terms and records the amendment. Pending bids on the tender are flagged for
+// reconfirmation in the same transaction.
+func (r *Repository) AmendTender(previous models.Tender, updated *models.Tender, amendment *models.TenderAmendment, events ...models.Event) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRow(`INSERT INTO tender_version (tender_id, name, description, deadline, attachment_ids)
+		VALUES ($1, $2, $3, $4, `+attachmentSnapshot(attachmentEntityTender)+`) RETURNING id`,
+		previous.ID, previous.Name, previous.Description, previous.Deadline).Scan(&amendment.TenderVersionID)
+	if err != nil {
+		return fmt.Errorf("failed to insert data into tender_version: %w", err)
+	}
+
+	_, err = tx.Exec(`UPDATE tender SET name = $1, description = $2, deadline = $3, updated_at = CURRENT_TIMESTAMP WHERE id = $4`,
+		updated.Name, updated.Description, updated.Deadline, updated.ID)
+	if err != nil {
+		return fmt.Errorf("failed to update tender: %w", err)
+	}
+
+	res, err := tx.Exec(`UPDATE bid SET needs_reconfirmation = TRUE, updated_at = CURRENT_TIMESTAMP WHERE tender_id = $1 AND status = $2`,
+		updated.ID, bidStatusCreated)
+	if err != nil {
+		return fmt.Errorf("failed to flag bids for reconfirmation: %w", err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to flag bids for reconfirmation: %w", err)
+	}
+	amendment.AffectedBids = int(affected)
+
+	err = tx.QueryRow(`INSERT INTO tender_amendment (id, tender_id, tender_version_id, reason, changed_fields, affected_bids, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING created_at`,
+		amendment.ID, amendment.TenderID, amendment.TenderVersionID, amendment.Reason, pq.Array(amendment.ChangedFields),
+		amendment.AffectedBids, amendment.CreatedBy).Scan(&amendment.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to insert data into tender_amendment: %w", err)
+	}
+
+	err = insertEvents(tx, events)
+	if err != nil {
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+func (r *Repository) AmendmentsByTenderID(tenderID string) ([]models.TenderAmendment, error) {
+	amendments := []models.TenderAmendment{}
+
+	rows, err := r.db.Query(`SELECT id, tender_id, tender_version_id, COALESCE(reason, ''), changed_fields, affected_bids, created_by, created_at
+		FROM tender_amendment WHERE tender_id = $1 ORDER BY created_at`, tenderID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to select data from tender_amendment: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		amendment := models.TenderAmendment{}
+		err := rows.Scan(&amendment.ID, &amendment.TenderID, &amendment.TenderVersionID, &amendment.Reason,
+			pq.Array(&amendment.ChangedFields), &amendment.AffectedBids, &amendment.CreatedBy, &amendment.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan: %w", err)
+		}
+		amendments = append(amendments, amendment)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, fmt.Errorf("rows iteration error: %w", err)
+	}
+
+	return amendments, nil
+}
+
+func (r *Repository) ReconfirmBid(bidID string) error {
+	_, err := r.db.Exec(`UPDATE bid SET needs_reconfirmation = FALSE, updated_at = CURRENT_TIMESTAMP WHERE id = $1`, bidID)
+	if err != nil {
+		return fmt.Errorf("failed to reconfirm bid: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+package connection
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+
+	"github.com/noctusha/tender/models"
+)
+
+const (
+	attachmentEntityTender = "tender"
+	attachmentEntityBid    = "bid"
+)
+
+// attachmentSnapshot is an SQL expression listing the attachments currently linked to
+// the entity passed as $1, stored with a version so a rollback can restore them.
+func attachmentSnapshot(entityType string) string {
+	return `ARRAY(SELECT id FROM attachment WHERE entity_type = '` + entityType + `' AND entity_id = $1 AND NOT detached ORDER BY created_at)`
+}
+
+func (r *Repository) NewAttachment(attachment *models.Attachment) error {
+	err := r.db.QueryRow(`INSERT INTO attachment (id, entity_type, entity_id, file_name, content_type, size, sha256, storage_key, uploaded_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9) RETURNING created_at`,
+		attachment.ID, attachment.EntityType, attachment.EntityID, attachment.FileName, attachment.ContentType,
+		attachment.Size, attachment.SHA256, attachment.StorageKey, attachment.UploadedBy).Scan(&attachment.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to insert data into attachment: %w", err)
+	}
+	return nil
+}
+
+func (r *Repository) AttachmentsByEntity(entityType string, entityID string) ([]models.Attachment, error) {
+	attachments := []models.Attachment{}
+
+	rows, err := r.db.Query(`SELECT id, entity_type, entity_id, file_name, content_type, size, sha256, storage_key, uploaded_by, created_at
+		FROM attachment WHERE entity_type = $1 AND entity_id = $2 AND NOT detached ORDER BY created_at`, entityType, entityID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to select data from attachment: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		attachment := models.Attachment{}
+		err := rows.Scan(&attachment.ID, &attachment.EntityType, &attachment.EntityID, &attachment.FileName, &attachment.ContentType,
+			&attachment.Size, &attachment.SHA256, &attachment.StorageKey, &attachment.UploadedBy, &attachment.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan: %w", err)
+		}
+		attachments = append(attachments, attachment)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, fmt.Errorf("rows iteration error: %w", err)
+	}
+
+	return attachments, nil
+}
+
+// GetAttachmentByID also finds detached attachments, so files referenced by older versions stay downloadable.
+func (r *Repository) GetAttachmentByID(attachmentID uuid.UUID) (*models.Attachment, bool, error) {
+	var attachment models.Attachment
+	err := r.db.QueryRow(`SELECT id, entity_type, entity_id, file_name, content_type, size, sha256, storage_key, uploaded_by, created_at
+		FROM attachment WHERE id = $1`, attachmentID.String()).
+		Scan(&attachment.ID, &attachment.EntityType, &attachment.EntityID, &attachment.FileName, &attachment.ContentType,
+			&attachment.Size, &attachment.SHA256, &attachment.StorageKey, &attachment.UploadedBy, &attachment.CreatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, false, nil
+		}
+		return nil, false, fmt.Errorf("failed to select data from attachment: %w", err)
+	}
+	return &attachment, true, nil
+}
+
+// DetachAttachment unlinks an attachment from its entity. The row and the blob are kept for older versions.
+func (r *Repository) DetachAttachment(attachmentID string) error {
+	_, err := r.db.Exec(`UPDATE attachment SET detached = TRUE WHERE id = $1`, attachmentID)
+	if err != nil {
+		return fmt.Errorf("failed to detach attachment: %w", err)
+	}
+	return nil
+}
+
+// RestoreAttachments links exactly the given attachments to the entity, as recorded in a version snapshot.
+func (r *Repository) RestoreAttachments(entityType string, entityID string, attachmentIDs []string) error {
+	_, err := r.db.Exec(`UPDATE attachment SET detached = NOT (id::text = ANY($3)) WHERE entity_type = $1 AND entity_id = $2`,
+		entityType, entityID, pq.Array(attachmentIDs))
+	if err != nil {
+		return fmt.Errorf("failed to restore attachments: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,229 @@
+package connection
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+
+	"github.com/noctusha/tender/models"
+)
+
+const auditEventColumns = `audit_event.id, audit_event.actor, audit_event.organization_ids, audit_event.action,
+		audit_event.entity_type, audit_event.entity_id, audit_event.before, audit_event.after,
+		COALESCE(audit_event.request_id, ''), audit_event.created_at,
+		COALESCE(audit_event.prev_hash, ''), COALESCE(audit_event.hash, '')`
+
+// AuditFilter narrows the audit log. An empty OrganizationID means every organization.
+type AuditFilter struct {
+	EntityType     string
+	EntityID       string
+	Actor          string
+	OrganizationID string
+	From           *time.Time
+	To             *time.Time
+	Page           Page
+}
+
+// NewAuditEvent appends an event to the audit log, chained to the previous record by its hash.
+// Empty Before or After are stored as NULL.
+//
+// The chain has a single head, so the append locks it until the end of the transaction. Inside
+// the transaction of a change this serializes all mutating requests from the append to their
+// commit; callers append the record last so that the lock is held as briefly as possible.
+func (r *Repository) NewAuditEvent(event models.AuditEvent) error {
+	entityID, err := uuid.Parse(event.EntityID)
+	if err != nil {
+		return fmt.Errorf("invalid entity id of audit event: %w", err)
+	}
+	event.EntityID = entityID.String()
+	event.ID = uuid.New().String()
+
+	var before, after interface{}
+	if len(event.Before) > 0 {
+		before = string(event.Before)
+	}
+	if len(event.After) > 0 {
+		after = string(event.After)
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	err = lockChain(tx, "audit_event")
+	if err != nil {
+		return err
+	}
+
+	event.PrevHash = genesisHash
+	err = tx.QueryRow(`SELECT hash FROM audit_event WHERE hash IS NOT NULL ORDER BY sequence DESC LIMIT 1`).Scan(&event.PrevHash)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("failed to select data from audit_event: %w", err)
+	}
+
+	createdAt := chainNow()
+	event.Hash, err = auditEventHash(event, createdAt)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`INSERT INTO audit_event (id, actor, organization_ids, action, entity_type, entity_id, before, after, request_id,
+			created_at, prev_hash, hash)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, NULLIF($9, ''), $10, $11, $12)`,
+		event.ID, event.Actor, pq.Array(event.OrganizationIDs), event.Action, event.EntityType, event.EntityID, before, after,
+		event.RequestID, createdAt, event.PrevHash, event.Hash)
+	if err != nil {
+		return fmt.Errorf("failed to insert data into audit_event: %w", err)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+func auditEventHash(event models.AuditEvent, createdAt time.Time) (string, error) {
+	before, err := canonicalJSON(event.Before)
+	if err != nil {
+		return "", fmt.Errorf("failed to hash audit event: %w", err)
+	}
+	after, err := canonicalJSON(event.After)
+	if err != nil {
+		return "", fmt.Errorf("failed to hash audit event: %w", err)
+	}
+
+	return chainHash(event.PrevHash, event.ID, event.Actor, strings.Join(event.OrganizationIDs, ","), event.Action,
+		event.EntityType, event.EntityID, before, after, event.RequestID, chainTime(createdAt)), nil
+}
+
+// VerifyAuditChain walks the audit log and reports the first record whose hash does not match its
+// content or the previous record. Records written before the log was chained are skipped.
+func (r *Repository) VerifyAuditChain() (models.ChainVerification, error) {
+	verification := models.ChainVerification{Valid: true}
+
+	rows, err := r.db.Query(`SELECT audit_event.sequence, ` + auditEventColumns + ` FROM audit_event ORDER BY audit_event.sequence`)
+	if err != nil {
+		return verification, fmt.Errorf("failed to select data from audit_event: %w", err)
+	}
+	defer rows.Close()
+
+	prevHash := ""
+	for rows.Next() {
+		var (
+			sequence      int64
+			event         models.AuditEvent
+			before, after []byte
+			createdAt     time.Time
+		)
+		err := rows.Scan(&sequence, &event.ID, &event.Actor, pq.Array(&event.OrganizationIDs), &event.Action, &event.EntityType,
+			&event.EntityID, &before, &after, &event.RequestID, &createdAt, &event.PrevHash, &event.Hash)
+		if err != nil {
+			return verification, fmt.Errorf("failed to scan data from audit_event: %w", err)
+		}
+		event.Before = before
+		event.After = after
+
+		if event.Hash == "" {
+			if prevHash == "" {
+				continue
+			}
+			breakChain(&verification, sequence, event.ID, "record is not chained")
+			return verification, nil
+		}
+		if prevHash == "" {
+			prevHash = genesisHash
+		}
+
+		hash, err := auditEventHash(event, createdAt)
+		if err != nil {
+			return verification, err
+		}
+
+		switch {
+		case event.PrevHash != prevHash:
+			breakChain(&verification, sequence, event.ID, "previous hash does not match the preceding record")
+			return verification, nil
+		case hash != event.Hash:
+			breakChain(&verification, sequence, event.ID, "hash does not match the content of the record")
+			return verification, nil
+		}
+
+		prevHash = event.Hash
+		verification.Checked++
+		verification.LastHash = event.Hash
+	}
+	if err := rows.Err(); err != nil {
+		return verification, fmt.Errorf("failed to select data from audit_event: %w", err)
+	}
+
+	return verification, nil
+}
+
+// AuditEvents is the audit log, newest first.
+func (r *Repository) AuditEvents(filter AuditFilter) ([]models.AuditEvent, PageInfo, error) {
+	events := []models.AuditEvent{}
+
+	q := newQuery(auditEventColumns, "audit_event")
+	if filter.EntityType != "" {
+		q.Where("audit_event.entity_type = ?", filter.EntityType)
+	}
+	if filter.EntityID != "" {
+		q.Where("audit_event.entity_id::text = ?", filter.EntityID)
+	}
+	if filter.Actor != "" {
+		q.Where("audit_event.actor = ?", filter.Actor)
+	}
+	if filter.OrganizationID != "" {
+		q.Where("?::uuid = ANY(audit_event.organization_ids)", filter.OrganizationID)
+	}
+	q.WhereTimeRange("audit_event.created_at", filter.From, filter.To)
+
+	keys := []sortKey{{expr: "audit_event.created_at", desc: true}, {expr: "audit_event.id"}}
+	info, err := r.paginate(q, keys, "-createdAt", filter.Page, func(rows *tracedRows, keyDest ...interface{}) error {
+		var (
+			event         models.AuditEvent
+			before, after []byte
+		)
+		err := rows.Scan(append([]interface{}{&event.ID, &event.Actor, pq.Array(&event.OrganizationIDs), &event.Action,
+			&event.EntityType, &event.EntityID, &before, &after, &event.RequestID, &event.CreatedAt,
+			&event.PrevHash, &event.Hash}, keyDest...)...)
+		if err != nil {
+			return err
+		}
+		event.Before = before
+		event.After = after
+		events = append(events, event)
+		return nil
+	})
+	if errors.Is(err, ErrInvalidCursor) {
+		return nil, info, err
+	}
+	if err != nil {
+		return nil, info, fmt.Errorf("failed to select data from audit_event: %w", err)
+	}
+
+	return events, info, nil
+}
+
+// OrganizationAdminOf returns the organization the user administers, if any.
+func (r *Repository) OrganizationAdminOf(username string) (string, bool, error) {
+	var organizationId string
+	err := r.db.QueryRow(`SELECT organization_responsible.organization_id FROM employee
+		JOIN organization_responsible ON employee.id = organization_responsible.user_id
+		WHERE employee.username = $1 AND organization_responsible.is_admin`, username).Scan(&organizationId)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", false, nil
+		}
+		return "", false, fmt.Errorf("failed to check organization admin rights: %w", err)
+	}
+	return organizationId, true, nil
+}
@@ -0,0 +1,302 @@
+package connection
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/noctusha/tender/models"
+)
+
+const (
+	SealActionSubmit     = "submit"
+	SealActionEdit       = "edit"
+	SealActionRollback   = "rollback"
+	SealActionAttachment = "attachment"
+)
+
+// genesisHash is the previous hash of the first link of a chain.
+var genesisHash = strings.Repeat("0", sha256.Size*2)
+
+type queryer interface {
+	QueryRow(query string, args ...interface{}) *tracedRow
+	Query(query string, args ...interface{}) (*tracedRows, error)
+}
+
+// chainHash links an entry to the previous one: the SHA-256 of the previous hash and the fields
+// of the entry, encoded as a JSON array so that no two different entries share an encoding.
+func chainHash(prevHash string, fields ...string) string {
+	data, _ := json.Marshal(append([]string{prevHash}, fields...))
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// canonicalJSON re-encodes a JSON document with sorted keys and without insignificant whitespace,
+// so the document hashes the same after a round trip through a JSONB column.
+func canonicalJSON(data []byte) (string, error) {
+	if len(data) == 0 {
+		return "", nil
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var v interface{}
+	err := decoder.Decode(&v)
+	if err != nil {
+		return "", fmt.Errorf("failed to decode JSON: %w", err)
+	}
+
+	canonical, err := json.Marshal(v)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode JSON: %w", err)
+	}
+	return string(canonical), nil
+}
+
+// chainTime is the time of a link as hashed. Links are timestamped by the application, with the
+// microsecond precision of the database.
+func chainTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+func chainNow() time.Time {
+	return time.Now().UTC().Truncate(time.Microsecond)
+}
+
+// lockChain serializes the appends to a chain until the end of the transaction. Transactions
+// appending to the same chain wait for each other's commit, so writes to one chain do not scale.
+func lockChain(tx *transaction, chain string) error {
+	_, err := tx.Exec(`SELECT pg_advisory_xact_lock(hashtext($1))`, chain)
+	if err != nil {
+		return fmt.Errorf("failed to lock %s chain: %w", chain, err)
+	}
+	return nil
+}
+
+type sealedBid struct {
+	ID          string             `json:"id"`
+	TenderID    string             `json:"tenderId"`
+	LotID       string             `json:"lotId,omitempty"`
+	AuthorType  string             `json:"authorType"`
+	AuthorID    string             `json:"authorId"`
+	Name        string             `json:"name"`
+	Description string             `json:"description"`
+	Attachments []sealedAttachment `json:"attachments"`
+
+	Signature *models.BidSignature `json:"signature,omitempty"`
+}
+
+type sealedAttachment struct {
+	ID     string `json:"id"`
+	SHA256 string `json:"sha256"`
+}
+
+// sealedBidContent is the version of a bid as sealed: its terms and the checksums of its attachments.
+func sealedBidContent(q queryer, bidID string) (string, error) {
+	var (
+		bid                             = sealedBid{Attachments: []sealedAttachment{}}
+		signature, signedBy, signingKey sql.NullString
+		signedVersion                   sql.NullInt64
+	)
+	err := q.QueryRow(`SELECT id, tender_id, COALESCE(lot_id::text, ''), author_type, author_id, name, COALESCE(description, ''),
+			signature, signed_by, signing_key, signed_version
+		FROM bid WHERE id = $1`, bidID).Scan(&bid.ID, &bid.TenderID, &bid.LotID, &bid.AuthorType, &bid.AuthorID, &bid.Name, &bid.Description,
+		&signature, &signedBy, &signingKey, &signedVersion)
+	if err != nil {
+		return "", fmt.Errorf("failed to select data from bid: %w", err)
+	}
+	bid.Signature = scanBidSignature(signature, signedBy, signingKey, signedVersion)
+
+	rows, err := q.Query(`SELECT id, sha256 FROM attachment
+		WHERE entity_type = $1 AND entity_id = $2 AND NOT detached ORDER BY created_at, id`, attachmentEntityBid, bidID)
+	if err != nil {
+		return "", fmt.Errorf("failed to select data from attachment: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var attachment sealedAttachment
+		err := rows.Scan(&attachment.ID, &attachment.SHA256)
+		if err != nil {
+			return "", fmt.Errorf("failed to scan data from attachment: %w", err)
+		}
+		bid.Attachments = append(bid.Attachments, attachment)
+	}
+	if err := rows.Err(); err != nil {
+		return "", fmt.Errorf("failed to select data from attachment: %w", err)
+	}
+
+	content, err := json.Marshal(bid)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode sealed bid: %w", err)
+	}
+	return string(content), nil
+}
+
+func bidSealHash(seal models.BidSeal, createdAt time.Time) string {
+	return chainHash(seal.PrevHash, seal.ID, seal.TenderID, seal.BidID, seal.Action, seal.Content, chainTime(createdAt))
+}
+
+// sealBid appends the current version of a bid to the chain of its tender.
+func sealBid(tx *transaction, bidID string, action string) (*models.BidSeal, error) {
+	seal := models.BidSeal{
+		ID:     uuid.New().String(),
+		BidID:  bidID,
+		Action: action,
+	}
+
+	err := tx.QueryRow(`SELECT tender_id FROM bid WHERE id = $1`, bidID).Scan(&seal.TenderID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to select data from bid: %w", err)
+	}
+
+	err = lockChain(tx, "bid_seal:"+seal.TenderID)
+	if err != nil {
+		return nil, err
+	}
+
+	seal.Content, err = sealedBidContent(tx, bidID)
+	if err != nil {
+		return nil, err
+	}
+
+	seal.PrevHash = genesisHash
+	err = tx.QueryRow(`SELECT hash FROM bid_seal WHERE tender_id = $1 ORDER BY sequence DESC LIMIT 1`, seal.TenderID).Scan(&seal.PrevHash)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("failed to select data from bid_seal: %w", err)
+	}
+
+	createdAt := chainNow()
+	seal.Hash = bidSealHash(seal, createdAt)
+	seal.CreatedAt = chainTime(createdAt)
+
+	err = tx.QueryRow(`INSERT INTO bid_seal (id, tender_id, bid_id, action, content, prev_hash, hash, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING sequence`,
+		seal.ID, seal.TenderID, seal.BidID, seal.Action, seal.Content, seal.PrevHash, seal.Hash, createdAt).Scan(&seal.Sequence)
+	if err != nil {
+		return nil, fmt.Errorf("failed to insert data into bid_seal: %w", err)
+	}
+	return &seal, nil
+}
+
+// SealBid appends the current version of a bid, as saved, to the chain of its tender.
+func (r *Repository) SealBid(bidID string, action string) (*models.BidSeal, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	seal, err := sealBid(tx, bidID, action)
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return seal, nil
+}
+
+// LastBidSeal returns the latest sealed version of a bid.
+func (r *Repository) LastBidSeal(bidID string) (*models.BidSeal, bool, error) {
+	var (
+		seal      models.BidSeal
+		createdAt time.Time
+	)
+	err := r.db.QueryRow(`SELECT id, sequence, tender_id, bid_id, action, content, prev_hash, hash, created_at
+		FROM bid_seal WHERE bid_id = $1 ORDER BY sequence DESC LIMIT 1`, bidID).Scan(&seal.ID, &seal.Sequence, &seal.TenderID,
+		&seal.BidID, &seal.Action, &seal.Content, &seal.PrevHash, &seal.Hash, &createdAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, false, nil
+		}
+		return nil, false, fmt.Errorf("failed to select data from bid_seal: %w", err)
+	}
+	seal.CreatedAt = chainTime(createdAt)
+	return &seal, true, nil
+}
+
+// VerifyBidChain walks the chain of the bids of a tender and reports the first link whose hash does
+// not match its content or the previous link, or whose bid no longer matches its last sealed version.
+func (r *Repository) VerifyBidChain(tenderID string) (models.ChainVerification, error) {
+	verification := models.ChainVerification{Valid: true}
+
+	rows, err := r.db.Query(`SELECT id, sequence, tender_id, bid_id, action, content, prev_hash, hash, created_at
+		FROM bid_seal WHERE tender_id = $1 ORDER BY sequence`, tenderID)
+	if err != nil {
+		return verification, fmt.Errorf("failed to select data from bid_seal: %w", err)
+	}
+	defer rows.Close()
+
+	var (
+		prevHash = genesisHash
+		latest   = map[string]models.BidSeal{}
+		bidIDs   []string
+	)
+	for rows.Next() {
+		var (
+			seal      models.BidSeal
+			createdAt time.Time
+		)
+		err := rows.Scan(&seal.ID, &seal.Sequence, &seal.TenderID, &seal.BidID, &seal.Action, &seal.Content,
+			&seal.PrevHash, &seal.Hash, &createdAt)
+		if err != nil {
+			return verification, fmt.Errorf("failed to scan data from bid_seal: %w", err)
+		}
+
+		switch {
+		case seal.PrevHash != prevHash:
+			breakChain(&verification, seal.Sequence, seal.ID, "previous hash does not match the preceding link")
+		case bidSealHash(seal, createdAt) != seal.Hash:
+			breakChain(&verification, seal.Sequence, seal.ID, "hash does not match the content of the link")
+		}
+		if !verification.Valid {
+			return verification, nil
+		}
+
+		prevHash = seal.Hash
+		verification.Checked++
+		verification.LastHash = seal.Hash
+		if _, ok := latest[seal.BidID]; !ok {
+			bidIDs = append(bidIDs, seal.BidID)
+		}
+		latest[seal.BidID] = seal
+	}
+	if err := rows.Err(); err != nil {
+		return verification, fmt.Errorf("failed to select data from bid_seal: %w", err)
+	}
+
+	for _, bidID := range bidIDs {
+		seal := latest[bidID]
+		content, err := sealedBidContent(r.db, bidID)
+		if errors.Is(err, sql.ErrNoRows) {
+			breakChain(&verification, seal.Sequence, seal.ID, fmt.Sprintf("bid %s no longer exists", bidID))
+			return verification, nil
+		}
+		if err != nil {
+			return verification, err
+		}
+
+		if content != seal.Content {
+			breakChain(&verification, seal.Sequence, seal.ID, fmt.Sprintf("bid %s differs from its last sealed version", bidID))
+			return verification, nil
+		}
+	}
+
+	return verification, nil
+}
+
+func breakChain(verification *models.ChainVerification, sequence int64, id string, reason string) {
+	verification.Valid = false
+	verification.BrokenLink = &models.BrokenLink{Sequence: sequence, ID: id, Reason: reason}
+}
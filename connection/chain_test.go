@@ -0,0 +1,183 @@
+package connection
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/noctusha/tender/models"
+)
+
+func TestChainHash(t *testing.T) {
+	want := "2be40c579c296a0a10cf9e8a79cbeba95c781dc4851cef10f98a10c0e49c3d3a"
+	if got := chainHash(genesisHash, "tender", "edit"); got != want {
+		t.Errorf("chainHash = %s, want %s", got, want)
+	}
+
+	tests := []struct {
+		name     string
+		prevHash string
+		fields   []string
+	}{
+		{name: "other previous hash", prevHash: want, fields: []string{"tender", "edit"}},
+		{name: "fields moved across a boundary", prevHash: genesisHash, fields: []string{"tende", "redit"}},
+		{name: "fields joined", prevHash: genesisHash, fields: []string{"tender,edit"}},
+		{name: "empty field added", prevHash: genesisHash, fields: []string{"tender", "edit", ""}},
+		{name: "fields swapped", prevHash: genesisHash, fields: []string{"edit", "tender"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := chainHash(tt.prevHash, tt.fields...); got == want {
+				t.Errorf("chainHash(%q, %q) = %s, the hash of another entry", tt.prevHash, tt.fields, got)
+			}
+		})
+	}
+}
+
+func TestCanonicalJSON(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{name: "empty", in: "", want: ""},
+		{name: "null", in: "null", want: "null"},
+		{name: "sorted keys", in: `{"name":"Поставка","id":"1"}`, want: `{"id":"1","name":"Поставка"}`},
+		{name: "whitespace", in: "{ \"a\" : [1, 2,\n 3] }", want: `{"a":[1,2,3]}`},
+		{name: "nested objects", in: `{"b":{"d":1,"c":2},"a":[{"z":0,"y":0}]}`, want: `{"a":[{"y":0,"z":0}],"b":{"c":2,"d":1}}`},
+		{name: "large numbers kept", in: `{"n":12345678901234567890,"f":1.50}`, want: `{"f":1.50,"n":12345678901234567890}`},
+		{name: "invalid", in: `{"a":`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := canonicalJSON([]byte(tt.in))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("canonicalJSON(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("canonicalJSON(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAuditEventHash(t *testing.T) {
+	createdAt := time.Date(2024, 3, 1, 12, 30, 0, 123456000, time.UTC)
+	event := models.AuditEvent{
+		ID:              "0b8f2d6e-3c1a-4f5e-9a7b-2d4c6e8f0a1b",
+		Actor:           "user1",
+		OrganizationIDs: []string{"550e8400-e29b-41d4-a716-446655440000"},
+		Action:          "tender.edit",
+		EntityType:      "tender",
+		EntityID:        "6ba7b810-9dad-11d1-80b4-00c04fd430c8",
+		Before:          json.RawMessage(`{"name":"Поставка","version":1}`),
+		After:           json.RawMessage(`{"name":"Поставка бумаги","version":2}`),
+		RequestID:       "req-1",
+		PrevHash:        genesisHash,
+	}
+	want, err := auditEventHash(event, createdAt)
+	if err != nil {
+		t.Fatalf("auditEventHash: %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		event     func(event *models.AuditEvent)
+		createdAt time.Time
+		same      bool
+		wantErr   bool
+	}{
+		{name: "same event", same: true},
+		{name: "reformatted JSONB", event: func(event *models.AuditEvent) {
+			event.Before = json.RawMessage(`{"version": 1, "name": "Поставка"}`)
+		}, same: true},
+		{name: "same time in another zone", createdAt: createdAt.In(time.FixedZone("MSK", 3*60*60)), same: true},
+		{name: "other time", createdAt: createdAt.Add(time.Microsecond)},
+		{name: "other previous hash", event: func(event *models.AuditEvent) { event.PrevHash = want }},
+		{name: "other actor", event: func(event *models.AuditEvent) { event.Actor = "user2" }},
+		{name: "other organizations", event: func(event *models.AuditEvent) { event.OrganizationIDs = nil }},
+		{name: "other action", event: func(event *models.AuditEvent) { event.Action = "tender.rollback" }},
+		{name: "changed after", event: func(event *models.AuditEvent) {
+			event.After = json.RawMessage(`{"name":"Поставка бумаги","version":3}`)
+		}},
+		{name: "other request", event: func(event *models.AuditEvent) { event.RequestID = "" }},
+		{name: "invalid before", event: func(event *models.AuditEvent) { event.Before = json.RawMessage(`{`) }, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, at := event, createdAt
+			if tt.event != nil {
+				tt.event(&e)
+			}
+			if !tt.createdAt.IsZero() {
+				at = tt.createdAt
+			}
+
+			got, err := auditEventHash(e, at)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("auditEventHash error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if (got == want) != tt.same {
+				t.Errorf("auditEventHash = %s, same as the original = %v, want %v", got, got == want, tt.same)
+			}
+		})
+	}
+}
+
+func TestBidSealHash(t *testing.T) {
+	createdAt := time.Date(2024, 3, 1, 12, 30, 0, 123456000, time.UTC)
+	seal := models.BidSeal{
+		ID:       "0b8f2d6e-3c1a-4f5e-9a7b-2d4c6e8f0a1b",
+		TenderID: "550e8400-e29b-41d4-a716-446655440000",
+		BidID:    "3f2b8c1e-9d4a-4c7b-8e21-6a5f0d9b7c13",
+		Action:   SealActionEdit,
+		Content:  `{"id":"3f2b8c1e-9d4a-4c7b-8e21-6a5f0d9b7c13","name":"Поставка труб","attachments":[]}`,
+		PrevHash: genesisHash,
+	}
+	want := chainHash(genesisHash, seal.ID, seal.TenderID, seal.BidID, seal.Action, seal.Content, "2024-03-01T12:30:00.123456Z")
+	if got := bidSealHash(seal, createdAt); got != want {
+		t.Fatalf("bidSealHash = %s, want %s", got, want)
+	}
+
+	tests := []struct {
+		name      string
+		seal      func(seal *models.BidSeal)
+		createdAt time.Time
+		same      bool
+	}{
+		{name: "sequence and stored hash are not hashed", seal: func(seal *models.BidSeal) {
+			seal.Sequence = 7
+			seal.Hash = want
+			seal.CreatedAt = "2024-03-01T15:30:00+03:00"
+		}, same: true},
+		{name: "same time in another zone", createdAt: createdAt.In(time.FixedZone("MSK", 3*60*60)), same: true},
+		{name: "other time", createdAt: createdAt.Add(time.Microsecond)},
+		{name: "other previous hash", seal: func(seal *models.BidSeal) { seal.PrevHash = want }},
+		{name: "other tender", seal: func(seal *models.BidSeal) { seal.TenderID = "6ba7b810-9dad-11d1-80b4-00c04fd430c8" }},
+		{name: "other bid", seal: func(seal *models.BidSeal) { seal.BidID = "7c9e6679-7425-40de-944b-e07fc1f90ae7" }},
+		{name: "other action", seal: func(seal *models.BidSeal) { seal.Action = SealActionRollback }},
+		{name: "changed content", seal: func(seal *models.BidSeal) {
+			seal.Content = `{"id":"3f2b8c1e-9d4a-4c7b-8e21-6a5f0d9b7c13","name":"Поставка труб ДУ-50","attachments":[]}`
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, at := seal, createdAt
+			if tt.seal != nil {
+				tt.seal(&s)
+			}
+			if !tt.createdAt.IsZero() {
+				at = tt.createdAt
+			}
+
+			got := bidSealHash(s, at)
+			if (got == want) != tt.same {
+				t.Errorf("bidSealHash = %s, same as the original = %v, want %v", got, got == want, tt.same)
+			}
+		})
+	}
+}
@@ -7,13 +7,13 @@ import (
 	"os"
 
 	"github.com/google/uuid"
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 
 	"github.com/noctusha/tender/models"
 )
 
 type Repository struct {
-	db *sql.DB
+	db database
 }
 
 func NewRepository() (*Repository, error) {
@@ -32,119 +32,143 @@ func NewRepository() (*Repository, error) {
 		return nil, fmt.Errorf("failed to ping a database: %w", err)
 	}
 
-	return &Repository{db: db}, nil
+	return &Repository{db: database{DB: db}}, nil
 }
 
 func (r *Repository) Close() {
 	r.db.Close()
 }
 
-func (r *Repository) TendersList(serviceType string, limit int, offset int) ([]models.Tender, error) {
-	tenders := []models.Tender{}
-	var rows *sql.Rows
-	var err error
+// TendersList returns published tenders visible to the organization: public ones, its own
+// and invite-only tenders it was invited to. An empty organizationID lists public tenders only.
+func (r *Repository) TendersList(organizationID string, filter TenderFilter) ([]models.Tender, PageInfo, error) {
+	q := newQuery(tenderColumns, "tender").
+		Where("tender.status = 'PUBLISHED'").
+		Where(tenderVisibleTo, organizationID, organizationID)
+	filter.apply(q)
 
-	if limit == 0 {
-		limit = 5
-	}
+	return r.queryTenders(q, filter)
+}
 
-	if serviceType == "" {
-		rows, err = r.db.Query("SELECT id, name, description, service_type, status, organization_id, creator_username FROM tender WHERE status='PUBLISHED' ORDER BY name LIMIT $1 OFFSET $2", limit, offset)
-	} else {
-		rows, err = r.db.Query("SELECT id, name, description, service_type, status, organization_id, creator_username FROM tender WHERE service_type = $1 AND status='PUBLISHED' ORDER BY name LIMIT $2 OFFSET $3", serviceType, limit, offset)
+func (r *Repository) NewTender(tender models.Tender) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
 	}
+	defer tx.Rollback()
 
+	_, err = tx.Exec(
+		`INSERT INTO tender (id, name, description, service_type, status, organization_id, creator_username, visibility, deadline)
+					VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`,
+		tender.ID, tender.Name, tender.Description, tender.ServiceType, tender.Status, tender.OrganizationID, tender.CreatorUserName, tender.Visibility, tender.Deadline)
 	if err != nil {
-		return nil, fmt.Errorf("failed to select data from tender: %w", err)
+		return fmt.Errorf("failed to insert data into tender: %w", err)
 	}
-	defer rows.Close()
 
-	for rows.Next() {
-		tender := models.Tender{}
-		err := rows.Scan(&tender.ID, &tender.Name, &tender.Description, &tender.ServiceType, &tender.Status, &tender.OrganizationID, &tender.CreatorUserName)
+	for _, lot := range tender.Lots {
+		err = insertLot(tx, lot)
 		if err != nil {
-			return nil, fmt.Errorf("failed to scan: %w", err)
+			return err
 		}
-		tenders = append(tenders, tender)
 	}
 
-	err = rows.Err()
+	err = tx.Commit()
 	if err != nil {
-		return nil, fmt.Errorf("rows iteration error: %w", err)
+		return fmt.Errorf("failed to commit transaction: %w", err)
 	}
-
-	return tenders, nil
+	return nil
 }
 
-func (r *Repository) NewTender(tender models.Tender) error {
-	_, err := r.db.Exec(
-		`INSERT INTO tender (id, name, description, service_type, status, organization_id, creator_username)
-					VALUES ($1, $2, $3, $4, $5, $6, $7)`,
-		tender.ID, tender.Name, tender.Description, tender.ServiceType, tender.Status, tender.OrganizationID, tender.CreatorUserName)
-	if err != nil {
-		return fmt.Errorf("failed to insert data into tender: %w", err)
+func (r *Repository) MyTendersList(username string, filter TenderFilter) ([]models.Tender, PageInfo, error) {
+	if username == "" {
+		return nil, PageInfo{}, fmt.Errorf("username is mandatory")
 	}
-	return nil
+
+	q := newQuery(tenderColumns, "tender").Where("tender.creator_username = ?", username)
+	filter.apply(q)
+
+	return r.queryTenders(q, filter)
 }
 
-func (r *Repository) MyTendersList(username string, limit int, offset int) ([]models.Tender, error) {
+func (r *Repository) queryTenders(q *queryBuilder, filter TenderFilter) ([]models.Tender, PageInfo, error) {
 	tenders := []models.Tender{}
-	var rows *sql.Rows
-	var err error
 
-	if limit == 0 {
-		limit = 5
+	sort := filter.sort()
+	info, err := r.paginate(q, sortKeys(sort, tenderSortColumns, "tender.id"), formatSort(sort), filter.Page,
+		func(rows *tracedRows, keyDest ...interface{}) error {
+			tender := models.Tender{}
+			err := rows.Scan(append([]interface{}{&tender.ID, &tender.Name, &tender.Description, &tender.ServiceType, &tender.Status,
+				&tender.OrganizationID, &tender.CreatorUserName, &tender.Visibility, &tender.Deadline}, keyDest...)...)
+			if err != nil {
+				return err
+			}
+			tenders = append(tenders, tender)
+			return nil
+		})
+	if errors.Is(err, ErrInvalidCursor) {
+		return nil, info, err
 	}
-
-	if username == "" {
-		return nil, fmt.Errorf("failed to find tenders by user: %w", err)
-	} else {
-		rows, err = r.db.Query("SELECT id, name, description, service_type, status, organization_id, creator_username FROM tender WHERE creator_username LIKE $1 LIMIT $2 OFFSET $3", username, limit, offset)
+	if err != nil {
+		return nil, info, fmt.Errorf("failed to select data from tender: %w", err)
 	}
 
+	return tenders, info, nil
+}
+
+func (r *Repository) UpdateTender(tender *models.Tender, events ...models.Event) error {
+	tx, err := r.db.Begin()
 	if err != nil {
-		return nil, fmt.Errorf("failed to select data from tender: %w", err)
+		return fmt.Errorf("failed to begin transaction: %w", err)
 	}
-	defer rows.Close()
+	defer tx.Rollback()
 
-	for rows.Next() {
-		tender := models.Tender{}
-		err := rows.Scan(&tender.ID, &tender.Name, &tender.Description, &tender.ServiceType, &tender.Status, &tender.OrganizationID, &tender.CreatorUserName)
-		if err != nil {
-			return nil, fmt.Errorf("failed to scan: %w", err)
-		}
-		tenders = append(tenders, tender)
+	_, err = tx.Exec(`UPDATE tender SET name = $1, description = $2, deadline = $3, updated_at = CURRENT_TIMESTAMP WHERE id = $4`,
+		tender.Name, tender.Description, tender.Deadline, tender.ID)
+	if err != nil {
+		return fmt.Errorf("failed to update tender: %w", err)
 	}
 
-	err = rows.Err()
+	err = insertEvents(tx, events)
 	if err != nil {
-		return nil, fmt.Errorf("rows iteration error: %w", err)
+		return err
 	}
 
-	return tenders, nil
-}
-
-func (r *Repository) UpdateTender(tender *models.Tender) error {
-	_, err := r.db.Exec(`UPDATE tender SET name = $1, description = $2, updated_at = CURRENT_TIMESTAMP WHERE id = $3`,
-		tender.Name, tender.Description, tender.ID)
+	err = tx.Commit()
 	if err != nil {
-		return fmt.Errorf("failed to update tender: %w", err)
+		return fmt.Errorf("failed to commit transaction: %w", err)
 	}
 	return nil
 }
 
-func (r *Repository) UpdateTenderStatus(tenderId string, status string) error {
-	_, err := r.db.Exec(`UPDATE tender SET status = $1, updated_at = CURRENT_TIMESTAMP WHERE id = $2`,
+func (r *Repository) UpdateTenderStatus(tenderId string, status string, events ...models.Event) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`UPDATE tender SET status = $1, updated_at = CURRENT_TIMESTAMP WHERE id = $2`,
 		status, tenderId)
 	if err != nil {
 		return fmt.Errorf("failed to update tender status: %w", err)
 	}
+
+	err = insertEvents(tx, events)
+	if err != nil {
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
 	return nil
 }
 
 func (r *Repository) AddTenderVersion(tenderVer *models.TenderVersion) error {
-	_, err := r.db.Exec(`INSERT INTO tender_version (tender_id, name, description) VALUES ($1, $2, $3)`,
-		tenderVer.TenderID, tenderVer.Name, tenderVer.Description)
+	_, err := r.db.Exec(`INSERT INTO tender_version (tender_id, name, description, deadline, attachment_ids)
+		VALUES ($1, $2, $3, $4, `+attachmentSnapshot(attachmentEntityTender)+`)`,
+		tenderVer.TenderID, tenderVer.Name, tenderVer.Description, tenderVer.Deadline)
 	if err != nil {
 		return fmt.Errorf("failed to insert data into tender_version: %w", err)
 	}
@@ -154,8 +178,8 @@ func (r *Repository) AddTenderVersion(tenderVer *models.TenderVersion) error {
 
 func (r *Repository) GetTenderByID(tenderID uuid.UUID) (*models.Tender, bool, error) {
 	var tender models.Tender
-	err := r.db.QueryRow(`SELECT id, name, description, service_type, status, organization_id, creator_username FROM tender WHERE id = $1`,
-		tenderID.String()).Scan(&tender.ID, &tender.Name, &tender.Description, &tender.ServiceType, &tender.Status, &tender.OrganizationID, &tender.CreatorUserName)
+	err := r.db.QueryRow(`SELECT id, name, description, service_type, status, organization_id, creator_username, visibility, deadline FROM tender WHERE id = $1`,
+		tenderID.String()).Scan(&tender.ID, &tender.Name, &tender.Description, &tender.ServiceType, &tender.Status, &tender.OrganizationID, &tender.CreatorUserName, &tender.Visibility, &tender.Deadline)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, false, nil
@@ -167,106 +191,121 @@ func (r *Repository) GetTenderByID(tenderID uuid.UUID) (*models.Tender, bool, er
 
 func (r *Repository) GetTenderVersionByID(tenderVerID uuid.UUID) (*models.TenderVersion, error) {
 	var tenderVer models.TenderVersion
-	err := r.db.QueryRow(`SELECT id, tender_id, name, description FROM tender_version WHERE id = $1`,
-		tenderVerID.String()).Scan(&tenderVer.ID, &tenderVer.TenderID, &tenderVer.Name, &tenderVer.Description)
+	err := r.db.QueryRow(`SELECT id, tender_id, name, description, deadline, attachment_ids FROM tender_version WHERE id = $1`,
+		tenderVerID.String()).Scan(&tenderVer.ID, &tenderVer.TenderID, &tenderVer.Name, &tenderVer.Description, &tenderVer.Deadline, pq.Array(&tenderVer.AttachmentIDs))
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("tender version no found")
+			return nil, NotFound("tender_version_not_found", "tender version not found")
 		}
 		return nil, fmt.Errorf("failed to select data from tender_version: %w", err)
 	}
 	return &tenderVer, nil
 }
 
-func (r *Repository) NewBid(bid models.Bid) error {
-	_, err := r.db.Exec(
-		`INSERT INTO bid (id, name, description, status, tender_id, author_type, author_id)
-					VALUES ($1, $2, $3, $4, $5, $6, $7)`,
-		bid.ID, bid.Name, bid.Description, bid.Status, bid.TenderID,
-		bid.AuthorType, bid.AuthorId)
+// NewBid saves a bid, with the signature of its author if any, and seals it as submitted in the
+// chain of its tender. A bid on a tender that is not published, or whose id is taken, is a
+// conflict.
+func (r *Repository) NewBid(bid models.Bid, signature *models.BidSignature, events ...models.Event) (*models.BidSeal, error) {
+	tx, err := r.db.Begin()
 	if err != nil {
-		return fmt.Errorf("failed to insert data into bid: %w", err)
-	}
-	return nil
-}
-
-func (r *Repository) MyBidsList(userId string, organizationId string, limit int, offset int) ([]models.Bid, error) {
-	if limit == 0 {
-		limit = 5
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// The bid is inserted only while the tender is published; the share lock waits for a
+	// concurrent status change and sees its outcome.
+	sig, signedBy, signingKey, signedVersion := bidSignatureValues(signature)
+	res, err := tx.Exec(
+		`INSERT INTO bid (id, name, description, status, tender_id, author_type, author_id, lot_id, version,
+						signature, signed_by, signing_key, signed_version)
+					SELECT $1, $2, $3, $4, tender.id, $5, $6, NULLIF($7, '')::uuid, $8, $9, $10, $11, $12
+					FROM tender WHERE tender.id = $13 AND tender.status = $14
+					FOR SHARE`,
+		bid.ID, bid.Name, bid.Description, bid.Status,
+		bid.AuthorType, bid.AuthorId, bid.LotID, bid.Version, sig, signedBy, signingKey, signedVersion,
+		bid.TenderID, tenderStatusPublished)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return nil, Conflict("bid_exists", "bid %s already exists", bid.ID)
+		}
+		return nil, fmt.Errorf("failed to insert data into bid: %w", err)
 	}
 
-	bids := []models.Bid{}
-	var rows *sql.Rows
-	var err error
-
-	if userId == "" || organizationId == "" {
-		return nil, fmt.Errorf("userId and userId are mandatory")
-	} else {
-		rows, err = r.db.Query("SELECT id, name, description, status, tender_id, author_type, author_id FROM bid WHERE (author_type = 'User' AND author_id = $1) OR (author_type = 'Organization' AND author_id = $2) LIMIT $3 OFFSET $4",
-			userId, organizationId, limit, offset)
+	err = expectUpdated(res, func() error { return tenderStatusConflict(tx, bid.TenderID) })
+	if err != nil {
+		return nil, err
 	}
 
+	seal, err := sealBid(tx, bid.ID, SealActionSubmit)
 	if err != nil {
-		return nil, fmt.Errorf("failed to select data from bid: %w", err)
+		return nil, err
 	}
-	defer rows.Close()
 
-	for rows.Next() {
-		bid := models.Bid{}
-		err := rows.Scan(&bid.ID, &bid.Name, &bid.Description, &bid.Status, &bid.TenderID, &bid.AuthorType, &bid.AuthorId)
-		if err != nil {
-			return nil, fmt.Errorf("failed to scan: %w", err)
-		}
-		bids = append(bids, bid)
+	err = insertEvents(tx, events)
+	if err != nil {
+		return nil, err
 	}
 
-	err = rows.Err()
+	err = tx.Commit()
 	if err != nil {
-		return nil, fmt.Errorf("rows iteration error: %w", err)
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
 	}
-
-	return bids, nil
+	return seal, nil
 }
 
-func (r *Repository) BidsByTenderId(tenderID string, limit int, offset int) ([]models.Bid, error) {
-	bids := []models.Bid{}
-	var rows *sql.Rows
-	var err error
-
-	if limit == 0 {
-		limit = 5
+func (r *Repository) MyBidsList(userId string, organizationId string, filter BidFilter) ([]models.Bid, PageInfo, error) {
+	if userId == "" || organizationId == "" {
+		return nil, PageInfo{}, fmt.Errorf("userId and organizationId are mandatory")
 	}
 
+	q := newQuery(bidColumns, "bid").
+		Where("((bid.author_type = 'User' AND bid.author_id::text = ?) OR (bid.author_type = 'Organization' AND bid.author_id::text = ?))",
+			userId, organizationId)
+	filter.apply(q)
+
+	return r.queryBids(q, filter)
+}
+
+func (r *Repository) BidsByTenderId(tenderID string, filter BidFilter) ([]models.Bid, PageInfo, error) {
 	if tenderID == "" {
-		return nil, fmt.Errorf("tenderID must not be empty")
-	} else {
-		rows, err = r.db.Query("SELECT id, name, description, status, tender_id, author_type, author_id FROM bid WHERE tender_id = $1 LIMIT $2 OFFSET $3", tenderID, limit, offset)
+		return nil, PageInfo{}, fmt.Errorf("tenderID must not be empty")
 	}
 
-	if err != nil {
-		return nil, fmt.Errorf("failed to select data from bid: %w", err)
-	}
-	defer rows.Close()
+	q := newQuery(bidColumns, "bid").Where("bid.tender_id::text = ?", tenderID)
+	filter.apply(q)
 
-	for rows.Next() {
-		bid := models.Bid{}
-		err := rows.Scan(&bid.ID, &bid.Name, &bid.Description, &bid.Status, &bid.TenderID, &bid.AuthorType, &bid.AuthorId)
-		if err != nil {
-			return nil, fmt.Errorf("failed to scan: %w", err)
-		}
-		bids = append(bids, bid)
-	}
+	return r.queryBids(q, filter)
+}
 
-	err = rows.Err()
+func (r *Repository) queryBids(q *queryBuilder, filter BidFilter) ([]models.Bid, PageInfo, error) {
+	bids := []models.Bid{}
+
+	sort := filter.sort()
+	info, err := r.paginate(q, sortKeys(sort, bidSortColumns, "bid.id"), formatSort(sort), filter.Page,
+		func(rows *tracedRows, keyDest ...interface{}) error {
+			bid := models.Bid{}
+			err := rows.Scan(append([]interface{}{&bid.ID, &bid.Name, &bid.Description, &bid.Status, &bid.TenderID,
+				&bid.AuthorType, &bid.AuthorId, &bid.LotID, &bid.Version, &bid.NeedsReconfirmation}, keyDest...)...)
+			if err != nil {
+				return err
+			}
+			bids = append(bids, bid)
+			return nil
+		})
+	if errors.Is(err, ErrInvalidCursor) {
+		return nil, info, err
+	}
 	if err != nil {
-		return nil, fmt.Errorf("rows iteration error: %w", err)
+		return nil, info, fmt.Errorf("failed to select data from bid: %w", err)
 	}
 
-	return bids, nil
+	return bids, info, nil
 }
 
+// AddBidVersion archives a version of a bid together with the signature of the current version.
 func (r *Repository) AddBidVersion(bidVer *models.BidVersion) error {
-	_, err := r.db.Exec(`INSERT INTO bid_version (bid_id, name, description) VALUES ($1, $2, $3)`,
+	_, err := r.db.Exec(`INSERT INTO bid_version (bid_id, name, description, attachment_ids, signature, signed_by, signing_key, signed_version)
+		SELECT $1, $2, $3, `+attachmentSnapshot(attachmentEntityBid)+`, signature, signed_by, signing_key, signed_version FROM bid WHERE id = $1`,
 		bidVer.BidID, bidVer.Name, bidVer.Description)
 	if err != nil {
 		return fmt.Errorf("failed to insert data into bid_version: %w", err)
@@ -277,11 +316,12 @@ func (r *Repository) AddBidVersion(bidVer *models.BidVersion) error {
 
 func (r *Repository) GetBidByID(bidID uuid.UUID) (*models.Bid, error) {
 	var bid models.Bid
-	err := r.db.QueryRow(`SELECT id, name, description, status, tender_id, author_type, author_id FROM bid WHERE id = $1`,
-		bidID.String()).Scan(&bid.ID, &bid.Name, &bid.Description, &bid.Status, &bid.TenderID, &bid.AuthorType, &bid.AuthorId)
+	err := r.db.QueryRow(`SELECT id, name, description, status, tender_id, author_type, author_id, COALESCE(lot_id::text, ''), version, needs_reconfirmation
+		FROM bid WHERE id = $1`,
+		bidID.String()).Scan(&bid.ID, &bid.Name, &bid.Description, &bid.Status, &bid.TenderID, &bid.AuthorType, &bid.AuthorId, &bid.LotID, &bid.Version, &bid.NeedsReconfirmation)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("bid not found")
+			return nil, NotFound("bid_not_found", "bid not found")
 		}
 		return nil, fmt.Errorf("failed to select data from bid: %w", err)
 	}
@@ -289,25 +329,40 @@ func (r *Repository) GetBidByID(bidID uuid.UUID) (*models.Bid, error) {
 }
 
 func (r *Repository) GetBidVersionByID(bidVerID uuid.UUID) (*models.BidVersion, error) {
-	var bidVer models.BidVersion
-	err := r.db.QueryRow(`SELECT id, bid_id, name, description FROM bid_version WHERE id = $1`,
-		bidVerID.String()).Scan(&bidVer.ID, &bidVer.BidID, &bidVer.Name, &bidVer.Description)
+	var (
+		bidVer                          models.BidVersion
+		signature, signedBy, signingKey sql.NullString
+		signedVersion                   sql.NullInt64
+	)
+	err := r.db.QueryRow(`SELECT id, bid_id, name, description, attachment_ids, signature, signed_by, signing_key, signed_version
+		FROM bid_version WHERE id = $1`,
+		bidVerID.String()).Scan(&bidVer.ID, &bidVer.BidID, &bidVer.Name, &bidVer.Description, pq.Array(&bidVer.AttachmentIDs),
+		&signature, &signedBy, &signingKey, &signedVersion)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("bid version no found")
+			return nil, NotFound("bid_version_not_found", "bid version not found")
 		}
 		return nil, fmt.Errorf("failed to select data from bid_version: %w", err)
 	}
+	bidVer.Signature = scanBidSignature(signature, signedBy, signingKey, signedVersion)
 	return &bidVer, nil
 }
 
-func (r *Repository) UpdateBid(bid *models.Bid) error {
-	_, err := r.db.Exec(`UPDATE bid SET name = $1, description = $2, updated_at = CURRENT_TIMESTAMP WHERE id = $3`,
-		bid.Name, bid.Description, bid.ID)
+// UpdateBid saves a bid as its version bid.Version, with its signature, nil for an unsigned
+// version. The version must follow the saved one, so a bid changed by another request since it
+// was read is a conflict.
+func (r *Repository) UpdateBid(bid *models.Bid, signature *models.BidSignature) error {
+	sig, signedBy, signingKey, signedVersion := bidSignatureValues(signature)
+	res, err := r.db.Exec(`UPDATE bid SET name = $1, description = $2, needs_reconfirmation = FALSE, updated_at = CURRENT_TIMESTAMP,
+		signature = $3, signed_by = $4, signing_key = $5, signed_version = $6, version = $7 WHERE id = $8 AND version = $7 - 1`,
+		bid.Name, bid.Description, sig, signedBy, signingKey, signedVersion, bid.Version, bid.ID)
 	if err != nil {
 		return fmt.Errorf("failed to update bid: %w", err)
 	}
-	return nil
+
+	return expectUpdated(res, func() error {
+		return Conflict("bid_changed", "bid has been changed by another request")
+	})
 }
 
 func (r *Repository) GetUserIDByUsername(username string) (string, bool, error) {
@@ -346,6 +401,15 @@ func (r *Repository) GetOrganizationIDByUsername(username string) (string, bool,
 	return organizationId, true, nil
 }
 
+func (r *Repository) OrganizationExists(organizationId string) (bool, error) {
+	var exists bool
+	err := r.db.QueryRow(`SELECT EXISTS (SELECT 1 FROM organization WHERE id::text = $1)`, organizationId).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("failed to find organization: %w", err)
+	}
+	return exists, nil
+}
+
 func (r *Repository) GetOrganizationIDByUserID(userId string) (string, bool, error) {
 	var organizationId string
 
@@ -0,0 +1,63 @@
+package connection
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/lib/pq"
+)
+
+// Kinds of domain errors. A domain error wraps one of them, so callers can tell a missing row,
+// a clash with the current state, a forbidden operation and an argument the repository cannot
+// use from a failure of the database.
+var (
+	ErrNotFound  = errors.New("not found")
+	ErrConflict  = errors.New("conflict")
+	ErrForbidden = errors.New("forbidden")
+	ErrInvalid   = errors.New("invalid argument")
+)
+
+// Error is a domain error. Code is stable and names the condition, e.g. "bid_not_found";
+// Args are the values its message is built from.
+type Error struct {
+	Kind error
+	Code string
+	Args []interface{}
+
+	message string
+}
+
+func (e *Error) Error() string {
+	return e.message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Kind
+}
+
+func newError(kind error, code string, format string, args ...interface{}) *Error {
+	return &Error{Kind: kind, Code: code, Args: args, message: fmt.Sprintf(format, args...)}
+}
+
+func NotFound(code string, format string, args ...interface{}) *Error {
+	return newError(ErrNotFound, code, format, args...)
+}
+
+func Conflict(code string, format string, args ...interface{}) *Error {
+	return newError(ErrConflict, code, format, args...)
+}
+
+func Forbidden(code string, format string, args ...interface{}) *Error {
+	return newError(ErrForbidden, code, format, args...)
+}
+
+func Invalid(code string, format string, args ...interface{}) *Error {
+	return newError(ErrInvalid, code, format, args...)
+}
+
+const uniqueViolation = "23505"
+
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == uniqueViolation
+}
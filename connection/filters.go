@@ -0,0 +1,80 @@
+package connection
+
+import (
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const (
+	tenderColumns = `tender.id, tender.name, tender.description, tender.service_type, tender.status, tender.organization_id,
+		tender.creator_username, tender.visibility, tender.deadline`
+	bidColumns = `bid.id, bid.name, bid.description, bid.status, bid.tender_id, bid.author_type, bid.author_id,
+		COALESCE(bid.lot_id::text, ''), bid.version, bid.needs_reconfirmation`
+
+	// tenderBudget is the budget of a tender: the total budget of its lots.
+	tenderBudget = `(SELECT COALESCE(SUM(lot.budget), 0) FROM lot WHERE lot.tender_id = tender.id)`
+)
+
+// TenderFilter narrows a tender list. A service type matches its child categories as well.
+type TenderFilter struct {
+	ServiceTypes   []string
+	Statuses       []string
+	OrganizationID string
+	CreatedFrom    *time.Time
+	CreatedTo      *time.Time
+	UpdatedFrom    *time.Time
+	UpdatedTo      *time.Time
+	BudgetMin      *float64
+	BudgetMax      *float64
+	Sort           []SortField
+	Page
+}
+
+func (f TenderFilter) apply(q *queryBuilder) {
+	if len(f.ServiceTypes) > 0 {
+		q.Where("tender.service_type IN "+serviceTypeWithChildren, pq.Array(f.ServiceTypes))
+	}
+	q.WhereIn("tender.status", f.Statuses)
+	if f.OrganizationID != "" {
+		q.Where("tender.organization_id::text = ?", f.OrganizationID)
+	}
+	q.WhereTimeRange("tender.created_at", f.CreatedFrom, f.CreatedTo)
+	q.WhereTimeRange("tender.updated_at", f.UpdatedFrom, f.UpdatedTo)
+	if f.BudgetMin != nil {
+		q.Where(tenderBudget+" >= ?", *f.BudgetMin)
+	}
+	if f.BudgetMax != nil {
+		q.Where(tenderBudget+" <= ?", *f.BudgetMax)
+	}
+}
+
+type BidFilter struct {
+	Statuses    []string
+	CreatedFrom *time.Time
+	CreatedTo   *time.Time
+	UpdatedFrom *time.Time
+	UpdatedTo   *time.Time
+	Sort        []SortField
+	Page
+}
+
+func (f TenderFilter) sort() []SortField {
+	if len(f.Sort) == 0 {
+		return []SortField{{Field: "name"}}
+	}
+	return f.Sort
+}
+
+func (f BidFilter) apply(q *queryBuilder) {
+	q.WhereIn("bid.status", f.Statuses)
+	q.WhereTimeRange("bid.created_at", f.CreatedFrom, f.CreatedTo)
+	q.WhereTimeRange("bid.updated_at", f.UpdatedFrom, f.UpdatedTo)
+}
+
+func (f BidFilter) sort() []SortField {
+	if len(f.Sort) == 0 {
+		return []SortField{{Field: "name"}}
+	}
+	return f.Sort
+}
@@ -0,0 +1,179 @@
+package connection
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+
+	"github.com/noctusha/tender/models"
+)
+
+// NotificationDeadlineReminder is the kind of inbox notifications reminding bidders that a tender closes soon.
+const NotificationDeadlineReminder = "tender.deadline"
+
+const notificationColumns = `notification.id, notification.kind, COALESCE(notification.tender_id::text, ''),
+		COALESCE(notification.bid_id::text, ''), notification.data, COALESCE(notification.read_at::text, ''), notification.created_at`
+
+func scanNotification(row interface{ Scan(...interface{}) error }, notification *models.Notification, keyDest ...interface{}) error {
+	var data []byte
+	err := row.Scan(append([]interface{}{&notification.ID, &notification.Kind, &notification.TenderID, &notification.BidID,
+		&data, &notification.ReadAt, &notification.CreatedAt}, keyDest...)...)
+	if err != nil {
+		return err
+	}
+	notification.Data = data
+	notification.Read = notification.ReadAt != ""
+	return nil
+}
+
+// insertNotifications adds the notification to the inbox of every user selected by recipients,
+// which may refer to the tender id as $3 and the bid id as $4. A user gets one notification per
+// dedup key, so an event delivered again does not show up twice.
+func (r *Repository) insertNotifications(notification models.Notification, dedupKey string, recipients string) (int, error) {
+	res, err := r.db.Exec(`INSERT INTO notification (user_id, kind, dedup_key, tender_id, bid_id, data)
+		SELECT employee.id, $1, $2, NULLIF($3, '')::uuid, NULLIF($4, '')::uuid, $5 FROM employee
+		WHERE employee.id IN (`+recipients+`)
+		ON CONFLICT (user_id, dedup_key) DO NOTHING`,
+		notification.Kind, dedupKey, notification.TenderID, notification.BidID, string(notification.Data))
+	if err != nil {
+		return 0, fmt.Errorf("failed to insert data into notification: %w", err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to insert data into notification: %w", err)
+	}
+	return int(n), nil
+}
+
+// NotifyBidAuthor notifies the author of the bid: the user, or every responsible of the organization.
+func (r *Repository) NotifyBidAuthor(notification models.Notification, dedupKey string) (int, error) {
+	return r.insertNotifications(notification, dedupKey, `
+		SELECT bid.author_id FROM bid WHERE bid.id::text = $4 AND bid.author_type = 'User'
+		UNION
+		SELECT organization_responsible.user_id FROM bid
+		JOIN organization_responsible ON organization_responsible.organization_id = bid.author_id
+		WHERE bid.id::text = $4 AND bid.author_type = 'Organization'`)
+}
+
+// NotifyTenderOwner notifies the responsibles of the organization that owns the tender.
+func (r *Repository) NotifyTenderOwner(notification models.Notification, dedupKey string) (int, error) {
+	return r.insertNotifications(notification, dedupKey, `
+		SELECT organization_responsible.user_id FROM tender
+		JOIN organization_responsible ON organization_responsible.organization_id = tender.organization_id
+		WHERE tender.id::text = $3`)
+}
+
+// CreateDeadlineReminders reminds the authors of pending bids when the deadline of a published
+// tender is less than the given time away. Each deadline is reminded of once, so moving the
+// deadline with an amendment brings a new reminder.
+func (r *Repository) CreateDeadlineReminders(within time.Duration) (int, error) {
+	res, err := r.db.Exec(`INSERT INTO notification (user_id, kind, dedup_key, tender_id, bid_id, data)
+		SELECT employee.id, $1, 'deadline:' || bid.id || ':' || tender.deadline, tender.id, bid.id,
+			jsonb_build_object('tenderId', tender.id, 'tenderName', tender.name, 'deadline', tender.deadline,
+				'bidId', bid.id, 'bidName', bid.name)
+		FROM tender
+		JOIN bid ON bid.tender_id = tender.id AND bid.status = 'CREATED'
+		JOIN LATERAL (
+			SELECT bid.author_id AS user_id WHERE bid.author_type = 'User'
+			UNION
+			SELECT organization_responsible.user_id FROM organization_responsible
+			WHERE bid.author_type = 'Organization' AND organization_responsible.organization_id = bid.author_id
+		) recipient ON TRUE
+		JOIN employee ON employee.id = recipient.user_id
+		WHERE tender.status = 'PUBLISHED' AND tender.deadline > CURRENT_TIMESTAMP
+			AND tender.deadline <= CURRENT_TIMESTAMP + make_interval(secs => $2)
+		ON CONFLICT (user_id, dedup_key) DO NOTHING`,
+		NotificationDeadlineReminder, within.Seconds())
+	if err != nil {
+		return 0, fmt.Errorf("failed to create deadline reminders: %w", err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to create deadline reminders: %w", err)
+	}
+	return int(n), nil
+}
+
+// Notifications is the inbox of a user, newest first, optionally limited to unread or read entries.
+func (r *Repository) Notifications(userID string, read *bool, page Page) ([]models.Notification, PageInfo, error) {
+	notifications := []models.Notification{}
+
+	q := newQuery(notificationColumns, "notification").Where("notification.user_id::text = ?", userID)
+	if read != nil {
+		if *read {
+			q.Where("notification.read_at IS NOT NULL")
+		} else {
+			q.Where("notification.read_at IS NULL")
+		}
+	}
+
+	keys := []sortKey{{expr: "notification.created_at", desc: true}, {expr: "notification.id"}}
+	info, err := r.paginate(q, keys, "-createdAt", page, func(rows *tracedRows, keyDest ...interface{}) error {
+		notification := models.Notification{}
+		if err := scanNotification(rows, &notification, keyDest...); err != nil {
+			return err
+		}
+		notifications = append(notifications, notification)
+		return nil
+	})
+	if errors.Is(err, ErrInvalidCursor) {
+		return nil, info, err
+	}
+	if err != nil {
+		return nil, info, fmt.Errorf("failed to select data from notification: %w", err)
+	}
+
+	return notifications, info, nil
+}
+
+func (r *Repository) UnreadNotificationsCount(userID string) (int, error) {
+	var count int
+	err := r.db.QueryRow(`SELECT COUNT(*) FROM notification WHERE user_id = $1 AND read_at IS NULL`, userID).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count notifications: %w", err)
+	}
+	return count, nil
+}
+
+func (r *Repository) GetNotificationByID(notificationID uuid.UUID, userID string) (*models.Notification, bool, error) {
+	var notification models.Notification
+	err := scanNotification(r.db.QueryRow(`SELECT `+notificationColumns+` FROM notification WHERE id = $1 AND user_id = $2`,
+		notificationID.String(), userID), &notification)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, false, nil
+		}
+		return nil, false, fmt.Errorf("failed to select data from notification: %w", err)
+	}
+	return &notification, true, nil
+}
+
+// MarkNotificationsRead marks the given unread notifications of the user as read, or all of them
+// when ids is nil. It returns how many were marked.
+func (r *Repository) MarkNotificationsRead(userID string, ids []string) (int, error) {
+	var (
+		res sql.Result
+		err error
+	)
+	if ids == nil {
+		res, err = r.db.Exec(`UPDATE notification SET read_at = CURRENT_TIMESTAMP WHERE user_id = $1 AND read_at IS NULL`, userID)
+	} else {
+		res, err = r.db.Exec(`UPDATE notification SET read_at = CURRENT_TIMESTAMP
+			WHERE user_id = $1 AND read_at IS NULL AND id::text = ANY($2)`, userID, pq.Array(ids))
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to mark notifications read: %w", err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to mark notifications read: %w", err)
+	}
+	return int(n), nil
+}
@@ -0,0 +1,118 @@
+package connection
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+
+	"github.com/noctusha/tender/models"
+)
+
+const invitationStatusDeclined = "DECLINED"
+
+// tenderVisibleTo is a condition on the tender table matching tenders the organization may see.
+// It takes the organization id twice.
+const tenderVisibleTo = `(tender.visibility = 'PUBLIC' OR tender.organization_id::text = ? OR EXISTS (
+	SELECT 1 FROM tender_invitation WHERE tender_invitation.tender_id = tender.id
+	AND tender_invitation.organization_id::text = ? AND tender_invitation.status <> 'DECLINED'))`
+
+func (r *Repository) NewInvitation(invitation models.TenderInvitation) error {
+	_, err := r.db.Exec(`INSERT INTO tender_invitation (id, tender_id, organization_id, status) VALUES ($1, $2, $3, $4)`,
+		invitation.ID, invitation.TenderID, invitation.OrganizationID, invitation.Status)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return Conflict("organization_already_invited", "organization %s is already invited", invitation.OrganizationID)
+		}
+		return fmt.Errorf("failed to insert data into tender_invitation: %w", err)
+	}
+	return nil
+}
+
+func (r *Repository) invitationsList(query string, args ...interface{}) ([]models.TenderInvitation, error) {
+	invitations := []models.TenderInvitation{}
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to select data from tender_invitation: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		invitation := models.TenderInvitation{}
+		err := rows.Scan(&invitation.ID, &invitation.TenderID, &invitation.OrganizationID, &invitation.Status, &invitation.CreatedAt, &invitation.UpdatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan: %w", err)
+		}
+		invitations = append(invitations, invitation)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, fmt.Errorf("rows iteration error: %w", err)
+	}
+
+	return invitations, nil
+}
+
+func (r *Repository) InvitationsByTenderID(tenderID string) ([]models.TenderInvitation, error) {
+	return r.invitationsList(`SELECT id, tender_id, organization_id, status, created_at, updated_at
+		FROM tender_invitation WHERE tender_id = $1 ORDER BY created_at`, tenderID)
+}
+
+func (r *Repository) InvitationsByOrganizationID(organizationID string) ([]models.TenderInvitation, error) {
+	return r.invitationsList(`SELECT id, tender_id, organization_id, status, created_at, updated_at
+		FROM tender_invitation WHERE organization_id = $1 ORDER BY created_at DESC`, organizationID)
+}
+
+func (r *Repository) GetInvitationByID(invitationID uuid.UUID) (*models.TenderInvitation, bool, error) {
+	var invitation models.TenderInvitation
+	err := r.db.QueryRow(`SELECT id, tender_id, organization_id, status, created_at, updated_at FROM tender_invitation WHERE id = $1`,
+		invitationID.String()).Scan(&invitation.ID, &invitation.TenderID, &invitation.OrganizationID, &invitation.Status, &invitation.CreatedAt, &invitation.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, false, nil
+		}
+		return nil, false, fmt.Errorf("failed to select data from tender_invitation: %w", err)
+	}
+	return &invitation, true, nil
+}
+
+func (r *Repository) InvitationExists(tenderID string, organizationID string) (bool, error) {
+	var exists bool
+	err := r.db.QueryRow(`SELECT EXISTS (SELECT 1 FROM tender_invitation WHERE tender_id = $1 AND organization_id::text = $2)`,
+		tenderID, organizationID).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("failed to check tender invitation: %w", err)
+	}
+	return exists, nil
+}
+
+// IsOrganizationInvited reports whether the organization holds an invitation to the tender it has not declined.
+func (r *Repository) IsOrganizationInvited(tenderID string, organizationID string) (bool, error) {
+	var invited bool
+	err := r.db.QueryRow(`SELECT EXISTS (SELECT 1 FROM tender_invitation WHERE tender_id = $1 AND organization_id::text = $2 AND status <> $3)`,
+		tenderID, organizationID, invitationStatusDeclined).Scan(&invited)
+	if err != nil {
+		return false, fmt.Errorf("failed to check tender invitation: %w", err)
+	}
+	return invited, nil
+}
+
+func (r *Repository) UpdateInvitationStatus(invitationID string, status string) error {
+	_, err := r.db.Exec(`UPDATE tender_invitation SET status = $1, updated_at = CURRENT_TIMESTAMP WHERE id = $2`,
+		status, invitationID)
+	if err != nil {
+		return fmt.Errorf("failed to update tender invitation status: %w", err)
+	}
+	return nil
+}
+
+func (r *Repository) DeleteInvitation(invitationID string) error {
+	_, err := r.db.Exec(`DELETE FROM tender_invitation WHERE id = $1`, invitationID)
+	if err != nil {
+		return fmt.Errorf("failed to delete tender invitation: %w", err)
+	}
+	return nil
+}
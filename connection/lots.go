@@ -0,0 +1,338 @@
+package connection
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+
+	"github.com/noctusha/tender/models"
+)
+
+const (
+	lotStatusOpen      = "OPEN"
+	lotStatusAwarded   = "AWARDED"
+	lotStatusCancelled = "CANCELLED"
+
+	bidStatusCreated   = "CREATED"
+	bidStatusApproved  = "APPROVED"
+	bidStatusRejected  = "REJECTED"
+	bidStatusWithdrawn = "WITHDRAWN"
+
+	tenderStatusPublished = "PUBLISHED"
+	tenderStatusClosed    = "CLOSED"
+)
+
+func insertLot(tx *transaction, lot models.Lot) error {
+	_, err := tx.Exec(
+		`INSERT INTO lot (id, tender_id, description, quantity, budget, service_type, status)
+					VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		lot.ID, lot.TenderID, lot.Description, lot.Quantity, lot.Budget, lot.ServiceType, lot.Status)
+	if err != nil {
+		return fmt.Errorf("failed to insert data into lot: %w", err)
+	}
+	return nil
+}
+
+func (r *Repository) NewLot(lot models.Lot) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	err = insertLot(tx, lot)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *Repository) LotsByTenderID(tenderID string) ([]models.Lot, error) {
+	lots := []models.Lot{}
+
+	rows, err := r.db.Query(`SELECT id, tender_id, description, quantity, budget, service_type, status, COALESCE(winner_bid_id::text, '')
+		FROM lot WHERE tender_id = $1 ORDER BY created_at, id`, tenderID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to select data from lot: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		lot := models.Lot{}
+		err := rows.Scan(&lot.ID, &lot.TenderID, &lot.Description, &lot.Quantity, &lot.Budget, &lot.ServiceType, &lot.Status, &lot.WinnerBidID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan: %w", err)
+		}
+		lots = append(lots, lot)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, fmt.Errorf("rows iteration error: %w", err)
+	}
+
+	return lots, nil
+}
+
+func (r *Repository) GetLotByID(lotID uuid.UUID) (*models.Lot, bool, error) {
+	var lot models.Lot
+	err := r.db.QueryRow(`SELECT id, tender_id, description, quantity, budget, service_type, status, COALESCE(winner_bid_id::text, '')
+		FROM lot WHERE id = $1`, lotID.String()).
+		Scan(&lot.ID, &lot.TenderID, &lot.Description, &lot.Quantity, &lot.Budget, &lot.ServiceType, &lot.Status, &lot.WinnerBidID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, false, nil
+		}
+		return nil, false, fmt.Errorf("failed to select data from lot: %w", err)
+	}
+	return &lot, true, nil
+}
+
+func (r *Repository) TenderHasLots(tenderID string) (bool, error) {
+	var exists bool
+	err := r.db.QueryRow(`SELECT EXISTS (SELECT 1 FROM lot WHERE tender_id = $1)`, tenderID).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("failed to check tender lots: %w", err)
+	}
+	return exists, nil
+}
+
+// AwardLot approves the bid, rejects the other pending bids on the same lot and
+// closes the tender once none of its lots is open. It reports whether the tender was closed.
+func (r *Repository) AwardLot(lotID string, bidID string, events ...models.Event) (bool, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var tenderID string
+	err = tx.QueryRow(`UPDATE lot SET status = $1, winner_bid_id = $2, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $3 AND status = $4 RETURNING tender_id`,
+		lotStatusAwarded, bidID, lotID, lotStatusOpen).Scan(&tenderID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, lotStatusConflict(tx, lotID)
+		}
+		return false, fmt.Errorf("failed to award lot: %w", err)
+	}
+
+	res, err := tx.Exec(`UPDATE bid SET status = $1, updated_at = CURRENT_TIMESTAMP WHERE id = $2 AND status = $3`,
+		bidStatusApproved, bidID, bidStatusCreated)
+	if err != nil {
+		return false, fmt.Errorf("failed to approve bid: %w", err)
+	}
+
+	err = expectUpdated(res, func() error { return bidStatusConflict(tx, bidID) })
+	if err != nil {
+		return false, err
+	}
+
+	_, err = tx.Exec(`UPDATE bid SET status = $1, updated_at = CURRENT_TIMESTAMP WHERE lot_id = $2 AND id <> $3 AND status = $4`,
+		bidStatusRejected, lotID, bidID, bidStatusCreated)
+	if err != nil {
+		return false, fmt.Errorf("failed to reject competing bids: %w", err)
+	}
+
+	closed, err := closeTenderIfLotsSettled(tx, tenderID)
+	if err != nil {
+		return false, err
+	}
+
+	if closed {
+		event, err := tenderClosedEvent(tx, tenderID)
+		if err != nil {
+			return false, err
+		}
+		events = append(events, event)
+	}
+
+	err = insertEvents(tx, events)
+	if err != nil {
+		return false, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return false, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return closed, nil
+}
+
+// CancelLot cancels an open lot and closes the tender once none of its lots is open.
+// It reports whether the tender was closed.
+func (r *Repository) CancelLot(lotID string, events ...models.Event) (bool, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var tenderID string
+	err = tx.QueryRow(`UPDATE lot SET status = $1, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $2 AND status = $3 RETURNING tender_id`,
+		lotStatusCancelled, lotID, lotStatusOpen).Scan(&tenderID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, lotStatusConflict(tx, lotID)
+		}
+		return false, fmt.Errorf("failed to cancel lot: %w", err)
+	}
+
+	_, err = tx.Exec(`UPDATE bid SET status = $1, updated_at = CURRENT_TIMESTAMP WHERE lot_id = $2 AND status = $3`,
+		bidStatusRejected, lotID, bidStatusCreated)
+	if err != nil {
+		return false, fmt.Errorf("failed to reject bids of cancelled lot: %w", err)
+	}
+
+	closed, err := closeTenderIfLotsSettled(tx, tenderID)
+	if err != nil {
+		return false, err
+	}
+
+	if closed {
+		event, err := tenderClosedEvent(tx, tenderID)
+		if err != nil {
+			return false, err
+		}
+		events = append(events, event)
+	}
+
+	err = insertEvents(tx, events)
+	if err != nil {
+		return false, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return false, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return closed, nil
+}
+
+// closeTenderIfLotsSettled closes a published tender none of whose lots is open.
+func closeTenderIfLotsSettled(tx *transaction, tenderID string) (bool, error) {
+	res, err := tx.Exec(`UPDATE tender SET status = $1, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $2 AND status = $3 AND NOT EXISTS (SELECT 1 FROM lot WHERE tender_id = $2 AND status = $4)`,
+		tenderStatusClosed, tenderID, tenderStatusPublished, lotStatusOpen)
+	if err != nil {
+		return false, fmt.Errorf("failed to close tender: %w", err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to close tender: %w", err)
+	}
+	return n > 0, nil
+}
+
+// DecideBid sets the decision on a pending bid of a tender without lots. An approved bid wins
+// the whole tender, so the tender must be published with no open lots; the remaining pending
+// bids are rejected and the tender is closed. A concurrent decision makes it a conflict.
+func (r *Repository) DecideBid(bid *models.Bid, status string, events ...models.Event) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`UPDATE bid SET status = $1, updated_at = CURRENT_TIMESTAMP WHERE id = $2 AND status = $3`,
+		status, bid.ID, bidStatusCreated)
+	if err != nil {
+		return fmt.Errorf("failed to update bid status: %w", err)
+	}
+
+	err = expectUpdated(res, func() error { return bidStatusConflict(tx, bid.ID) })
+	if err != nil {
+		return err
+	}
+
+	if status == bidStatusApproved {
+		var openLots bool
+		err = tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM lot WHERE tender_id = $1 AND status = $2)`,
+			bid.TenderID, lotStatusOpen).Scan(&openLots)
+		if err != nil {
+			return fmt.Errorf("failed to check tender lots: %w", err)
+		}
+
+		if openLots {
+			return Conflict("tender_has_open_lots", "tender has open lots")
+		}
+
+		res, err = tx.Exec(`UPDATE tender SET status = $1, updated_at = CURRENT_TIMESTAMP WHERE id = $2 AND status = $3`,
+			tenderStatusClosed, bid.TenderID, tenderStatusPublished)
+		if err != nil {
+			return fmt.Errorf("failed to close tender: %w", err)
+		}
+
+		err = expectUpdated(res, func() error { return tenderStatusConflict(tx, bid.TenderID) })
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(`UPDATE bid SET status = $1, updated_at = CURRENT_TIMESTAMP WHERE tender_id = $2 AND id <> $3 AND status = $4`,
+			bidStatusRejected, bid.TenderID, bid.ID, bidStatusCreated)
+		if err != nil {
+			return fmt.Errorf("failed to reject competing bids: %w", err)
+		}
+
+		event, err := tenderClosedEvent(tx, bid.TenderID)
+		if err != nil {
+			return err
+		}
+		events = append(events, event)
+	}
+
+	err = insertEvents(tx, events)
+	if err != nil {
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// expectUpdated returns the error of conflict when a guarded UPDATE changed no row.
+func expectUpdated(res sql.Result, conflict func() error) error {
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get affected rows: %w", err)
+	}
+	if n == 0 {
+		return conflict()
+	}
+	return nil
+}
+
+func bidStatusConflict(tx *transaction, bidID string) error {
+	var status string
+	err := tx.QueryRow(`SELECT status FROM bid WHERE id = $1`, bidID).Scan(&status)
+	if err != nil {
+		return fmt.Errorf("failed to select bid status: %w", err)
+	}
+	return Conflict("bid_status_conflict", "bid is already %s", status)
+}
+
+func lotStatusConflict(tx *transaction, lotID string) error {
+	var status string
+	err := tx.QueryRow(`SELECT status FROM lot WHERE id = $1`, lotID).Scan(&status)
+	if err != nil {
+		return fmt.Errorf("failed to select lot status: %w", err)
+	}
+	return Conflict("lot_status_conflict", "lot is already %s", status)
+}
+
+func tenderStatusConflict(tx *transaction, tenderID string) error {
+	var status string
+	err := tx.QueryRow(`SELECT status FROM tender WHERE id = $1`, tenderID).Scan(&status)
+	if err != nil {
+		return fmt.Errorf("failed to select tender status: %w", err)
+	}
+	return Conflict("tender_status_conflict", "tender is %s", status)
+}
@@ -0,0 +1,158 @@
+package connection
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/lib/pq"
+
+	"github.com/noctusha/tender/models"
+)
+
+const defaultNotificationLanguage = "ru"
+
+func (r *Repository) NotificationPreferencesByUserID(userID string) (models.NotificationPreferences, bool, error) {
+	var (
+		preferences models.NotificationPreferences
+		updatedAt   sql.NullString
+	)
+
+	err := r.db.QueryRow(`SELECT COALESCE(employee.email, ''), COALESCE(notification_preference.email_enabled, TRUE),
+		COALESCE(notification_preference.language, $2), COALESCE(notification_preference.muted_events, '{}'), notification_preference.updated_at
+		FROM employee LEFT JOIN notification_preference ON notification_preference.user_id = employee.id
+		WHERE employee.id = $1`, userID, defaultNotificationLanguage).
+		Scan(&preferences.Email, &preferences.EmailEnabled, &preferences.Language, pq.Array(&preferences.MutedEvents), &updatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return preferences, false, nil
+		}
+		return preferences, false, fmt.Errorf("failed to select notification preferences: %w", err)
+	}
+
+	preferences.UpdatedAt = updatedAt.String
+	return preferences, true, nil
+}
+
+// UpdateNotificationPreferences saves the preferences of a user together with the address
+// notifications are sent to.
+func (r *Repository) UpdateNotificationPreferences(userID string, preferences *models.NotificationPreferences) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`UPDATE employee SET email = NULLIF($1, ''), updated_at = CURRENT_TIMESTAMP WHERE id = $2`,
+		preferences.Email, userID)
+	if err != nil {
+		return fmt.Errorf("failed to update employee email: %w", err)
+	}
+
+	err = tx.QueryRow(`INSERT INTO notification_preference (user_id, email_enabled, language, muted_events)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (user_id) DO UPDATE SET email_enabled = EXCLUDED.email_enabled, language = EXCLUDED.language,
+			muted_events = EXCLUDED.muted_events, updated_at = CURRENT_TIMESTAMP
+		RETURNING updated_at`,
+		userID, preferences.EmailEnabled, preferences.Language, pq.Array(preferences.MutedEvents)).Scan(&preferences.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to save notification preferences: %w", err)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// notificationRecipients selects the users matching the condition on employee.id who have an
+// email address and did not turn off email or mute the event type, passed as $1.
+func (r *Repository) notificationRecipients(condition string, args ...interface{}) ([]models.NotificationRecipient, error) {
+	recipients := []models.NotificationRecipient{}
+
+	rows, err := r.db.Query(`SELECT employee.username, COALESCE(employee.first_name, ''), employee.email,
+		COALESCE(notification_preference.language, '`+defaultNotificationLanguage+`')
+		FROM employee LEFT JOIN notification_preference ON notification_preference.user_id = employee.id
+		WHERE COALESCE(employee.email, '') <> '' AND COALESCE(notification_preference.email_enabled, TRUE)
+		AND NOT $1 = ANY(COALESCE(notification_preference.muted_events, '{}'))
+		AND `+condition+` ORDER BY employee.username`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to select notification recipients: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var recipient models.NotificationRecipient
+		err := rows.Scan(&recipient.Username, &recipient.FirstName, &recipient.Email, &recipient.Language)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan: %w", err)
+		}
+		recipients = append(recipients, recipient)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, fmt.Errorf("rows iteration error: %w", err)
+	}
+
+	return recipients, nil
+}
+
+// TenderBidderRecipients returns the users to notify about an event of a tender they bid on:
+// the authors of bids that were not withdrawn and the responsibles of bidding organizations.
+func (r *Repository) TenderBidderRecipients(tenderID string, eventType string) ([]models.NotificationRecipient, error) {
+	return r.notificationRecipients(`employee.id IN (
+			SELECT bid.author_id FROM bid WHERE bid.tender_id = $2 AND bid.author_type = 'User' AND bid.status <> 'WITHDRAWN'
+			UNION
+			SELECT organization_responsible.user_id FROM bid
+			JOIN organization_responsible ON organization_responsible.organization_id = bid.author_id
+			WHERE bid.tender_id = $2 AND bid.author_type = 'Organization' AND bid.status <> 'WITHDRAWN'
+		)`,
+		eventType, tenderID)
+}
+
+// OrganizationRecipients returns the responsibles of the organization to notify about an event.
+func (r *Repository) OrganizationRecipients(organizationID string, eventType string) ([]models.NotificationRecipient, error) {
+	return r.notificationRecipients(`employee.id IN (SELECT user_id FROM organization_responsible WHERE organization_id = $2)`,
+		eventType, organizationID)
+}
+
+// EmailDeliveries returns the recipients an event was already emailed to, or refused for good,
+// so an event sent again reaches only the others.
+func (r *Repository) EmailDeliveries(eventID string) (map[string]bool, error) {
+	delivered := make(map[string]bool)
+
+	rows, err := r.db.Query(`SELECT username FROM email_delivery WHERE event_id = $1`, eventID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to select email deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var username string
+		err := rows.Scan(&username)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan: %w", err)
+		}
+		delivered[username] = true
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, fmt.Errorf("rows iteration error: %w", err)
+	}
+
+	return delivered, nil
+}
+
+// RecordEmailDelivery remembers that the email about an event reached a recipient, or, with a
+// failure, that the server refused it for good.
+func (r *Repository) RecordEmailDelivery(eventID string, username string, failure string) error {
+	_, err := r.db.Exec(`INSERT INTO email_delivery (event_id, username, failure) VALUES ($1, $2, NULLIF($3, ''))
+		ON CONFLICT (event_id, username) DO NOTHING`, eventID, username, failure)
+	if err != nil {
+		return fmt.Errorf("failed to record email delivery: %w", err)
+	}
+	return nil
+}
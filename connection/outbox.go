@@ -0,0 +1,265 @@
+package connection
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+
+	"github.com/noctusha/tender/models"
+)
+
+const (
+	EventTenderPublished = "tender.published"
+	EventTenderAmended   = "tender.amended"
+	EventTenderClosed    = "tender.closed"
+	EventTenderCancelled = "tender.cancelled"
+	EventBidCreated      = "bid.created"
+	EventBidApproved     = "bid.approved"
+	EventBidRejected     = "bid.rejected"
+	EventBidWithdrawn    = "bid.withdrawn"
+
+	EventTenderUpdated    = "tender.updated"
+	EventQuestionAnswered = "question.answered"
+)
+
+// EventTypes lists every domain event type.
+var EventTypes = []string{
+	EventTenderPublished, EventTenderAmended, EventTenderClosed, EventTenderCancelled,
+	EventBidCreated, EventBidApproved, EventBidRejected, EventBidWithdrawn,
+	EventTenderUpdated, EventQuestionAnswered,
+}
+
+func IsEventType(eventType string) bool {
+	for _, t := range EventTypes {
+		if t == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// NewEvent creates an event to be passed to a repository method, which records it in the
+// same transaction as the change. Data is encoded when the transaction writes it, so a pointer
+// reflects what the method filled in.
+func NewEvent(eventType string, data interface{}, organizationIDs ...string) models.Event {
+	return models.Event{
+		ID:              uuid.New().String(),
+		Type:            eventType,
+		OrganizationIDs: organizationIDs,
+		Data:            data,
+	}
+}
+
+// NewTenderEvent creates an event that is also shown in the event stream of the tender.
+func NewTenderEvent(eventType string, tenderID string, data interface{}, organizationIDs ...string) models.Event {
+	event := NewEvent(eventType, data, organizationIDs...)
+	event.TenderID = tenderID
+	return event
+}
+
+func insertEvents(tx *transaction, events []models.Event) error {
+	for _, event := range events {
+		payload, err := json.Marshal(event.Data)
+		if err != nil {
+			return fmt.Errorf("failed to encode %s event: %w", event.Type, err)
+		}
+
+		_, err = tx.Exec(`INSERT INTO outbox_event (id, event_type, organization_ids, payload, tender_id, restricted)
+			VALUES ($1, $2, $3, $4, NULLIF($5, '')::uuid, $6)`,
+			event.ID, event.Type, pq.Array(event.OrganizationIDs), string(payload), event.TenderID, event.Restricted)
+		if err != nil {
+			return fmt.Errorf("failed to insert data into outbox_event: %w", err)
+		}
+	}
+	return nil
+}
+
+// tenderClosedEvent describes a tender closed as a side effect of settling its bids or lots.
+func tenderClosedEvent(tx *transaction, tenderID string) (models.Event, error) {
+	var tender models.Tender
+	err := tx.QueryRow(`SELECT `+tenderColumns+` FROM tender WHERE id = $1`, tenderID).
+		Scan(&tender.ID, &tender.Name, &tender.Description, &tender.ServiceType, &tender.Status, &tender.OrganizationID,
+			&tender.CreatorUserName, &tender.Visibility, &tender.Deadline)
+	if err != nil {
+		return models.Event{}, fmt.Errorf("failed to select data from tender: %w", err)
+	}
+	return NewTenderEvent(EventTenderClosed, tender.ID, tender, tender.OrganizationID), nil
+}
+
+const outboxEventColumns = `id, sequence, event_type, organization_ids, payload, COALESCE(tender_id::text, ''), restricted, created_at`
+
+func scanOutboxEvent(rows *tracedRows, event *models.Event, extra ...interface{}) error {
+	var payload []byte
+	dest := []interface{}{&event.ID, &event.Sequence, &event.Type, pq.Array(&event.OrganizationIDs), &payload,
+		&event.TenderID, &event.Restricted, &event.CreatedAt}
+	err := rows.Scan(append(dest, extra...)...)
+	if err != nil {
+		return err
+	}
+	event.Data = json.RawMessage(payload)
+	return nil
+}
+
+// OutboxEvent is an event claimed for publishing, with the sinks that already accepted it.
+type OutboxEvent struct {
+	Event          models.Event
+	PublishedSinks []string
+	Attempts       int
+}
+
+// ClaimOutboxEvents takes up to limit unpublished events that are due, oldest first. Claimed events
+// are hidden from other dispatchers for the lease, so an event is retried if its dispatcher dies.
+func (r *Repository) ClaimOutboxEvents(limit int, lease time.Duration) ([]OutboxEvent, error) {
+	events := []OutboxEvent{}
+
+	rows, err := r.db.Query(`UPDATE outbox_event SET next_attempt_at = CURRENT_TIMESTAMP + make_interval(secs => $2)
+		WHERE id IN (
+			SELECT id FROM outbox_event WHERE published_at IS NULL AND next_attempt_at <= CURRENT_TIMESTAMP
+			ORDER BY sequence LIMIT $1 FOR UPDATE SKIP LOCKED
+		)
+		RETURNING `+outboxEventColumns+`, published_sinks, attempts`,
+		limit, lease.Seconds())
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim outbox events: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var event OutboxEvent
+		err := scanOutboxEvent(rows, &event.Event, pq.Array(&event.PublishedSinks), &event.Attempts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan: %w", err)
+		}
+		events = append(events, event)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, fmt.Errorf("rows iteration error: %w", err)
+	}
+
+	return events, nil
+}
+
+// MarkOutboxSinkPublished records that a sink accepted the event, so a retry skips it.
+func (r *Repository) MarkOutboxSinkPublished(eventID string, sink string) error {
+	_, err := r.db.Exec(`UPDATE outbox_event SET published_sinks = array_append(published_sinks, $1)
+		WHERE id = $2 AND NOT $1 = ANY(published_sinks)`, sink, eventID)
+	if err != nil {
+		return fmt.Errorf("failed to update outbox event: %w", err)
+	}
+	return nil
+}
+
+func (r *Repository) MarkOutboxEventPublished(eventID string) error {
+	_, err := r.db.Exec(`UPDATE outbox_event SET published_at = CURRENT_TIMESTAMP, attempts = attempts + 1, last_error = NULL
+		WHERE id = $1`, eventID)
+	if err != nil {
+		return fmt.Errorf("failed to update outbox event: %w", err)
+	}
+	return nil
+}
+
+func (r *Repository) MarkOutboxEventFailed(eventID string, reason string, retryAt time.Time) error {
+	_, err := r.db.Exec(`UPDATE outbox_event SET attempts = attempts + 1, last_error = $1, next_attempt_at = $2 WHERE id = $3`,
+		reason, retryAt, eventID)
+	if err != nil {
+		return fmt.Errorf("failed to update outbox event: %w", err)
+	}
+	return nil
+}
+
+// StreamPosition is the place of an event in the stream of its tender: the transaction that
+// recorded it, then its sequence. Sequences are taken when events are inserted, not when they
+// are committed, so a stream read by sequence would skip an event whose transaction commits after
+// a later one. The stream is therefore read in the order of transactions, and only up to the
+// oldest transaction still running: everything before it is committed and can no longer change.
+type StreamPosition struct {
+	XID      int64
+	Sequence int64
+}
+
+func (p StreamPosition) String() string {
+	return strconv.FormatInt(p.XID, 10) + "-" + strconv.FormatInt(p.Sequence, 10)
+}
+
+// ParseStreamPosition reads a position written by String.
+func ParseStreamPosition(s string) (StreamPosition, error) {
+	xid, sequence, ok := strings.Cut(s, "-")
+	if !ok {
+		return StreamPosition{}, fmt.Errorf("invalid stream position: %s", s)
+	}
+
+	var (
+		p   StreamPosition
+		err error
+	)
+	p.XID, err = strconv.ParseInt(xid, 10, 64)
+	if err != nil || p.XID < 0 {
+		return StreamPosition{}, fmt.Errorf("invalid stream position: %s", s)
+	}
+	p.Sequence, err = strconv.ParseInt(sequence, 10, 64)
+	if err != nil || p.Sequence < 0 {
+		return StreamPosition{}, fmt.Errorf("invalid stream position: %s", s)
+	}
+	return p, nil
+}
+
+// committedBefore limits the stream to the transactions older than any still running.
+const committedBefore = `xid < pg_snapshot_xmin(pg_current_snapshot())`
+
+// TenderEventsAfter returns the stream events of the tender after the given position, in stream
+// order, with the position of each.
+func (r *Repository) TenderEventsAfter(tenderID string, after StreamPosition) ([]models.Event, []StreamPosition, error) {
+	events := []models.Event{}
+	positions := []StreamPosition{}
+
+	rows, err := r.db.Query(`SELECT `+outboxEventColumns+`, xid::text FROM outbox_event
+		WHERE tender_id = $1 AND (xid, sequence) > ($2::text::xid8, $3) AND `+committedBefore+`
+		ORDER BY xid, sequence`, tenderID, after.XID, after.Sequence)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to select data from outbox_event: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			event    models.Event
+			position StreamPosition
+		)
+		err := scanOutboxEvent(rows, &event, &position.XID)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to scan: %w", err)
+		}
+		position.Sequence = event.Sequence
+		events = append(events, event)
+		positions = append(positions, position)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, nil, fmt.Errorf("rows iteration error: %w", err)
+	}
+
+	return events, positions, nil
+}
+
+// LastTenderEventPosition returns the position of the latest event of the tender stream, the
+// zero position if it has none.
+func (r *Repository) LastTenderEventPosition(tenderID string) (StreamPosition, error) {
+	var p StreamPosition
+	err := r.db.QueryRow(`SELECT xid::text, sequence FROM outbox_event
+		WHERE tender_id = $1 AND `+committedBefore+`
+		ORDER BY xid DESC, sequence DESC LIMIT 1`, tenderID).Scan(&p.XID, &p.Sequence)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return StreamPosition{}, fmt.Errorf("failed to select data from outbox_event: %w", err)
+	}
+	return p, nil
+}
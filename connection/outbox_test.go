@@ -0,0 +1,39 @@
+package connection
+
+import "testing"
+
+func TestParseStreamPosition(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    StreamPosition
+		wantErr bool
+	}{
+		{in: "0-0", want: StreamPosition{}},
+		{in: "7345-42", want: StreamPosition{XID: 7345, Sequence: 42}},
+		{in: "9223372036854775807-1", want: StreamPosition{XID: 9223372036854775807, Sequence: 1}},
+		{in: "42", wantErr: true},
+		{in: "", wantErr: true},
+		{in: "-1-2", wantErr: true},
+		{in: "1--2", wantErr: true},
+		{in: "a-1", wantErr: true},
+		{in: "1-b", wantErr: true},
+		{in: "1-2-3", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParseStreamPosition(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseStreamPosition(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if got != tt.want {
+				t.Errorf("ParseStreamPosition(%q) = %+v, want %+v", tt.in, got, tt.want)
+			}
+			if got.String() != tt.in {
+				t.Errorf("String() = %q, want %q", got.String(), tt.in)
+			}
+		})
+	}
+}
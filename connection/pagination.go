@@ -0,0 +1,158 @@
+package connection
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+const (
+	defaultListLimit = 5
+	MaxListLimit     = 100
+)
+
+// ErrInvalidCursor is returned by list methods for a cursor that cannot be decoded
+// or was issued for a different sort order.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Page selects a page of a list either by offset or by the cursor returned with the previous page.
+type Page struct {
+	Limit     int
+	Offset    int
+	Cursor    string
+	WithTotal bool
+}
+
+type PageInfo struct {
+	// NextCursor is empty on the last page.
+	NextCursor string
+	// Total is the number of rows matching the filter, counted only when asked for.
+	Total *int
+}
+
+// cursor is the position of the last row of a page: the text form of its sort keys.
+// The sort is kept so a cursor cannot be replayed against another order.
+type cursor struct {
+	Sort   string    `json:"s"`
+	Values []*string `json:"v"`
+}
+
+func encodeCursor(c cursor) (string, error) {
+	data, err := json.Marshal(c)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode cursor: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+func decodeCursor(value string) (cursor, error) {
+	var c cursor
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return c, ErrInvalidCursor
+	}
+	if err := json.Unmarshal(data, &c); err != nil {
+		return c, ErrInvalidCursor
+	}
+	return c, nil
+}
+
+// cursorValues decodes the cursor of a page into the sort keys of the row the page starts after.
+// The cursor must have been issued for the same sort.
+func cursorValues(page Page, keys []sortKey, sort string) ([]*string, error) {
+	if page.Offset > 0 {
+		return nil, fmt.Errorf("%w: cursor cannot be combined with offset", ErrInvalidCursor)
+	}
+
+	c, err := decodeCursor(page.Cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.Sort != sort || len(c.Values) != len(keys) {
+		return nil, fmt.Errorf("%w: cursor was issued for a different sort", ErrInvalidCursor)
+	}
+	return c.Values, nil
+}
+
+// paginate orders and pages the query and runs it. scan is called for every row of the page
+// and must scan the regular columns followed by keyDest.
+func (r *Repository) paginate(q *queryBuilder, keys []sortKey, sort string, page Page, scan func(rows *tracedRows, keyDest ...interface{}) error) (PageInfo, error) {
+	var info PageInfo
+
+	if page.WithTotal {
+		query, args := q.BuildCount()
+		var total int
+		err := r.db.QueryRow(query, args...).Scan(&total)
+		if err != nil {
+			return info, fmt.Errorf("failed to count rows: %w", err)
+		}
+		info.Total = &total
+	}
+
+	if page.Cursor != "" {
+		values, err := cursorValues(page, keys, sort)
+		if err != nil {
+			return info, err
+		}
+		q.After(keys, values)
+	}
+
+	limit := page.Limit
+	if limit <= 0 {
+		limit = defaultListLimit
+	}
+
+	q.SelectKeys(keys).OrderBy(orderTerms(keys)...)
+	// One row more than asked for tells whether there is a next page.
+	q.Page(limit+1, page.Offset)
+
+	query, args := q.Build()
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return info, fmt.Errorf("failed to select page: %w", err)
+	}
+	defer rows.Close()
+
+	values := make([]sql.NullString, len(keys))
+	keyDest := make([]interface{}, len(keys))
+	for i := range values {
+		keyDest[i] = &values[i]
+	}
+
+	n := 0
+	var last []*string
+	for rows.Next() {
+		if n == limit {
+			c, err := encodeCursor(cursor{Sort: sort, Values: last})
+			if err != nil {
+				return info, err
+			}
+			info.NextCursor = c
+			break
+		}
+
+		err := scan(rows, keyDest...)
+		if err != nil {
+			return info, fmt.Errorf("failed to scan: %w", err)
+		}
+
+		last = make([]*string, len(values))
+		for i, value := range values {
+			if value.Valid {
+				v := value.String
+				last[i] = &v
+			}
+		}
+		n++
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return info, fmt.Errorf("rows iteration error: %w", err)
+	}
+
+	return info, nil
+}
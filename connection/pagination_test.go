@@ -0,0 +1,155 @@
+package connection
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func stringPtr(s string) *string {
+	return &s
+}
+
+func TestCursorRoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		in   cursor
+	}{
+		{name: "id only", in: cursor{Sort: "", Values: []*string{stringPtr("3f2b8c1e-9d4a-4c7b-8e21-6a5f0d9b7c13")}}},
+		{name: "descending sort", in: cursor{Sort: "-createdAt", Values: []*string{
+			stringPtr("2024-03-01 12:30:00.123456"), stringPtr("3f2b8c1e-9d4a-4c7b-8e21-6a5f0d9b7c13")}}},
+		{name: "null key", in: cursor{Sort: "deadline,name", Values: []*string{
+			nil, stringPtr("Поставка труб"), stringPtr("3f2b8c1e-9d4a-4c7b-8e21-6a5f0d9b7c13")}}},
+		{name: "empty string is not null", in: cursor{Sort: "name", Values: []*string{
+			stringPtr(""), stringPtr("3f2b8c1e-9d4a-4c7b-8e21-6a5f0d9b7c13")}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			encoded, err := encodeCursor(tt.in)
+			if err != nil {
+				t.Fatalf("encodeCursor: %v", err)
+			}
+
+			got, err := decodeCursor(encoded)
+			if err != nil {
+				t.Fatalf("decodeCursor(%q): %v", encoded, err)
+			}
+			if !reflect.DeepEqual(got, tt.in) {
+				t.Errorf("decodeCursor(encodeCursor(%+v)) = %+v", tt.in, got)
+			}
+		})
+	}
+}
+
+func TestCursorValues(t *testing.T) {
+	keys := sortKeys([]SortField{{Field: "createdAt", Desc: true}}, tenderSortColumns, "tender.id")
+	values := []*string{stringPtr("2024-03-01 12:30:00"), stringPtr("3f2b8c1e-9d4a-4c7b-8e21-6a5f0d9b7c13")}
+	valid, err := encodeCursor(cursor{Sort: "-createdAt", Values: values})
+	if err != nil {
+		t.Fatalf("encodeCursor: %v", err)
+	}
+	otherSort, _ := encodeCursor(cursor{Sort: "createdAt", Values: values})
+	fewerKeys, _ := encodeCursor(cursor{Sort: "-createdAt", Values: values[1:]})
+
+	tests := []struct {
+		name    string
+		page    Page
+		want    []*string
+		wantErr bool
+	}{
+		{name: "same sort", page: Page{Cursor: valid}, want: values},
+		{name: "other sort", page: Page{Cursor: otherSort}, wantErr: true},
+		{name: "other number of keys", page: Page{Cursor: fewerKeys}, wantErr: true},
+		{name: "with offset", page: Page{Cursor: valid, Offset: 5}, wantErr: true},
+		{name: "not base64", page: Page{Cursor: "not a cursor!"}, wantErr: true},
+		{name: "not JSON", page: Page{Cursor: "bm90IGpzb24"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := cursorValues(tt.page, keys, "-createdAt")
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidCursor) {
+					t.Errorf("cursorValues error = %v, want ErrInvalidCursor", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("cursorValues: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("cursorValues = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQueryBuilderAfter(t *testing.T) {
+	tests := []struct {
+		name     string
+		fields   []SortField
+		values   []*string
+		want     string
+		wantArgs []interface{}
+	}{
+		{
+			name:     "id",
+			values:   []*string{stringPtr("id1")},
+			want:     "SELECT id, tender.id::text FROM tender WHERE (((tender.id > $1 OR tender.id IS NULL))) ORDER BY tender.id ASC NULLS LAST LIMIT $2",
+			wantArgs: []interface{}{"id1", 6},
+		},
+		{
+			name:   "ascending",
+			fields: []SortField{{Field: "name"}},
+			values: []*string{stringPtr("Поставка"), stringPtr("id1")},
+			want: "SELECT id, tender.name::text, tender.id::text FROM tender WHERE (((tender.name > $1 OR tender.name IS NULL)) OR " +
+				"(tender.name = $2 AND (tender.id > $3 OR tender.id IS NULL))) " +
+				"ORDER BY tender.name ASC NULLS LAST, tender.id ASC NULLS LAST LIMIT $4",
+			wantArgs: []interface{}{"Поставка", "Поставка", "id1", 6},
+		},
+		{
+			name:   "descending",
+			fields: []SortField{{Field: "createdAt", Desc: true}},
+			values: []*string{stringPtr("2024-03-01 12:30:00"), stringPtr("id1")},
+			want: "SELECT id, tender.created_at::text, tender.id::text FROM tender WHERE (((tender.created_at < $1 OR tender.created_at IS NULL)) OR " +
+				"(tender.created_at = $2 AND (tender.id > $3 OR tender.id IS NULL))) " +
+				"ORDER BY tender.created_at DESC NULLS LAST, tender.id ASC NULLS LAST LIMIT $4",
+			wantArgs: []interface{}{"2024-03-01 12:30:00", "2024-03-01 12:30:00", "id1", 6},
+		},
+		{
+			name:   "null key is followed only by other nulls",
+			fields: []SortField{{Field: "deadline", Desc: true}},
+			values: []*string{nil, stringPtr("id1")},
+			want: "SELECT id, tender.deadline::text, tender.id::text FROM tender WHERE " +
+				"((tender.deadline IS NULL AND (tender.id > $1 OR tender.id IS NULL))) " +
+				"ORDER BY tender.deadline DESC NULLS LAST, tender.id ASC NULLS LAST LIMIT $2",
+			wantArgs: []interface{}{"id1", 6},
+		},
+		{
+			name:   "nothing follows null keys only",
+			fields: []SortField{{Field: "deadline"}},
+			values: []*string{nil, nil},
+			want: "SELECT id, tender.deadline::text, tender.id::text FROM tender WHERE FALSE " +
+				"ORDER BY tender.deadline ASC NULLS LAST, tender.id ASC NULLS LAST LIMIT $1",
+			wantArgs: []interface{}{6},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keys := sortKeys(tt.fields, tenderSortColumns, "tender.id")
+			q := newQuery("id", "tender").After(keys, tt.values).SelectKeys(keys).OrderBy(orderTerms(keys)...).Page(6, 0)
+
+			query, args := q.Build()
+			if query != tt.want {
+				t.Errorf("query = %q, want %q", query, tt.want)
+			}
+			if !reflect.DeepEqual(args, tt.wantArgs) {
+				t.Errorf("args = %v, want %v", args, tt.wantArgs)
+			}
+
+			count, _ := q.BuildCount()
+			if count != "SELECT count(*) FROM tender" {
+				t.Errorf("count query = %q, want the keyset left out", count)
+			}
+		})
+	}
+}
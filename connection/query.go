@@ -0,0 +1,269 @@
+package connection
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// queryBuilder assembles a SELECT statement from fixed SQL fragments written with "?"
+// placeholders. Every value is passed as a bind argument; the placeholders are numbered
+// when the statement is built, so callers never splice input into the SQL text.
+type queryBuilder struct {
+	columns   string
+	from      string
+	fromArgs  []interface{}
+	where     []string
+	whereArgs []interface{}
+	orderBy   []string
+	limit     int
+	offset    int
+
+	// keyColumns and the keyset condition serve cursor pagination; they are left out of the count query.
+	keyColumns []string
+	keyset     string
+	keysetArgs []interface{}
+}
+
+func newQuery(columns string, from string, args ...interface{}) *queryBuilder {
+	return &queryBuilder{columns: columns, from: from, fromArgs: args}
+}
+
+func (q *queryBuilder) Where(condition string, args ...interface{}) *queryBuilder {
+	if strings.Count(condition, "?") != len(args) {
+		panic(fmt.Sprintf("queryBuilder: %q expects %d arguments, got %d", condition, strings.Count(condition, "?"), len(args)))
+	}
+	q.where = append(q.where, condition)
+	q.whereArgs = append(q.whereArgs, args...)
+	return q
+}
+
+// WhereIn adds "column = ANY(values)". An empty list adds no condition.
+func (q *queryBuilder) WhereIn(column string, values []string) *queryBuilder {
+	if len(values) == 0 {
+		return q
+	}
+	return q.Where(column+" = ANY(?)", pq.Array(values))
+}
+
+func (q *queryBuilder) WhereTimeRange(column string, from *time.Time, to *time.Time) *queryBuilder {
+	if from != nil {
+		q.Where(column+" >= ?", *from)
+	}
+	if to != nil {
+		q.Where(column+" <= ?", *to)
+	}
+	return q
+}
+
+func (q *queryBuilder) OrderBy(terms ...string) *queryBuilder {
+	q.orderBy = append(q.orderBy, terms...)
+	return q
+}
+
+func (q *queryBuilder) Page(limit int, offset int) *queryBuilder {
+	q.limit = limit
+	q.offset = offset
+	return q
+}
+
+// After restricts the rows to those ordered after the given key values. A nil value stands for NULL.
+func (q *queryBuilder) After(keys []sortKey, values []*string) *queryBuilder {
+	var alternatives []string
+	var args []interface{}
+	for i, key := range keys {
+		var terms []string
+		for j := 0; j < i; j++ {
+			if values[j] == nil {
+				terms = append(terms, keys[j].expr+" IS NULL")
+			} else {
+				terms = append(terms, keys[j].expr+" = ?")
+				args = append(args, *values[j])
+			}
+		}
+
+		// NULLs sort last in both directions, so nothing follows a NULL key but other NULLs.
+		if values[i] == nil {
+			continue
+		}
+		op := " > ?"
+		if key.desc {
+			op = " < ?"
+		}
+		terms = append(terms, "("+key.expr+op+" OR "+key.expr+" IS NULL)")
+		args = append(args, *values[i])
+
+		alternatives = append(alternatives, "("+strings.Join(terms, " AND ")+")")
+	}
+
+	if len(alternatives) == 0 {
+		q.keyset = "FALSE"
+	} else {
+		q.keyset = "(" + strings.Join(alternatives, " OR ") + ")"
+	}
+	q.keysetArgs = args
+	return q
+}
+
+// SelectKeys adds the text form of the sort keys to the selected columns, after the regular ones.
+func (q *queryBuilder) SelectKeys(keys []sortKey) *queryBuilder {
+	for _, key := range keys {
+		q.keyColumns = append(q.keyColumns, key.expr+"::text")
+	}
+	return q
+}
+
+func (q *queryBuilder) Build() (string, []interface{}) {
+	var sb strings.Builder
+	sb.WriteString("SELECT ")
+	sb.WriteString(q.columns)
+	if len(q.keyColumns) > 0 {
+		sb.WriteString(", ")
+		sb.WriteString(strings.Join(q.keyColumns, ", "))
+	}
+	sb.WriteString(" FROM ")
+	sb.WriteString(q.from)
+
+	where := q.where
+	args := append(append([]interface{}{}, q.fromArgs...), q.whereArgs...)
+	if q.keyset != "" {
+		where = append(append([]string{}, where...), q.keyset)
+		args = append(args, q.keysetArgs...)
+	}
+	if len(where) > 0 {
+		sb.WriteString(" WHERE ")
+		sb.WriteString(strings.Join(where, " AND "))
+	}
+	if len(q.orderBy) > 0 {
+		sb.WriteString(" ORDER BY ")
+		sb.WriteString(strings.Join(q.orderBy, ", "))
+	}
+
+	if q.limit > 0 {
+		sb.WriteString(" LIMIT ?")
+		args = append(args, q.limit)
+	}
+	if q.offset > 0 {
+		sb.WriteString(" OFFSET ?")
+		args = append(args, q.offset)
+	}
+
+	return numberPlaceholders(sb.String()), args
+}
+
+// BuildCount builds a query counting every row matching the conditions, regardless of the page.
+func (q *queryBuilder) BuildCount() (string, []interface{}) {
+	var sb strings.Builder
+	sb.WriteString("SELECT count(*) FROM ")
+	sb.WriteString(q.from)
+	if len(q.where) > 0 {
+		sb.WriteString(" WHERE ")
+		sb.WriteString(strings.Join(q.where, " AND "))
+	}
+
+	args := append(append([]interface{}{}, q.fromArgs...), q.whereArgs...)
+	return numberPlaceholders(sb.String()), args
+}
+
+func numberPlaceholders(query string) string {
+	var sb strings.Builder
+	n := 0
+	for _, c := range query {
+		if c == '?' {
+			n++
+			sb.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		sb.WriteRune(c)
+	}
+	return sb.String()
+}
+
+type SortField struct {
+	Field string
+	Desc  bool
+}
+
+var (
+	tenderSortColumns = map[string]string{
+		"name":      "tender.name",
+		"createdAt": "tender.created_at",
+		"updatedAt": "tender.updated_at",
+		"deadline":  "tender.deadline",
+	}
+
+	bidSortColumns = map[string]string{
+		"name":      "bid.name",
+		"createdAt": "bid.created_at",
+		"updatedAt": "bid.updated_at",
+	}
+)
+
+// ParseTenderSort parses a comma-separated list of tender fields, each optionally prefixed
+// with "-" for descending order, e.g. "deadline,-createdAt".
+func ParseTenderSort(value string) ([]SortField, error) {
+	return parseSort(value, tenderSortColumns)
+}
+
+func ParseBidSort(value string) ([]SortField, error) {
+	return parseSort(value, bidSortColumns)
+}
+
+func parseSort(value string, columns map[string]string) ([]SortField, error) {
+	fields := []SortField{}
+	for _, term := range strings.Split(value, ",") {
+		field := SortField{Field: strings.TrimSpace(term)}
+		if strings.HasPrefix(field.Field, "-") {
+			field.Desc = true
+			field.Field = field.Field[1:]
+		}
+		if _, ok := columns[field.Field]; !ok {
+			return nil, Invalid("invalid_sort", "unknown sort field: %s", term)
+		}
+		fields = append(fields, field)
+	}
+	return fields, nil
+}
+
+func formatSort(fields []SortField) string {
+	terms := make([]string, 0, len(fields))
+	for _, field := range fields {
+		if field.Desc {
+			terms = append(terms, "-"+field.Field)
+		} else {
+			terms = append(terms, field.Field)
+		}
+	}
+	return strings.Join(terms, ",")
+}
+
+// sortKey is an expression the rows are ordered by.
+type sortKey struct {
+	expr string
+	desc bool
+}
+
+// sortKeys turns validated sort fields into the keys of the order, ending with the id so the order is
+// stable and every row has a unique position a cursor can point to.
+func sortKeys(fields []SortField, columns map[string]string, id string) []sortKey {
+	keys := []sortKey{}
+	for _, field := range fields {
+		keys = append(keys, sortKey{expr: columns[field.Field], desc: field.Desc})
+	}
+	return append(keys, sortKey{expr: id})
+}
+
+func orderTerms(keys []sortKey) []string {
+	terms := []string{}
+	for _, key := range keys {
+		if key.desc {
+			terms = append(terms, key.expr+" DESC NULLS LAST")
+		} else {
+			terms = append(terms, key.expr+" ASC NULLS LAST")
+		}
+	}
+	return terms
+}
@@ -0,0 +1,161 @@
+package connection
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+func TestNumberPlaceholders(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{in: "SELECT 1", want: "SELECT 1"},
+		{in: "a = ?", want: "a = $1"},
+		{in: "a = ? AND b = ANY(?) LIMIT ?", want: "a = $1 AND b = ANY($2) LIMIT $3"},
+		{in: "name = ? -- имя", want: "name = $1 -- имя"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			if got := numberPlaceholders(tt.in); got != tt.want {
+				t.Errorf("numberPlaceholders(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQueryBuilderBuild(t *testing.T) {
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name      string
+		query     func() *queryBuilder
+		want      string
+		wantArgs  []interface{}
+		wantCount string
+		countArgs int
+	}{
+		{
+			name:      "no conditions",
+			query:     func() *queryBuilder { return newQuery("id", "tender") },
+			want:      "SELECT id FROM tender",
+			wantCount: "SELECT count(*) FROM tender",
+		},
+		{
+			name: "conditions are numbered after the arguments of from",
+			query: func() *queryBuilder {
+				return newQuery("id", "tender, (SELECT to_tsquery(?) AS query) q", "труба").
+					Where("tender.status = ?", "PUBLISHED").
+					WhereIn("tender.service_type", []string{"Construction", "Delivery"})
+			},
+			want: "SELECT id FROM tender, (SELECT to_tsquery($1) AS query) q " +
+				"WHERE tender.status = $2 AND tender.service_type = ANY($3)",
+			wantArgs:  []interface{}{"труба", "PUBLISHED", pq.Array([]string{"Construction", "Delivery"})},
+			wantCount: "SELECT count(*) FROM tender, (SELECT to_tsquery($1) AS query) q WHERE tender.status = $2 AND tender.service_type = ANY($3)",
+			countArgs: 3,
+		},
+		{
+			name: "empty in list and open time range add nothing",
+			query: func() *queryBuilder {
+				return newQuery("id", "bid").WhereIn("bid.status", nil).WhereTimeRange("bid.created_at", nil, nil)
+			},
+			want:      "SELECT id FROM bid",
+			wantCount: "SELECT count(*) FROM bid",
+		},
+		{
+			name: "time range",
+			query: func() *queryBuilder {
+				return newQuery("id", "bid").WhereTimeRange("bid.created_at", &from, &to)
+			},
+			want:      "SELECT id FROM bid WHERE bid.created_at >= $1 AND bid.created_at <= $2",
+			wantArgs:  []interface{}{from, to},
+			wantCount: "SELECT count(*) FROM bid WHERE bid.created_at >= $1 AND bid.created_at <= $2",
+			countArgs: 2,
+		},
+		{
+			name: "order and page are left out of the count",
+			query: func() *queryBuilder {
+				return newQuery("id", "tender").Where("tender.organization_id::text = ?", "org").
+					OrderBy("tender.name ASC NULLS LAST", "tender.id ASC NULLS LAST").Page(6, 10)
+			},
+			want: "SELECT id FROM tender WHERE tender.organization_id::text = $1 " +
+				"ORDER BY tender.name ASC NULLS LAST, tender.id ASC NULLS LAST LIMIT $2 OFFSET $3",
+			wantArgs:  []interface{}{"org", 6, 10},
+			wantCount: "SELECT count(*) FROM tender WHERE tender.organization_id::text = $1",
+			countArgs: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, args := tt.query().Build()
+			if query != tt.want {
+				t.Errorf("Build query = %q, want %q", query, tt.want)
+			}
+			if len(args) != len(tt.wantArgs) || len(args) > 0 && !reflect.DeepEqual(args, tt.wantArgs) {
+				t.Errorf("Build args = %v, want %v", args, tt.wantArgs)
+			}
+
+			count, countArgs := tt.query().BuildCount()
+			if count != tt.wantCount {
+				t.Errorf("BuildCount query = %q, want %q", count, tt.wantCount)
+			}
+			if len(countArgs) != tt.countArgs || len(countArgs) > 0 && !reflect.DeepEqual(countArgs, tt.wantArgs[:tt.countArgs]) {
+				t.Errorf("BuildCount args = %v, want %v", countArgs, tt.wantArgs[:tt.countArgs])
+			}
+		})
+	}
+}
+
+func TestQueryBuilderWhereArguments(t *testing.T) {
+	tests := []struct {
+		name      string
+		condition string
+		args      []interface{}
+		wantPanic bool
+	}{
+		{name: "matching", condition: "a = ? AND b = ?", args: []interface{}{1, 2}},
+		{name: "none", condition: "a IS NULL"},
+		{name: "missing argument", condition: "a = ? AND b = ?", args: []interface{}{1}, wantPanic: true},
+		{name: "extra argument", condition: "a = ?", args: []interface{}{1, 2}, wantPanic: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func() {
+				if r := recover(); (r != nil) != tt.wantPanic {
+					t.Errorf("Where(%q) panic = %v, wantPanic %v", tt.condition, r, tt.wantPanic)
+				}
+			}()
+			newQuery("id", "tender").Where(tt.condition, tt.args...)
+		})
+	}
+}
+
+func TestParseSort(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    []SortField
+		wantErr bool
+	}{
+		{in: "name", want: []SortField{{Field: "name"}}},
+		{in: "deadline,-createdAt", want: []SortField{{Field: "deadline"}, {Field: "createdAt", Desc: true}}},
+		{in: " -name , updatedAt", want: []SortField{{Field: "name", Desc: true}, {Field: "updatedAt"}}},
+		{in: "status", wantErr: true},
+		{in: "name,", wantErr: true},
+		{in: "--name", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParseTenderSort(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseTenderSort(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) && !tt.wantErr {
+				t.Errorf("ParseTenderSort(%q) = %+v, want %+v", tt.in, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,114 @@
+package connection
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/noctusha/tender/models"
+)
+
+type questionScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanQuestion(row questionScanner) (models.TenderQuestion, error) {
+	var (
+		question   models.TenderQuestion
+		answer     sql.NullString
+		answeredBy sql.NullString
+		answeredAt sql.NullTime
+	)
+
+	err := row.Scan(&question.ID, &question.TenderID, &question.OrganizationID, &question.AuthorUsername, &question.Question,
+		&answer, &answeredBy, &question.Public, &question.CreatedAt, &answeredAt)
+	if err != nil {
+		return question, err
+	}
+
+	question.Answer = answer.String
+	question.AnsweredBy = answeredBy.String
+	if answeredAt.Valid {
+		question.AnsweredAt = answeredAt.Time.Format(time.RFC3339Nano)
+	}
+	return question, nil
+}
+
+func (r *Repository) NewQuestion(question models.TenderQuestion) error {
+	_, err := r.db.Exec(`INSERT INTO tender_question (id, tender_id, organization_id, author_username, question) VALUES ($1, $2, $3, $4, $5)`,
+		question.ID, question.TenderID, question.OrganizationID, question.AuthorUsername, question.Question)
+	if err != nil {
+		return fmt.Errorf("failed to insert data into tender_question: %w", err)
+	}
+	return nil
+}
+
+func (r *Repository) QuestionsByTenderID(tenderID string) ([]models.TenderQuestion, error) {
+	questions := []models.TenderQuestion{}
+
+	rows, err := r.db.Query(`SELECT id, tender_id, organization_id, author_username, question, answer, answered_by, is_public, created_at, answered_at
+		FROM tender_question WHERE tender_id = $1 ORDER BY created_at`, tenderID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to select data from tender_question: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		question, err := scanQuestion(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan: %w", err)
+		}
+		questions = append(questions, question)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, fmt.Errorf("rows iteration error: %w", err)
+	}
+
+	return questions, nil
+}
+
+func (r *Repository) GetQuestionByID(questionID uuid.UUID) (*models.TenderQuestion, bool, error) {
+	question, err := scanQuestion(r.db.QueryRow(`SELECT id, tender_id, organization_id, author_username, question, answer, answered_by, is_public, created_at, answered_at
+		FROM tender_question WHERE id = $1`, questionID.String()))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, false, nil
+		}
+		return nil, false, fmt.Errorf("failed to select data from tender_question: %w", err)
+	}
+	return &question, true, nil
+}
+
+func (r *Repository) AnswerQuestion(question *models.TenderQuestion, events ...models.Event) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var answeredAt time.Time
+	err = tx.QueryRow(`UPDATE tender_question SET answer = $1, answered_by = $2, is_public = $3, answered_at = CURRENT_TIMESTAMP
+		WHERE id = $4 RETURNING answered_at`,
+		question.Answer, question.AnsweredBy, question.Public, question.ID).Scan(&answeredAt)
+	if err != nil {
+		return fmt.Errorf("failed to update tender_question: %w", err)
+	}
+
+	question.AnsweredAt = answeredAt.Format(time.RFC3339Nano)
+
+	err = insertEvents(tx, events)
+	if err != nil {
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
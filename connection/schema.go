@@ -58,6 +58,26 @@ func (r *Repository) InitSchema() error {
 		return fmt.Errorf("failed to create organization_responsible table: %w", err)
 	}
 
+	_, err = r.db.Exec(`ALTER TABLE employee ADD COLUMN IF NOT EXISTS is_admin BOOLEAN NOT NULL DEFAULT FALSE;`)
+	if err != nil {
+		return fmt.Errorf("failed to add is_admin to employee table: %w", err)
+	}
+
+	_, err = r.db.Exec(`
+	CREATE TABLE IF NOT EXISTS service_type (
+		id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+		name VARCHAR(100) UNIQUE NOT NULL,
+		description TEXT,
+		parent_id UUID REFERENCES service_type(id) ON DELETE RESTRICT,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+	INSERT INTO service_type (name) VALUES ('Construction'), ('Delivery'), ('Manufacture') ON CONFLICT (name) DO NOTHING;
+`)
+	if err != nil {
+		return fmt.Errorf("failed to create service_type table: %w", err)
+	}
+
 	_, err = r.db.Exec(`
 	CREATE TABLE IF NOT EXISTS tender (
 		id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
@@ -75,6 +95,62 @@ func (r *Repository) InitSchema() error {
 		return fmt.Errorf("failed to create tender table: %w", err)
 	}
 
+	_, err = r.db.Exec(`ALTER TABLE tender ADD COLUMN IF NOT EXISTS visibility VARCHAR(20) NOT NULL DEFAULT 'PUBLIC';`)
+	if err != nil {
+		return fmt.Errorf("failed to add visibility to tender table: %w", err)
+	}
+
+	_, err = r.db.Exec(`ALTER TABLE tender ADD COLUMN IF NOT EXISTS deadline TIMESTAMP;`)
+	if err != nil {
+		return fmt.Errorf("failed to add deadline to tender table: %w", err)
+	}
+
+	_, err = r.db.Exec(`
+	ALTER TABLE tender ADD COLUMN IF NOT EXISTS search_vector tsvector GENERATED ALWAYS AS (
+		setweight(to_tsvector('russian', coalesce(name, '')), 'A') ||
+		setweight(to_tsvector('english', coalesce(name, '')), 'A') ||
+		setweight(to_tsvector('russian', coalesce(description, '')), 'B') ||
+		setweight(to_tsvector('english', coalesce(description, '')), 'B')
+	) STORED;
+	CREATE INDEX IF NOT EXISTS tender_search_idx ON tender USING GIN (search_vector);
+`)
+	if err != nil {
+		return fmt.Errorf("failed to add search index to tender table: %w", err)
+	}
+
+	_, err = r.db.Exec(`
+	CREATE TABLE IF NOT EXISTS tender_invitation (
+		id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+		tender_id UUID REFERENCES tender(id) ON DELETE CASCADE,
+		organization_id UUID REFERENCES organization(id) ON DELETE CASCADE,
+		status VARCHAR(10) NOT NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		UNIQUE (tender_id, organization_id)
+	);
+`)
+	if err != nil {
+		return fmt.Errorf("failed to create tender_invitation table: %w", err)
+	}
+
+	_, err = r.db.Exec(`
+	CREATE TABLE IF NOT EXISTS tender_question (
+		id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+		tender_id UUID REFERENCES tender(id) ON DELETE CASCADE,
+		organization_id UUID REFERENCES organization(id) ON DELETE CASCADE,
+		author_username VARCHAR(50) NOT NULL,
+		question TEXT NOT NULL,
+		answer TEXT,
+		answered_by VARCHAR(50),
+		is_public BOOLEAN NOT NULL DEFAULT FALSE,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		answered_at TIMESTAMP
+	);
+`)
+	if err != nil {
+		return fmt.Errorf("failed to create tender_question table: %w", err)
+	}
+
 	_, err = r.db.Exec(`
 	CREATE TABLE IF NOT EXISTS tender_version (
 		id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
@@ -87,6 +163,45 @@ func (r *Repository) InitSchema() error {
 		return fmt.Errorf("failed to create tender_version table: %w", err)
 	}
 
+	_, err = r.db.Exec(`ALTER TABLE tender_version ADD COLUMN IF NOT EXISTS deadline TIMESTAMP;`)
+	if err != nil {
+		return fmt.Errorf("failed to add deadline to tender_version table: %w", err)
+	}
+
+	_, err = r.db.Exec(`
+	CREATE TABLE IF NOT EXISTS tender_amendment (
+		id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+		tender_id UUID REFERENCES tender(id) ON DELETE CASCADE,
+		tender_version_id UUID REFERENCES tender_version(id) ON DELETE CASCADE,
+		reason TEXT,
+		changed_fields TEXT[] NOT NULL,
+		affected_bids INTEGER NOT NULL DEFAULT 0,
+		created_by VARCHAR(50) NOT NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+`)
+	if err != nil {
+		return fmt.Errorf("failed to create tender_amendment table: %w", err)
+	}
+
+	_, err = r.db.Exec(`
+	CREATE TABLE IF NOT EXISTS lot (
+		id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+		tender_id UUID REFERENCES tender(id) ON DELETE CASCADE,
+		description TEXT NOT NULL,
+		quantity INTEGER NOT NULL,
+		budget NUMERIC(15, 2) NOT NULL,
+		service_type VARCHAR(100),
+		status VARCHAR(10) NOT NULL,
+		winner_bid_id UUID,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+`)
+	if err != nil {
+		return fmt.Errorf("failed to create lot table: %w", err)
+	}
+
 	_, err = r.db.Exec(`
 	CREATE TABLE IF NOT EXISTS bid (
 		id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
@@ -105,10 +220,20 @@ func (r *Repository) InitSchema() error {
 		return fmt.Errorf("failed to create bid table: %w", err)
 	}
 
+	_, err = r.db.Exec(`ALTER TABLE bid ADD COLUMN IF NOT EXISTS lot_id UUID REFERENCES lot(id) ON DELETE CASCADE;`)
+	if err != nil {
+		return fmt.Errorf("failed to add lot_id to bid table: %w", err)
+	}
+
+	_, err = r.db.Exec(`ALTER TABLE bid ADD COLUMN IF NOT EXISTS needs_reconfirmation BOOLEAN NOT NULL DEFAULT FALSE;`)
+	if err != nil {
+		return fmt.Errorf("failed to add needs_reconfirmation to bid table: %w", err)
+	}
+
 	_, err = r.db.Exec(`
 	CREATE TABLE IF NOT EXISTS bid_version (
 		id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
-		bid_id UUID REFERENCES tender(id) ON DELETE CASCADE,
+		bid_id UUID REFERENCES bid(id) ON DELETE CASCADE,
 		name VARCHAR(100) NOT NULL,
 		description TEXT
 	);
@@ -117,5 +242,261 @@ func (r *Repository) InitSchema() error {
 		return fmt.Errorf("failed to create bid_version table: %w", err)
 	}
 
+	_, err = r.db.Exec(`
+		DO $$ BEGIN
+			IF EXISTS (SELECT 1 FROM pg_constraint WHERE conname = 'bid_version_bid_id_fkey' AND confrelid = 'tender'::regclass) THEN
+				ALTER TABLE bid_version DROP CONSTRAINT bid_version_bid_id_fkey;
+				ALTER TABLE bid_version ADD CONSTRAINT bid_version_bid_id_fkey FOREIGN KEY (bid_id) REFERENCES bid(id) ON DELETE CASCADE;
+			END IF;
+		END $$;
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to fix bid_version foreign key: %w", err)
+	}
+
+	_, err = r.db.Exec(`
+	CREATE TABLE IF NOT EXISTS bid_status_history (
+		id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+		bid_id UUID REFERENCES bid(id) ON DELETE CASCADE,
+		status VARCHAR(10) NOT NULL,
+		reason TEXT,
+		changed_by VARCHAR(50) NOT NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+`)
+	if err != nil {
+		return fmt.Errorf("failed to create bid_status_history table: %w", err)
+	}
+
+	_, err = r.db.Exec(`
+	CREATE TABLE IF NOT EXISTS attachment (
+		id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+		entity_type VARCHAR(10) NOT NULL,
+		entity_id UUID NOT NULL,
+		file_name VARCHAR(255) NOT NULL,
+		content_type VARCHAR(100) NOT NULL,
+		size BIGINT NOT NULL,
+		sha256 CHAR(64) NOT NULL,
+		storage_key VARCHAR(255) NOT NULL,
+		uploaded_by VARCHAR(50) NOT NULL,
+		detached BOOLEAN NOT NULL DEFAULT FALSE,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+	CREATE INDEX IF NOT EXISTS attachment_entity_idx ON attachment (entity_type, entity_id);
+	ALTER TABLE tender_version ADD COLUMN IF NOT EXISTS attachment_ids UUID[] NOT NULL DEFAULT '{}';
+	ALTER TABLE bid_version ADD COLUMN IF NOT EXISTS attachment_ids UUID[] NOT NULL DEFAULT '{}';
+`)
+	if err != nil {
+		return fmt.Errorf("failed to create attachment table: %w", err)
+	}
+
+	_, err = r.db.Exec(`
+	CREATE TABLE IF NOT EXISTS webhook_subscription (
+		id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+		organization_id UUID REFERENCES organization(id) ON DELETE CASCADE,
+		url TEXT NOT NULL,
+		event_types TEXT[] NOT NULL,
+		secret VARCHAR(64) NOT NULL,
+		active BOOLEAN NOT NULL DEFAULT TRUE,
+		created_by VARCHAR(50) NOT NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+	CREATE TABLE IF NOT EXISTS webhook_delivery (
+		id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+		subscription_id UUID REFERENCES webhook_subscription(id) ON DELETE CASCADE,
+		event_id UUID NOT NULL,
+		event_type VARCHAR(50) NOT NULL,
+		payload JSONB NOT NULL,
+		status VARCHAR(10) NOT NULL,
+		attempts INT NOT NULL DEFAULT 0,
+		last_status_code INT,
+		last_error TEXT,
+		next_attempt_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		delivered_at TIMESTAMP,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+	CREATE INDEX IF NOT EXISTS webhook_delivery_pending_idx ON webhook_delivery (next_attempt_at) WHERE status = 'PENDING';
+	CREATE INDEX IF NOT EXISTS webhook_delivery_subscription_idx ON webhook_delivery (subscription_id, created_at);
+	CREATE UNIQUE INDEX IF NOT EXISTS webhook_delivery_event_idx ON webhook_delivery (subscription_id, event_id);
+`)
+	if err != nil {
+		return fmt.Errorf("failed to create webhook tables: %w", err)
+	}
+
+	_, err = r.db.Exec(`
+	CREATE TABLE IF NOT EXISTS outbox_event (
+		id UUID PRIMARY KEY,
+		sequence BIGSERIAL UNIQUE,
+		event_type VARCHAR(50) NOT NULL,
+		organization_ids UUID[] NOT NULL DEFAULT '{}',
+		payload JSONB NOT NULL,
+		published_sinks TEXT[] NOT NULL DEFAULT '{}',
+		attempts INT NOT NULL DEFAULT 0,
+		last_error TEXT,
+		next_attempt_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		published_at TIMESTAMP,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+	CREATE INDEX IF NOT EXISTS outbox_event_pending_idx ON outbox_event (next_attempt_at) WHERE published_at IS NULL;
+`)
+	if err != nil {
+		return fmt.Errorf("failed to create outbox_event table: %w", err)
+	}
+
+	_, err = r.db.Exec(`
+	ALTER TABLE outbox_event ADD COLUMN IF NOT EXISTS tender_id UUID;
+	ALTER TABLE outbox_event ADD COLUMN IF NOT EXISTS restricted BOOLEAN NOT NULL DEFAULT FALSE;
+	CREATE INDEX IF NOT EXISTS outbox_event_tender_idx ON outbox_event (tender_id, sequence) WHERE tender_id IS NOT NULL;
+`)
+	if err != nil {
+		return fmt.Errorf("failed to add tender columns to outbox_event: %w", err)
+	}
+
+	_, err = r.db.Exec(`
+	ALTER TABLE outbox_event ADD COLUMN IF NOT EXISTS xid xid8 NOT NULL DEFAULT pg_current_xact_id();
+	CREATE INDEX IF NOT EXISTS outbox_event_stream_idx ON outbox_event (tender_id, xid, sequence) WHERE tender_id IS NOT NULL;
+`)
+	if err != nil {
+		return fmt.Errorf("failed to add xid to outbox_event: %w", err)
+	}
+
+	_, err = r.db.Exec(`ALTER TABLE employee ADD COLUMN IF NOT EXISTS email VARCHAR(255);`)
+	if err != nil {
+		return fmt.Errorf("failed to add email to employee table: %w", err)
+	}
+
+	_, err = r.db.Exec(`
+	CREATE TABLE IF NOT EXISTS notification_preference (
+		user_id UUID PRIMARY KEY REFERENCES employee(id) ON DELETE CASCADE,
+		email_enabled BOOLEAN NOT NULL DEFAULT TRUE,
+		language VARCHAR(2) NOT NULL DEFAULT 'ru',
+		muted_events TEXT[] NOT NULL DEFAULT '{}',
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+`)
+	if err != nil {
+		return fmt.Errorf("failed to create notification_preference table: %w", err)
+	}
+
+	_, err = r.db.Exec(`
+	CREATE TABLE IF NOT EXISTS notification (
+		id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+		user_id UUID NOT NULL REFERENCES employee(id) ON DELETE CASCADE,
+		kind VARCHAR(50) NOT NULL,
+		dedup_key VARCHAR(200) NOT NULL,
+		tender_id UUID REFERENCES tender(id) ON DELETE CASCADE,
+		bid_id UUID REFERENCES bid(id) ON DELETE CASCADE,
+		data JSONB NOT NULL DEFAULT '{}',
+		read_at TIMESTAMP,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+	CREATE UNIQUE INDEX IF NOT EXISTS notification_dedup_idx ON notification (user_id, dedup_key);
+	CREATE INDEX IF NOT EXISTS notification_user_idx ON notification (user_id, created_at);
+	CREATE INDEX IF NOT EXISTS notification_unread_idx ON notification (user_id) WHERE read_at IS NULL;
+`)
+	if err != nil {
+		return fmt.Errorf("failed to create notification table: %w", err)
+	}
+
+	_, err = r.db.Exec(`
+	CREATE TABLE IF NOT EXISTS email_delivery (
+		event_id UUID NOT NULL,
+		username VARCHAR(50) NOT NULL,
+		failure TEXT,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (event_id, username)
+	);
+`)
+	if err != nil {
+		return fmt.Errorf("failed to create email_delivery table: %w", err)
+	}
+
+	_, err = r.db.Exec(`ALTER TABLE organization_responsible ADD COLUMN IF NOT EXISTS is_admin BOOLEAN NOT NULL DEFAULT FALSE;`)
+	if err != nil {
+		return fmt.Errorf("failed to add is_admin to organization_responsible table: %w", err)
+	}
+
+	_, err = r.db.Exec(`
+	CREATE TABLE IF NOT EXISTS audit_event (
+		id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+		sequence BIGSERIAL UNIQUE,
+		actor VARCHAR(50) NOT NULL,
+		organization_ids UUID[] NOT NULL DEFAULT '{}',
+		action VARCHAR(50) NOT NULL,
+		entity_type VARCHAR(30) NOT NULL,
+		entity_id UUID NOT NULL,
+		before JSONB,
+		after JSONB,
+		request_id VARCHAR(100),
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+	CREATE INDEX IF NOT EXISTS audit_event_entity_idx ON audit_event (entity_type, entity_id);
+	CREATE INDEX IF NOT EXISTS audit_event_actor_idx ON audit_event (actor, created_at);
+	CREATE INDEX IF NOT EXISTS audit_event_organization_idx ON audit_event USING GIN (organization_ids);
+
+	ALTER TABLE audit_event ADD COLUMN IF NOT EXISTS prev_hash CHAR(64);
+	ALTER TABLE audit_event ADD COLUMN IF NOT EXISTS hash CHAR(64);
+
+	CREATE OR REPLACE FUNCTION append_only() RETURNS trigger AS $$
+	BEGIN
+		RAISE EXCEPTION '% is append-only', TG_TABLE_NAME;
+	END;
+	$$ LANGUAGE plpgsql;
+
+	DROP TRIGGER IF EXISTS audit_event_no_change ON audit_event;
+	CREATE TRIGGER audit_event_no_change BEFORE UPDATE OR DELETE ON audit_event
+		FOR EACH ROW EXECUTE FUNCTION append_only();
+	DROP TRIGGER IF EXISTS audit_event_no_truncate ON audit_event;
+	CREATE TRIGGER audit_event_no_truncate BEFORE TRUNCATE ON audit_event
+		FOR EACH STATEMENT EXECUTE FUNCTION append_only();
+	DROP FUNCTION IF EXISTS audit_event_append_only();
+`)
+	if err != nil {
+		return fmt.Errorf("failed to create audit_event table: %w", err)
+	}
+
+	_, err = r.db.Exec(`
+	CREATE TABLE IF NOT EXISTS bid_seal (
+		id UUID PRIMARY KEY,
+		sequence BIGSERIAL UNIQUE,
+		tender_id UUID NOT NULL,
+		bid_id UUID NOT NULL,
+		action VARCHAR(20) NOT NULL,
+		content TEXT NOT NULL,
+		prev_hash CHAR(64) NOT NULL,
+		hash CHAR(64) NOT NULL,
+		created_at TIMESTAMP NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS bid_seal_tender_idx ON bid_seal (tender_id, sequence);
+	CREATE INDEX IF NOT EXISTS bid_seal_bid_idx ON bid_seal (bid_id, sequence);
+
+	DROP TRIGGER IF EXISTS bid_seal_no_change ON bid_seal;
+	CREATE TRIGGER bid_seal_no_change BEFORE UPDATE OR DELETE ON bid_seal
+		FOR EACH ROW EXECUTE FUNCTION append_only();
+	DROP TRIGGER IF EXISTS bid_seal_no_truncate ON bid_seal;
+	CREATE TRIGGER bid_seal_no_truncate BEFORE TRUNCATE ON bid_seal
+		FOR EACH STATEMENT EXECUTE FUNCTION append_only();
+`)
+	if err != nil {
+		return fmt.Errorf("failed to create bid_seal table: %w", err)
+	}
+
+	_, err = r.db.Exec(`
+	ALTER TABLE employee ADD COLUMN IF NOT EXISTS signing_key TEXT;
+	ALTER TABLE bid ADD COLUMN IF NOT EXISTS signature TEXT;
+	ALTER TABLE bid ADD COLUMN IF NOT EXISTS signed_by UUID;
+	ALTER TABLE bid ADD COLUMN IF NOT EXISTS signing_key TEXT;
+	ALTER TABLE bid_version ADD COLUMN IF NOT EXISTS signature TEXT;
+	ALTER TABLE bid_version ADD COLUMN IF NOT EXISTS signed_by UUID;
+	ALTER TABLE bid_version ADD COLUMN IF NOT EXISTS signing_key TEXT;
+	ALTER TABLE bid ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 1;
+	ALTER TABLE bid ADD COLUMN IF NOT EXISTS signed_version INTEGER;
+	ALTER TABLE bid_version ADD COLUMN IF NOT EXISTS signed_version INTEGER;
+`)
+	if err != nil {
+		return fmt.Errorf("failed to add bid signature columns: %w", err)
+	}
+
 	return nil
 }
@@ -0,0 +1,74 @@
+package connection
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/noctusha/tender/models"
+)
+
+type TenderSearchFilter struct {
+	TenderFilter
+	Query string
+	// ViewerOrganizationID is the organization of the user searching. It sees published tenders
+	// visible to it and its own tenders in any status.
+	ViewerOrganizationID string
+}
+
+// searchSortColumns extends the tender sort fields with the relevance of a search result.
+var searchSortColumns = func() map[string]string {
+	columns := map[string]string{"rank": "ts_rank_cd(tender.search_vector, q.query)"}
+	for field, column := range tenderSortColumns {
+		columns[field] = column
+	}
+	return columns
+}()
+
+// ParseSearchSort parses the sort of search results: the tender fields and "rank", the relevance.
+func ParseSearchSort(value string) ([]SortField, error) {
+	return parseSort(value, searchSortColumns)
+}
+
+// SearchTenders runs a full-text search over tender names and descriptions. The query is parsed
+// with both Russian and English stemming, results are ordered by relevance unless another sort
+// is requested and come with highlighted fragments.
+func (r *Repository) SearchTenders(filter TenderSearchFilter) ([]models.TenderSearchResult, PageInfo, error) {
+	results := []models.TenderSearchResult{}
+
+	q := newQuery(tenderColumns+`,
+			ts_rank_cd(tender.search_vector, q.query) AS rank,
+			ts_headline('russian', tender.name, q.query, 'HighlightAll=true'),
+			ts_headline('russian', coalesce(tender.description, ''), q.query, 'MaxFragments=2, MaxWords=25, MinWords=8')`,
+		`tender, (SELECT websearch_to_tsquery('russian', ?) || websearch_to_tsquery('english', ?) AS query) q`,
+		filter.Query, filter.Query).
+		Where("tender.search_vector @@ q.query").
+		Where("((tender.status = 'PUBLISHED' AND "+tenderVisibleTo+") OR tender.organization_id::text = ?)",
+			filter.ViewerOrganizationID, filter.ViewerOrganizationID, filter.ViewerOrganizationID)
+	filter.apply(q)
+
+	sort := filter.Sort
+	if len(sort) == 0 {
+		sort = []SortField{{Field: "rank", Desc: true}}
+	}
+
+	info, err := r.paginate(q, sortKeys(sort, searchSortColumns, "tender.id"), formatSort(sort), filter.Page,
+		func(rows *tracedRows, keyDest ...interface{}) error {
+			result := models.TenderSearchResult{}
+			err := rows.Scan(append([]interface{}{&result.ID, &result.Name, &result.Description, &result.ServiceType, &result.Status,
+				&result.OrganizationID, &result.CreatorUserName, &result.Visibility, &result.Deadline, &result.Rank,
+				&result.NameHighlight, &result.Snippet}, keyDest...)...)
+			if err != nil {
+				return err
+			}
+			results = append(results, result)
+			return nil
+		})
+	if errors.Is(err, ErrInvalidCursor) {
+		return nil, info, err
+	}
+	if err != nil {
+		return nil, info, fmt.Errorf("failed to search tender: %w", err)
+	}
+
+	return results, info, nil
+}
@@ -0,0 +1,185 @@
+package connection
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+
+	"github.com/noctusha/tender/models"
+)
+
+// serviceTypeWithChildren selects the names of the given service types and all their descendants.
+// It takes an array of names.
+const serviceTypeWithChildren = `(WITH RECURSIVE category AS (
+		SELECT id, name FROM service_type WHERE name = ANY(?)
+		UNION
+		SELECT service_type.id, service_type.name FROM service_type JOIN category ON service_type.parent_id = category.id
+	) SELECT name FROM category)`
+
+func (r *Repository) IsAdmin(username string) (bool, error) {
+	var admin bool
+	err := r.db.QueryRow(`SELECT EXISTS (SELECT 1 FROM employee WHERE username = $1 AND is_admin)`, username).Scan(&admin)
+	if err != nil {
+		return false, fmt.Errorf("failed to check admin rights: %w", err)
+	}
+	return admin, nil
+}
+
+func (r *Repository) ServiceTypesList() ([]models.ServiceType, error) {
+	serviceTypes := []models.ServiceType{}
+
+	rows, err := r.db.Query(`SELECT id, name, COALESCE(description, ''), COALESCE(parent_id::text, ''), created_at, updated_at
+		FROM service_type ORDER BY name`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to select data from service_type: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		serviceType := models.ServiceType{}
+		err := rows.Scan(&serviceType.ID, &serviceType.Name, &serviceType.Description, &serviceType.ParentID, &serviceType.CreatedAt, &serviceType.UpdatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan: %w", err)
+		}
+		serviceTypes = append(serviceTypes, serviceType)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, fmt.Errorf("rows iteration error: %w", err)
+	}
+
+	return serviceTypes, nil
+}
+
+func (r *Repository) GetServiceTypeByID(serviceTypeID uuid.UUID) (*models.ServiceType, bool, error) {
+	var serviceType models.ServiceType
+	err := r.db.QueryRow(`SELECT id, name, COALESCE(description, ''), COALESCE(parent_id::text, ''), created_at, updated_at
+		FROM service_type WHERE id = $1`, serviceTypeID.String()).
+		Scan(&serviceType.ID, &serviceType.Name, &serviceType.Description, &serviceType.ParentID, &serviceType.CreatedAt, &serviceType.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, false, nil
+		}
+		return nil, false, fmt.Errorf("failed to select data from service_type: %w", err)
+	}
+	return &serviceType, true, nil
+}
+
+// UnknownServiceTypes returns the names that are not in the registry.
+func (r *Repository) UnknownServiceTypes(names []string) ([]string, error) {
+	unknown := []string{}
+	if len(names) == 0 {
+		return unknown, nil
+	}
+
+	rows, err := r.db.Query(`SELECT DISTINCT requested.name FROM unnest($1::text[]) AS requested(name)
+		WHERE NOT EXISTS (SELECT 1 FROM service_type WHERE service_type.name = requested.name)`, pq.Array(names))
+	if err != nil {
+		return nil, fmt.Errorf("failed to check service types: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, fmt.Errorf("failed to scan: %w", err)
+		}
+		unknown = append(unknown, name)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, fmt.Errorf("rows iteration error: %w", err)
+	}
+
+	return unknown, nil
+}
+
+// IsServiceTypeDescendant reports whether serviceTypeID is ancestorID itself or lies in its subtree.
+func (r *Repository) IsServiceTypeDescendant(serviceTypeID string, ancestorID string) (bool, error) {
+	var descendant bool
+	err := r.db.QueryRow(`WITH RECURSIVE subtree AS (
+			SELECT id FROM service_type WHERE id = $1
+			UNION
+			SELECT service_type.id FROM service_type JOIN subtree ON service_type.parent_id = subtree.id
+		) SELECT EXISTS (SELECT 1 FROM subtree WHERE id = $2)`, ancestorID, serviceTypeID).Scan(&descendant)
+	if err != nil {
+		return false, fmt.Errorf("failed to check service type tree: %w", err)
+	}
+	return descendant, nil
+}
+
+// ServiceTypeInUse reports whether the service type has child categories or is used by a tender or lot.
+func (r *Repository) ServiceTypeInUse(serviceType models.ServiceType) (bool, error) {
+	var used bool
+	err := r.db.QueryRow(`SELECT EXISTS (SELECT 1 FROM service_type WHERE parent_id = $1)
+		OR EXISTS (SELECT 1 FROM tender WHERE service_type = $2)
+		OR EXISTS (SELECT 1 FROM lot WHERE service_type = $2)`, serviceType.ID, serviceType.Name).Scan(&used)
+	if err != nil {
+		return false, fmt.Errorf("failed to check service type usage: %w", err)
+	}
+	return used, nil
+}
+
+func (r *Repository) NewServiceType(serviceType *models.ServiceType) error {
+	err := r.db.QueryRow(`INSERT INTO service_type (id, name, description, parent_id) VALUES ($1, $2, $3, NULLIF($4, '')::uuid)
+		RETURNING created_at, updated_at`,
+		serviceType.ID, serviceType.Name, serviceType.Description, serviceType.ParentID).Scan(&serviceType.CreatedAt, &serviceType.UpdatedAt)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return Conflict("service_type_exists", "service type %s already exists", serviceType.Name)
+		}
+		return fmt.Errorf("failed to insert data into service_type: %w", err)
+	}
+	return nil
+}
+
+// UpdateServiceType saves the service type. Tenders and lots store the service type by name,
+// so a rename is carried over to them in the same transaction.
+func (r *Repository) UpdateServiceType(serviceType *models.ServiceType, previousName string) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRow(`UPDATE service_type SET name = $1, description = $2, parent_id = NULLIF($3, '')::uuid, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $4 RETURNING updated_at`,
+		serviceType.Name, serviceType.Description, serviceType.ParentID, serviceType.ID).Scan(&serviceType.UpdatedAt)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return Conflict("service_type_exists", "service type %s already exists", serviceType.Name)
+		}
+		return fmt.Errorf("failed to update service type: %w", err)
+	}
+
+	if serviceType.Name != previousName {
+		_, err = tx.Exec(`UPDATE tender SET service_type = $1 WHERE service_type = $2`, serviceType.Name, previousName)
+		if err != nil {
+			return fmt.Errorf("failed to rename tender service type: %w", err)
+		}
+
+		_, err = tx.Exec(`UPDATE lot SET service_type = $1 WHERE service_type = $2`, serviceType.Name, previousName)
+		if err != nil {
+			return fmt.Errorf("failed to rename lot service type: %w", err)
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+func (r *Repository) DeleteServiceType(serviceTypeID string) error {
+	_, err := r.db.Exec(`DELETE FROM service_type WHERE id = $1`, serviceTypeID)
+	if err != nil {
+		return fmt.Errorf("failed to delete service type: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package connection
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/noctusha/tender/models"
+)
+
+// SetSigningKey registers the Ed25519 public key the user signs bids with. An empty key removes it.
+func (r *Repository) SetSigningKey(userId string, publicKey string) error {
+	_, err := r.db.Exec(`UPDATE employee SET signing_key = NULLIF($1, ''), updated_at = CURRENT_TIMESTAMP WHERE id = $2`, publicKey, userId)
+	if err != nil {
+		return fmt.Errorf("failed to update signing key: %w", err)
+	}
+	return nil
+}
+
+// SigningKey returns the public key registered by the user, empty if there is none.
+func (r *Repository) SigningKey(userId string) (string, error) {
+	var publicKey string
+	err := r.db.QueryRow(`SELECT COALESCE(signing_key, '') FROM employee WHERE id = $1`, userId).Scan(&publicKey)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", nil
+		}
+		return "", fmt.Errorf("failed to select signing key: %w", err)
+	}
+	return publicKey, nil
+}
+
+// BidSignature returns the signature of the current version of a bid.
+func (r *Repository) BidSignature(bidID string) (*models.BidSignature, bool, error) {
+	var (
+		signature, signedBy, signingKey sql.NullString
+		signedVersion                   sql.NullInt64
+	)
+	err := r.db.QueryRow(`SELECT signature, signed_by, signing_key, signed_version FROM bid WHERE id = $1`, bidID).
+		Scan(&signature, &signedBy, &signingKey, &signedVersion)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, false, nil
+		}
+		return nil, false, fmt.Errorf("failed to select data from bid: %w", err)
+	}
+
+	bidSignature := scanBidSignature(signature, signedBy, signingKey, signedVersion)
+	return bidSignature, bidSignature != nil, nil
+}
+
+func scanBidSignature(signature, signedBy, signingKey sql.NullString, signedVersion sql.NullInt64) *models.BidSignature {
+	if !signature.Valid {
+		return nil
+	}
+	return &models.BidSignature{
+		SignedBy:  signedBy.String,
+		PublicKey: signingKey.String,
+		Signature: signature.String,
+		Version:   int(signedVersion.Int64),
+	}
+}
+
+// bidSignatureValues are the values of the signature, signed_by, signing_key and signed_version
+// columns.
+func bidSignatureValues(signature *models.BidSignature) (interface{}, interface{}, interface{}, interface{}) {
+	if signature == nil {
+		return nil, nil, nil, nil
+	}
+	return signature.Signature, signature.SignedBy, signature.PublicKey, signature.Version
+}
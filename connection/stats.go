@@ -0,0 +1,66 @@
+package connection
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// PipelineStats counts where tenders and bids are in their lifecycle. PendingDecisions are
+// bids of published tenders still waiting for the decision of the tender owner.
+type PipelineStats struct {
+	TendersByStatus  map[string]int
+	BidsByStatus     map[string]int
+	PendingDecisions int
+}
+
+// Stats returns the statistics of the connection pool.
+func (r *Repository) Stats() sql.DBStats {
+	return r.db.Stats()
+}
+
+func (r *Repository) PipelineStats() (PipelineStats, error) {
+	stats := PipelineStats{}
+
+	var err error
+	stats.TendersByStatus, err = r.countByStatus(`SELECT status, COUNT(*) FROM tender GROUP BY status`)
+	if err != nil {
+		return PipelineStats{}, fmt.Errorf("failed to count tenders: %w", err)
+	}
+
+	stats.BidsByStatus, err = r.countByStatus(`SELECT status, COUNT(*) FROM bid GROUP BY status`)
+	if err != nil {
+		return PipelineStats{}, fmt.Errorf("failed to count bids: %w", err)
+	}
+
+	err = r.db.QueryRow(`
+		SELECT COUNT(*) FROM bid
+		JOIN tender ON tender.id = bid.tender_id
+		WHERE tender.status = 'PUBLISHED' AND bid.status = $1`, bidStatusCreated).Scan(&stats.PendingDecisions)
+	if err != nil {
+		return PipelineStats{}, fmt.Errorf("failed to count pending decisions: %w", err)
+	}
+
+	return stats, nil
+}
+
+func (r *Repository) countByStatus(query string) (map[string]int, error) {
+	rows, err := r.db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := make(map[string]int)
+	for rows.Next() {
+		var (
+			status string
+			count  int
+		)
+		err = rows.Scan(&status, &count)
+		if err != nil {
+			return nil, err
+		}
+		counts[status] = count
+	}
+	return counts, rows.Err()
+}
@@ -0,0 +1,279 @@
+package connection
+
+import (
+	"context"
+	"database/sql"
+	"runtime"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "github.com/noctusha/tender/connection"
+
+// WithContext returns the repository acting for ctx: its statements run with ctx and, when ctx
+// carries a span, are traced under it.
+func (r *Repository) WithContext(ctx context.Context) *Repository {
+	repo := *r
+	repo.db.ctx = ctx
+	return &repo
+}
+
+// database runs the statements of the repository with its context. Each statement gets a span
+// named after the Repository method running it and carrying the statement name, e.g.
+// "SELECT tender"; a transaction gets one span for the method with a child per statement. The
+// span of a query ends once its rows have been read. Nothing is traced, and the method is not
+// looked up, without a recording span in the context, so background polling costs nothing.
+//
+// Inside Transaction the statements run in its transaction, and a method beginning its own
+// transaction joins it instead.
+type database struct {
+	*sql.DB
+	ctx context.Context
+	tx  *transaction
+}
+
+func (d database) context() context.Context {
+	if d.ctx == nil {
+		return context.Background()
+	}
+	return d.ctx
+}
+
+func (d database) Exec(query string, args ...interface{}) (sql.Result, error) {
+	if d.tx != nil {
+		return d.tx.Exec(query, args...)
+	}
+
+	ctx, span := startSpan(d.context(), repositoryMethod, query)
+	defer span.End()
+
+	res, err := d.DB.ExecContext(ctx, query, args...)
+	recordError(span, err)
+	return res, err
+}
+
+func (d database) Query(query string, args ...interface{}) (*tracedRows, error) {
+	if d.tx != nil {
+		return d.tx.Query(query, args...)
+	}
+
+	ctx, span := startSpan(d.context(), repositoryMethod, query)
+	rows, err := d.DB.QueryContext(ctx, query, args...)
+	return newTracedRows(span, rows, err)
+}
+
+func (d database) QueryRow(query string, args ...interface{}) *tracedRow {
+	if d.tx != nil {
+		return d.tx.QueryRow(query, args...)
+	}
+
+	ctx, span := startSpan(d.context(), repositoryMethod, query)
+	return newTracedRow(span, d.DB.QueryRowContext(ctx, query, args...))
+}
+
+func (d database) Begin() (*transaction, error) {
+	if d.tx != nil {
+		return &transaction{Tx: d.tx.Tx, ctx: d.tx.ctx, span: d.tx.span, parent: d.tx.parent, joined: true}, nil
+	}
+
+	ctx, span := d.context(), trace.SpanFromContext(d.context())
+	if span.IsRecording() {
+		ctx, span = otel.Tracer(tracerName).Start(ctx, repositoryMethod(),
+			trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(attribute.String("db.system", "postgresql"), attribute.String("db.operation", "TRANSACTION")))
+	}
+
+	sqlTx, err := d.DB.BeginTx(ctx, nil)
+	if err != nil {
+		recordError(span, err)
+		endSpan(span, d.context())
+		return nil, err
+	}
+	return &transaction{Tx: sqlTx, ctx: ctx, span: span, parent: d.context()}, nil
+}
+
+// transaction is a transaction of the repository; its span ends with the transaction. A joined
+// transaction is the one of Transaction seen from a method inside it: committing or rolling it
+// back is left to Transaction.
+type transaction struct {
+	*sql.Tx
+	ctx    context.Context
+	span   trace.Span
+	parent context.Context
+	joined bool
+}
+
+func (t *transaction) Exec(query string, args ...interface{}) (sql.Result, error) {
+	ctx, span := startSpan(t.ctx, statementName(query), query)
+	defer span.End()
+
+	res, err := t.Tx.ExecContext(ctx, query, args...)
+	recordError(span, err)
+	return res, err
+}
+
+func (t *transaction) Query(query string, args ...interface{}) (*tracedRows, error) {
+	ctx, span := startSpan(t.ctx, statementName(query), query)
+	rows, err := t.Tx.QueryContext(ctx, query, args...)
+	return newTracedRows(span, rows, err)
+}
+
+func (t *transaction) QueryRow(query string, args ...interface{}) *tracedRow {
+	ctx, span := startSpan(t.ctx, statementName(query), query)
+	return newTracedRow(span, t.Tx.QueryRowContext(ctx, query, args...))
+}
+
+func (t *transaction) Commit() error {
+	if t.joined {
+		return nil
+	}
+
+	err := t.Tx.Commit()
+	recordError(t.span, err)
+	endSpan(t.span, t.parent)
+	return err
+}
+
+// Rollback marks the span of a transaction that was not committed as failed. The repository
+// defers it after every Begin, so after a commit it does nothing.
+func (t *transaction) Rollback() error {
+	if t.joined {
+		return nil
+	}
+
+	err := t.Tx.Rollback()
+	if err == nil {
+		t.span.SetStatus(codes.Error, "transaction rolled back")
+	}
+	endSpan(t.span, t.parent)
+	return err
+}
+
+// tracedRows are the rows of a query. The span of the query ends once they have been read or
+// closed, so it covers reading them.
+type tracedRows struct {
+	*sql.Rows
+	span trace.Span
+}
+
+func newTracedRows(span trace.Span, rows *sql.Rows, err error) (*tracedRows, error) {
+	if err != nil {
+		recordError(span, err)
+		span.End()
+		return nil, err
+	}
+	return &tracedRows{Rows: rows, span: span}, nil
+}
+
+func (r *tracedRows) Next() bool {
+	if r.Rows.Next() {
+		return true
+	}
+	r.end()
+	return false
+}
+
+func (r *tracedRows) Close() error {
+	err := r.Rows.Close()
+	r.end()
+	return err
+}
+
+func (r *tracedRows) end() {
+	recordError(r.span, r.Rows.Err())
+	r.span.End()
+}
+
+// tracedRow is the row of a query. The span of the query ends once the row has been scanned.
+type tracedRow struct {
+	*sql.Row
+	span trace.Span
+}
+
+func newTracedRow(span trace.Span, row *sql.Row) *tracedRow {
+	return &tracedRow{Row: row, span: span}
+}
+
+func (r *tracedRow) Scan(dest ...interface{}) error {
+	err := r.Row.Scan(dest...)
+	recordError(r.span, err)
+	r.span.End()
+	return err
+}
+
+// startSpan starts the span of a statement under the span of ctx, if that span is recording.
+// The span is named by name, which is not called otherwise, since naming a span after the method
+// on the stack costs a stack walk.
+func startSpan(ctx context.Context, name func() string, query string) (context.Context, trace.Span) {
+	if !trace.SpanFromContext(ctx).IsRecording() {
+		return ctx, trace.SpanFromContext(ctx)
+	}
+
+	operation, table := statementParts(query)
+	return otel.Tracer(tracerName).Start(ctx, name(),
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("db.system", "postgresql"),
+			attribute.String("db.statement", strings.Join(strings.Fields(query), " ")),
+			attribute.String("db.operation", operation),
+			attribute.String("db.sql.table", table),
+		))
+}
+
+// endSpan ends a span the repository started; the span of the caller is left alone.
+func endSpan(span trace.Span, parent context.Context) {
+	if span != trace.SpanFromContext(parent) {
+		span.End()
+	}
+}
+
+func recordError(span trace.Span, err error) {
+	if err != nil && err != sql.ErrNoRows && err != sql.ErrTxDone {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+}
+
+// repositoryMethod names the Repository method on the stack, e.g. "Repository.GetTenderByID".
+func repositoryMethod() string {
+	pcs := make([]uintptr, 16)
+	n := runtime.Callers(3, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+	for {
+		frame, more := frames.Next()
+		if _, method, found := strings.Cut(frame.Function, ".(*Repository)."); found {
+			return "Repository." + method
+		}
+		if !more {
+			return "Repository"
+		}
+	}
+}
+
+// statementName names a statement by its operation and table, e.g. "UPDATE bid".
+func statementName(query string) func() string {
+	return func() string {
+		operation, table := statementParts(query)
+		return strings.TrimSpace(operation + " " + table)
+	}
+}
+
+func statementParts(query string) (string, string) {
+	fields := strings.Fields(query)
+	if len(fields) == 0 {
+		return "", ""
+	}
+
+	operation := strings.ToUpper(fields[0])
+	for i, field := range fields[:len(fields)-1] {
+		switch strings.ToUpper(field) {
+		case "FROM", "INTO", "UPDATE":
+			return operation, strings.Trim(fields[i+1], "(),;")
+		}
+	}
+	return operation, ""
+}
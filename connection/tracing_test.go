@@ -0,0 +1,76 @@
+package connection
+
+import (
+	"context"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestStatementName(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{query: "SELECT id, name FROM tender WHERE id = $1", want: "SELECT tender"},
+		{query: "\n\t\tINSERT INTO bid_seal (id, hash) VALUES ($1, $2)", want: "INSERT bid_seal"},
+		{query: "update bid SET name = $1 WHERE id = $2", want: "UPDATE bid"},
+		{query: "SELECT EXISTS (SELECT 1 FROM lot WHERE tender_id = $1)", want: "SELECT lot"},
+		{query: "SELECT pg_advisory_xact_lock($1)", want: "SELECT"},
+		{query: "", want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			if got := statementName(tt.query)(); got != tt.want {
+				t.Errorf("statementName(%q) = %q, want %q", tt.query, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStartSpan(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	global := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	t.Cleanup(func() {
+		otel.SetTracerProvider(global)
+		provider.Shutdown(context.Background())
+	})
+
+	recording, parent := provider.Tracer("test").Start(context.Background(), "request")
+	defer parent.End()
+	notRecording, _ := sdktrace.NewTracerProvider(sdktrace.WithSampler(sdktrace.NeverSample())).Tracer("test").
+		Start(context.Background(), "request")
+
+	tests := []struct {
+		name      string
+		ctx       context.Context
+		wantNamed bool
+	}{
+		{name: "no span", ctx: context.Background()},
+		{name: "span not recording", ctx: notRecording},
+		{name: "recording span", ctx: recording, wantNamed: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			named := false
+			_, span := startSpan(tt.ctx, func() string {
+				named = true
+				return "Repository.GetTenderByID"
+			}, "SELECT id FROM tender WHERE id = $1")
+			span.End()
+
+			if named != tt.wantNamed {
+				t.Errorf("span named = %v, want %v", named, tt.wantNamed)
+			}
+		})
+	}
+
+	ended := recorder.Ended()
+	if len(ended) != 1 || ended[0].Name() != "Repository.GetTenderByID" || ended[0].Parent().SpanID() != parent.SpanContext().SpanID() {
+		t.Errorf("ended spans = %v, want one statement span under the request", ended)
+	}
+}
@@ -0,0 +1,28 @@
+package connection
+
+import "fmt"
+
+// Transaction runs fn with a repository whose statements all run in one transaction, so a change
+// and what is recorded about it are saved together or not at all. The transaction is committed
+// when fn returns nil and rolled back otherwise; the error of fn is returned as is.
+func (r *Repository) Transaction(fn func(repo *Repository) error) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	repo := *r
+	repo.db.tx = tx
+
+	err = fn(&repo)
+	if err != nil {
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,275 @@
+package connection
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+
+	"github.com/noctusha/tender/models"
+)
+
+const (
+	webhookDeliveryPending   = "PENDING"
+	webhookDeliveryDelivered = "DELIVERED"
+	webhookDeliveryFailed    = "FAILED"
+)
+
+// WebhookDispatch is a claimed delivery together with where and how to send it.
+type WebhookDispatch struct {
+	Delivery models.WebhookDelivery
+	URL      string
+	Secret   string
+}
+
+const webhookSubscriptionColumns = `id, organization_id, url, event_types, active, created_by, created_at, updated_at`
+
+func scanWebhookSubscription(row interface{ Scan(...interface{}) error }, subscription *models.WebhookSubscription) error {
+	return row.Scan(&subscription.ID, &subscription.OrganizationID, &subscription.URL, pq.Array(&subscription.EventTypes),
+		&subscription.Active, &subscription.CreatedBy, &subscription.CreatedAt, &subscription.UpdatedAt)
+}
+
+func (r *Repository) NewWebhookSubscription(subscription *models.WebhookSubscription) error {
+	err := r.db.QueryRow(`INSERT INTO webhook_subscription (id, organization_id, url, event_types, secret, active, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING created_at, updated_at`,
+		subscription.ID, subscription.OrganizationID, subscription.URL, pq.Array(subscription.EventTypes), subscription.Secret,
+		subscription.Active, subscription.CreatedBy).Scan(&subscription.CreatedAt, &subscription.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to insert data into webhook_subscription: %w", err)
+	}
+	return nil
+}
+
+func (r *Repository) WebhookSubscriptionsByOrganizationID(organizationID string) ([]models.WebhookSubscription, error) {
+	subscriptions := []models.WebhookSubscription{}
+
+	rows, err := r.db.Query(`SELECT `+webhookSubscriptionColumns+` FROM webhook_subscription
+		WHERE organization_id::text = $1 ORDER BY created_at`, organizationID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to select data from webhook_subscription: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		subscription := models.WebhookSubscription{}
+		if err := scanWebhookSubscription(rows, &subscription); err != nil {
+			return nil, fmt.Errorf("failed to scan: %w", err)
+		}
+		subscriptions = append(subscriptions, subscription)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, fmt.Errorf("rows iteration error: %w", err)
+	}
+
+	return subscriptions, nil
+}
+
+func (r *Repository) GetWebhookSubscriptionByID(subscriptionID uuid.UUID) (*models.WebhookSubscription, bool, error) {
+	var subscription models.WebhookSubscription
+	err := scanWebhookSubscription(r.db.QueryRow(`SELECT `+webhookSubscriptionColumns+` FROM webhook_subscription WHERE id = $1`,
+		subscriptionID.String()), &subscription)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, false, nil
+		}
+		return nil, false, fmt.Errorf("failed to select data from webhook_subscription: %w", err)
+	}
+	return &subscription, true, nil
+}
+
+func (r *Repository) UpdateWebhookSubscription(subscription *models.WebhookSubscription) error {
+	err := r.db.QueryRow(`UPDATE webhook_subscription SET url = $1, event_types = $2, active = $3, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $4 RETURNING updated_at`,
+		subscription.URL, pq.Array(subscription.EventTypes), subscription.Active, subscription.ID).Scan(&subscription.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to update webhook subscription: %w", err)
+	}
+	return nil
+}
+
+func (r *Repository) DeleteWebhookSubscription(subscriptionID string) error {
+	_, err := r.db.Exec(`DELETE FROM webhook_subscription WHERE id = $1`, subscriptionID)
+	if err != nil {
+		return fmt.Errorf("failed to delete webhook subscription: %w", err)
+	}
+	return nil
+}
+
+// EnqueueWebhookDeliveries schedules the event for every active subscription of the organizations
+// that listens to its type and returns how many deliveries were queued. An event already queued
+// for a subscription is skipped.
+func (r *Repository) EnqueueWebhookDeliveries(eventID string, eventType string, payload []byte, organizationIDs []string) (int, error) {
+	res, err := r.db.Exec(`INSERT INTO webhook_delivery (subscription_id, event_id, event_type, payload, status)
+		SELECT id, $1, $2, $3, $4 FROM webhook_subscription
+		WHERE active AND organization_id::text = ANY($5) AND $2 = ANY(event_types)
+		ON CONFLICT (subscription_id, event_id) DO NOTHING`,
+		eventID, eventType, string(payload), webhookDeliveryPending, pq.Array(organizationIDs))
+	if err != nil {
+		return 0, fmt.Errorf("failed to insert data into webhook_delivery: %w", err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to count webhook deliveries: %w", err)
+	}
+	return int(n), nil
+}
+
+// ClaimWebhookDeliveries takes up to limit pending deliveries that are due. Claimed deliveries are
+// hidden from other dispatchers for the lease, so a dispatcher that dies mid-send is retried later.
+func (r *Repository) ClaimWebhookDeliveries(limit int, lease time.Duration) ([]WebhookDispatch, error) {
+	dispatches := []WebhookDispatch{}
+
+	rows, err := r.db.Query(`WITH claimed AS (
+			UPDATE webhook_delivery SET next_attempt_at = CURRENT_TIMESTAMP + make_interval(secs => $2)
+			WHERE id IN (
+				SELECT webhook_delivery.id FROM webhook_delivery
+				JOIN webhook_subscription ON webhook_subscription.id = webhook_delivery.subscription_id
+				WHERE webhook_delivery.status = $3 AND webhook_delivery.next_attempt_at <= CURRENT_TIMESTAMP AND webhook_subscription.active
+				ORDER BY webhook_delivery.next_attempt_at
+				LIMIT $1
+				FOR UPDATE OF webhook_delivery SKIP LOCKED
+			)
+			RETURNING id, subscription_id, event_id, event_type, payload, attempts
+		)
+		SELECT claimed.id, claimed.subscription_id, claimed.event_id, claimed.event_type, claimed.payload, claimed.attempts,
+			webhook_subscription.url, webhook_subscription.secret
+		FROM claimed JOIN webhook_subscription ON webhook_subscription.id = claimed.subscription_id`,
+		limit, lease.Seconds(), webhookDeliveryPending)
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim webhook deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			dispatch WebhookDispatch
+			payload  []byte
+		)
+		err := rows.Scan(&dispatch.Delivery.ID, &dispatch.Delivery.SubscriptionID, &dispatch.Delivery.EventID, &dispatch.Delivery.EventType,
+			&payload, &dispatch.Delivery.Attempts, &dispatch.URL, &dispatch.Secret)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan: %w", err)
+		}
+		dispatch.Delivery.Payload = payload
+		dispatch.Delivery.Status = webhookDeliveryPending
+		dispatches = append(dispatches, dispatch)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, fmt.Errorf("rows iteration error: %w", err)
+	}
+
+	return dispatches, nil
+}
+
+func (r *Repository) MarkWebhookDelivered(deliveryID string, statusCode int) error {
+	_, err := r.db.Exec(`UPDATE webhook_delivery SET status = $1, attempts = attempts + 1, last_status_code = $2, last_error = NULL,
+		delivered_at = CURRENT_TIMESTAMP WHERE id = $3`,
+		webhookDeliveryDelivered, statusCode, deliveryID)
+	if err != nil {
+		return fmt.Errorf("failed to update webhook delivery: %w", err)
+	}
+	return nil
+}
+
+// MarkWebhookAttemptFailed records a failed attempt. The delivery is retried at retryAt,
+// or given up on when retryAt is nil. A zero statusCode means no response was received.
+func (r *Repository) MarkWebhookAttemptFailed(deliveryID string, statusCode int, reason string, retryAt *time.Time) error {
+	status := webhookDeliveryPending
+	if retryAt == nil {
+		status = webhookDeliveryFailed
+	}
+
+	_, err := r.db.Exec(`UPDATE webhook_delivery SET status = $1, attempts = attempts + 1, last_status_code = NULLIF($2, 0), last_error = $3,
+		next_attempt_at = COALESCE($4, next_attempt_at) WHERE id = $5`,
+		status, statusCode, reason, retryAt, deliveryID)
+	if err != nil {
+		return fmt.Errorf("failed to update webhook delivery: %w", err)
+	}
+	return nil
+}
+
+const webhookDeliveryColumns = `webhook_delivery.id, webhook_delivery.subscription_id, webhook_delivery.event_id, webhook_delivery.event_type,
+		webhook_delivery.payload, webhook_delivery.status, webhook_delivery.attempts, COALESCE(webhook_delivery.last_status_code, 0),
+		COALESCE(webhook_delivery.last_error, ''), webhook_delivery.next_attempt_at, COALESCE(webhook_delivery.delivered_at::text, ''),
+		webhook_delivery.created_at`
+
+func scanWebhookDelivery(row interface{ Scan(...interface{}) error }, delivery *models.WebhookDelivery, keyDest ...interface{}) error {
+	var payload []byte
+	err := row.Scan(append([]interface{}{&delivery.ID, &delivery.SubscriptionID, &delivery.EventID, &delivery.EventType, &payload,
+		&delivery.Status, &delivery.Attempts, &delivery.LastStatusCode, &delivery.LastError, &delivery.NextAttemptAt,
+		&delivery.DeliveredAt, &delivery.CreatedAt}, keyDest...)...)
+	if err != nil {
+		return err
+	}
+	delivery.Payload = payload
+	if delivery.Status != webhookDeliveryPending {
+		delivery.NextAttemptAt = ""
+	}
+	return nil
+}
+
+// WebhookDeliveries is the delivery log of a subscription, newest first, optionally limited to one status.
+func (r *Repository) WebhookDeliveries(subscriptionID string, status string, page Page) ([]models.WebhookDelivery, PageInfo, error) {
+	deliveries := []models.WebhookDelivery{}
+
+	q := newQuery(webhookDeliveryColumns, "webhook_delivery").Where("webhook_delivery.subscription_id::text = ?", subscriptionID)
+	if status != "" {
+		q.Where("webhook_delivery.status = ?", status)
+	}
+
+	keys := []sortKey{{expr: "webhook_delivery.created_at", desc: true}, {expr: "webhook_delivery.id"}}
+	info, err := r.paginate(q, keys, "-createdAt", page, func(rows *tracedRows, keyDest ...interface{}) error {
+		delivery := models.WebhookDelivery{}
+		if err := scanWebhookDelivery(rows, &delivery, keyDest...); err != nil {
+			return err
+		}
+		deliveries = append(deliveries, delivery)
+		return nil
+	})
+	if errors.Is(err, ErrInvalidCursor) {
+		return nil, info, err
+	}
+	if err != nil {
+		return nil, info, fmt.Errorf("failed to select data from webhook_delivery: %w", err)
+	}
+
+	return deliveries, info, nil
+}
+
+func (r *Repository) GetWebhookDeliveryByID(deliveryID uuid.UUID) (*models.WebhookDelivery, bool, error) {
+	var delivery models.WebhookDelivery
+	err := scanWebhookDelivery(r.db.QueryRow(`SELECT `+webhookDeliveryColumns+` FROM webhook_delivery WHERE id = $1`,
+		deliveryID.String()), &delivery)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, false, nil
+		}
+		return nil, false, fmt.Errorf("failed to select data from webhook_delivery: %w", err)
+	}
+	return &delivery, true, nil
+}
+
+// ReplayWebhookDelivery puts a failed delivery back into the queue with a fresh set of attempts.
+// It reports false when the delivery is no longer failed.
+func (r *Repository) ReplayWebhookDelivery(deliveryID string) (bool, error) {
+	res, err := r.db.Exec(`UPDATE webhook_delivery SET status = $1, attempts = 0, next_attempt_at = CURRENT_TIMESTAMP
+		WHERE id = $2 AND status = $3`,
+		webhookDeliveryPending, deliveryID, webhookDeliveryFailed)
+	if err != nil {
+		return false, fmt.Errorf("failed to replay webhook delivery: %w", err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to replay webhook delivery: %w", err)
+	}
+	return n > 0, nil
+}
@@ -0,0 +1,78 @@
+package connection
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/noctusha/tender/models"
+)
+
+// ChangeBidStatus moves a bid from one status to bid.Status on behalf of its author and keeps the change with its reason in the bid history.
+// A bid no longer in the expected status, e.g. decided concurrently, is a conflict. A bid resubmitted after the tender was amended
+// since its withdrawal was submitted on outdated terms, so it is flagged for reconfirmation; bid.NeedsReconfirmation is filled in.
+func (r *Repository) ChangeBidStatus(bid *models.Bid, from string, reason string, changedBy string, events ...models.Event) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRow(`UPDATE bid SET status = $1, updated_at = CURRENT_TIMESTAMP,
+			needs_reconfirmation = needs_reconfirmation OR ($3 = $4 AND EXISTS (
+				SELECT 1 FROM tender_amendment WHERE tender_amendment.tender_id = bid.tender_id
+					AND tender_amendment.created_at > (SELECT max(created_at) FROM bid_status_history WHERE bid_id = bid.id AND status = $3)))
+		WHERE id = $2 AND status = $3
+		RETURNING needs_reconfirmation`,
+		bid.Status, bid.ID, from, bidStatusWithdrawn).Scan(&bid.NeedsReconfirmation)
+	if errors.Is(err, sql.ErrNoRows) {
+		return bidStatusConflict(tx, bid.ID)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to update bid status: %w", err)
+	}
+
+	_, err = tx.Exec(`INSERT INTO bid_status_history (bid_id, status, reason, changed_by) VALUES ($1, $2, NULLIF($3, ''), $4)`,
+		bid.ID, bid.Status, reason, changedBy)
+	if err != nil {
+		return fmt.Errorf("failed to insert data into bid_status_history: %w", err)
+	}
+
+	err = insertEvents(tx, events)
+	if err != nil {
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+func (r *Repository) BidStatusHistory(bidID string) ([]models.BidStatusChange, error) {
+	history := []models.BidStatusChange{}
+
+	rows, err := r.db.Query(`SELECT id, bid_id, status, COALESCE(reason, ''), changed_by, created_at
+		FROM bid_status_history WHERE bid_id = $1 ORDER BY created_at`, bidID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to select data from bid_status_history: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		change := models.BidStatusChange{}
+		err := rows.Scan(&change.ID, &change.BidID, &change.Status, &change.Reason, &change.ChangedBy, &change.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan: %w", err)
+		}
+		history = append(history, change)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, fmt.Errorf("rows iteration error: %w", err)
+	}
+
+	return history, nil
+}
@@ -0,0 +1,179 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+
+	"github.com/noctusha/tender/connection"
+	"github.com/noctusha/tender/models"
+)
+
+func deadlinePassed(tender *models.Tender) bool {
+	return tender.Deadline != nil && time.Now().After(*tender.Deadline)
+}
+
+func changedTenderFields(previous models.Tender, updated models.Tender) []string {
+	fields := []string{}
+	if previous.Name != updated.Name {
+		fields = append(fields, "name")
+	}
+	if previous.Description != updated.Description {
+		fields = append(fields, "description")
+	}
+	switch {
+	case previous.Deadline == nil && updated.Deadline == nil:
+	case previous.Deadline == nil || updated.Deadline == nil || !previous.Deadline.Equal(*updated.Deadline):
+		fields = append(fields, "deadline")
+	}
+	return fields
+}
+
+type tenderAmendedEvent struct {
+	Tender    *models.Tender          `json:"tender"`
+	Amendment *models.TenderAmendment `json:"amendment"`
+}
+
+// amendTender applies a change to a published tender as an amendment, so bidders can see
+// what changed and reconfirm their bids. A change that alters nothing is not recorded. change,
+// if not nil, makes the rest of the change in the same transaction, once the previous version
+// has been kept.
+func (h *Handler) amendTender(w http.ResponseWriter, r *http.Request, action string, previous models.Tender, tender *models.Tender, username string, reason string,
+	change func(repo *connection.Repository) error, alsoChanged ...string) {
+	changed := append(changedTenderFields(previous, *tender), alsoChanged...)
+	if len(changed) == 0 {
+		respondJSON(w, http.StatusOK, tender)
+		return
+	}
+
+	amendment := models.TenderAmendment{
+		ID:            uuid.New().String(),
+		TenderID:      tender.ID,
+		Reason:        reason,
+		ChangedFields: changed,
+		CreatedBy:     username,
+	}
+
+	event := connection.NewTenderEvent(connection.EventTenderAmended, tender.ID, tenderAmendedEvent{Tender: tender, Amendment: &amendment}, tender.OrganizationID)
+	err := h.repository(r).Transaction(func(repo *connection.Repository) error {
+		err := repo.AmendTender(previous, tender, &amendment, event)
+		if err != nil {
+			return fmt.Errorf("failed to amend tender: %w", err)
+		}
+
+		if change != nil {
+			err = change(repo)
+			if err != nil {
+				return err
+			}
+		}
+		return h.audit(r, repo, username, action, auditEntityTender, tender.ID, previous, tender, tender.OrganizationID)
+	})
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+	h.outbox.Wake()
+
+	respondJSON(w, http.StatusOK, tender)
+}
+
+func (h *Handler) ListAmendments(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	tenderID, err := uuid.Parse(vars["tenderId"])
+	if err != nil {
+		respondError(w, problem(codeInvalidParameter, "tenderId"))
+		return
+	}
+
+	var username string
+	for name, vals := range r.URL.Query() {
+		switch name {
+		case "username":
+			username = vals[0]
+		default:
+			respondError(w, problem(codeUnknownParameter, name))
+			return
+		}
+	}
+
+	if username == "" {
+		respondError(w, problem(codeMissingUsername))
+		return
+	}
+
+	tender, ok, err := h.repository(r).GetTenderByID(tenderID)
+	if err != nil {
+		respondError(w, fmt.Errorf("failed to get tender: %w", err))
+		return
+	}
+
+	if !ok {
+		respondError(w, problem(codeTenderNotFound))
+		return
+	}
+
+	organizationId, userFound, err := h.repository(r).GetOrganizationIDByUsername(username)
+	if err != nil {
+		respondError(w, fmt.Errorf("failed to get organization by username: %w", err))
+		return
+	}
+
+	if !userFound {
+		respondError(w, problem(codeUserNotFound, username))
+		return
+	}
+
+	allowed, err := h.canAccessTender(r, tender, organizationId)
+	if err != nil {
+		respondError(w, fmt.Errorf("failed to check tender invitation: %w", err))
+		return
+	}
+
+	if !allowed {
+		respondError(w, problem(codeTenderForbidden, username))
+		return
+	}
+
+	amendments, err := h.repository(r).AmendmentsByTenderID(tender.ID)
+	if err != nil {
+		respondError(w, fmt.Errorf("failed to select amendment from database: %w", err))
+		return
+	}
+
+	respondJSON(w, http.StatusOK, JSON{Amendments: &amendments})
+}
+
+// ReconfirmBid confirms that a bid flagged by an amendment still stands under the amended terms.
+func (h *Handler) ReconfirmBid(w http.ResponseWriter, r *http.Request) {
+	bid, tender, username, ok := h.authorBidFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	if !bid.NeedsReconfirmation || bid.Status != statusCreated {
+		respondError(w, problem(codeBidReconfirmationNotNeeded))
+		return
+	}
+
+	previous := *bid
+	bid.NeedsReconfirmation = false
+	organizationIDs := h.bidOrganizationIDs(r, bid, tender)
+	err := h.repository(r).Transaction(func(repo *connection.Repository) error {
+		err := repo.ReconfirmBid(bid.ID)
+		if err != nil {
+			return fmt.Errorf("failed to reconfirm bid: %w", err)
+		}
+		return h.audit(r, repo, username, auditActionEdit, auditEntityBid, bid.ID, previous, bid, organizationIDs...)
+	})
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, bid)
+}
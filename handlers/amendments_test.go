@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/noctusha/tender/models"
+)
+
+func TestChangedTenderFields(t *testing.T) {
+	deadline := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	sameDeadline := deadline.In(time.FixedZone("MSK", 3*60*60))
+	laterDeadline := deadline.Add(time.Hour)
+
+	previous := models.Tender{Name: "Поставка труб", Description: "Трубы ДУ-50", Deadline: &deadline, Status: statusPublished}
+
+	tests := []struct {
+		name    string
+		updated func(tender *models.Tender)
+		want    []string
+	}{
+		{name: "nothing", want: []string{}},
+		{name: "fields that are not terms", updated: func(tender *models.Tender) {
+			tender.Status = statusClosed
+			tender.ServiceType = "Delivery"
+		}, want: []string{}},
+		{name: "same deadline in another zone", updated: func(tender *models.Tender) { tender.Deadline = &sameDeadline }, want: []string{}},
+		{name: "name", updated: func(tender *models.Tender) { tender.Name = "Поставка труб ДУ-50" }, want: []string{"name"}},
+		{name: "description", updated: func(tender *models.Tender) { tender.Description = "" }, want: []string{"description"}},
+		{name: "deadline moved", updated: func(tender *models.Tender) { tender.Deadline = &laterDeadline }, want: []string{"deadline"}},
+		{name: "deadline removed", updated: func(tender *models.Tender) { tender.Deadline = nil }, want: []string{"deadline"}},
+		{name: "all terms", updated: func(tender *models.Tender) {
+			tender.Name = "Поставка бумаги"
+			tender.Description = "Бумага A4"
+			tender.Deadline = &laterDeadline
+		}, want: []string{"name", "description", "deadline"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			updated := previous
+			if tt.updated != nil {
+				tt.updated(&updated)
+			}
+
+			if got := changedTenderFields(previous, updated); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("changedTenderFields = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("deadline added", func(t *testing.T) {
+		withoutDeadline := previous
+		withoutDeadline.Deadline = nil
+		if got := changedTenderFields(withoutDeadline, previous); !reflect.DeepEqual(got, []string{"deadline"}) {
+			t.Errorf("changedTenderFields = %v, want [deadline]", got)
+		}
+	})
+}
+
+func TestDeadlinePassed(t *testing.T) {
+	past := time.Now().Add(-time.Minute)
+	future := time.Now().Add(time.Hour)
+
+	tests := []struct {
+		name     string
+		deadline *time.Time
+		want     bool
+	}{
+		{name: "no deadline"},
+		{name: "future", deadline: &future},
+		{name: "past", deadline: &past, want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := deadlinePassed(&models.Tender{Deadline: tt.deadline}); got != tt.want {
+				t.Errorf("deadlinePassed = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
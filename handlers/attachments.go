@@ -0,0 +1,618 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+
+	"github.com/noctusha/tender/connection"
+	"github.com/noctusha/tender/models"
+	"github.com/noctusha/tender/storage"
+)
+
+const (
+	attachmentEntityTender = "tender"
+	attachmentEntityBid    = "bid"
+
+	defaultMaxAttachmentSize = 20 << 20
+)
+
+// allowedAttachmentTypes maps every accepted declared content type to the types
+// http.DetectContentType may report for it, so a file cannot lie about its format.
+var allowedAttachmentTypes = map[string][]string{
+	"application/pdf": {"application/pdf"},
+	"image/png":       {"image/png"},
+	"image/jpeg":      {"image/jpeg"},
+	"image/vnd.dwg":   {"application/octet-stream"},
+	"text/plain":      {"text/plain"},
+	"text/csv":        {"text/plain"},
+	"application/zip": {"application/zip"},
+	"application/vnd.openxmlformats-officedocument.wordprocessingml.document": {"application/zip"},
+	"application/vnd.openxmlformats-officedocument.spreadsheetml.sheet":       {"application/zip"},
+}
+
+func attachmentContentType(file multipart.File, header *multipart.FileHeader) (string, error) {
+	declared, _, err := mime.ParseMediaType(header.Header.Get("Content-Type"))
+	if err != nil || declared == "" || declared == "application/octet-stream" {
+		declared, _, _ = mime.ParseMediaType(mime.TypeByExtension(strings.ToLower(filepath.Ext(header.Filename))))
+	}
+
+	sniffable, ok := allowedAttachmentTypes[declared]
+	if !ok {
+		return "", problem(codeContentTypeNotAllowed, declared)
+	}
+
+	head := make([]byte, 512)
+	n, err := io.ReadFull(file, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return "", fmt.Errorf("failed to read file: %w", err)
+	}
+
+	_, err = file.Seek(0, io.SeekStart)
+	if err != nil {
+		return "", fmt.Errorf("failed to read file: %w", err)
+	}
+
+	detected, _, _ := mime.ParseMediaType(http.DetectContentType(head[:n]))
+	for _, t := range sniffable {
+		if detected == t {
+			return declared, nil
+		}
+	}
+	return "", problem(codeContentTypeMismatch, detected, declared)
+}
+
+// receiveAttachment reads the "file" part of a multipart upload, checks its size and type
+// and stores it in the blob storage. The returned attachment still has to be saved to the
+// database. It writes the error response itself and reports false on failure.
+func (h *Handler) receiveAttachment(w http.ResponseWriter, r *http.Request, entityType string, entityID string, username string) (*models.Attachment, bool) {
+	r.Body = http.MaxBytesReader(w, r.Body, h.maxAttachmentSize+1<<20)
+
+	err := r.ParseMultipartForm(1 << 20)
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			respondError(w, problem(codeAttachmentTooLarge, h.maxAttachmentSize))
+			return nil, false
+		}
+		respondError(w, problem(codeInvalidMultipart, err))
+		return nil, false
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		respondError(w, problem(codeMissingFile))
+		return nil, false
+	}
+	defer file.Close()
+
+	if header.Size == 0 {
+		respondError(w, problem(codeEmptyFile))
+		return nil, false
+	}
+
+	if header.Size > h.maxAttachmentSize {
+		respondError(w, problem(codeAttachmentTooLarge, h.maxAttachmentSize))
+		return nil, false
+	}
+
+	fileName := filepath.Base(header.Filename)
+	if fileName == "" || fileName == "." || len(fileName) > 255 {
+		respondError(w, problem(codeInvalidFileName))
+		return nil, false
+	}
+
+	contentType, err := attachmentContentType(file, header)
+	if err != nil {
+		respondError(w, err)
+		return nil, false
+	}
+
+	attachment := &models.Attachment{
+		ID:          uuid.New().String(),
+		EntityType:  entityType,
+		EntityID:    entityID,
+		FileName:    fileName,
+		ContentType: contentType,
+		Size:        header.Size,
+		UploadedBy:  username,
+	}
+	attachment.StorageKey = "attachments/" + attachment.ID
+
+	hash := sha256.New()
+	err = h.storage.Put(r.Context(), attachment.StorageKey, io.TeeReader(file, hash), header.Size, contentType)
+	if err != nil {
+		respondError(w, fmt.Errorf("failed to store attachment: %w", err))
+		return nil, false
+	}
+	attachment.SHA256 = hex.EncodeToString(hash.Sum(nil))
+
+	return attachment, true
+}
+
+// saveAttachment saves a stored attachment after keepVersion has kept the version of its entity
+// before the change, in one transaction with the new seal of a bid and the audit record. The blob
+// is deleted if they cannot be saved.
+func (h *Handler) saveAttachment(w http.ResponseWriter, r *http.Request, attachment *models.Attachment,
+	keepVersion func(repo *connection.Repository) error, organizationIDs ...string) {
+	err := h.repository(r).Transaction(func(repo *connection.Repository) error {
+		err := keepVersion(repo)
+		if err != nil {
+			return err
+		}
+
+		err = repo.NewAttachment(attachment)
+		if err != nil {
+			return fmt.Errorf("failed to save attachment: %w", err)
+		}
+
+		err = sealAttachedBid(repo, attachment)
+		if err != nil {
+			return err
+		}
+		return h.audit(r, repo, attachment.UploadedBy, auditActionCreate, auditEntityAttachment, attachment.ID, nil, attachment, organizationIDs...)
+	})
+	if err != nil {
+		_ = h.storage.Delete(r.Context(), attachment.StorageKey)
+		respondError(w, err)
+		return
+	}
+	h.outbox.Wake()
+
+	respondJSON(w, http.StatusOK, attachment)
+}
+
+func (h *Handler) streamAttachment(w http.ResponseWriter, r *http.Request, attachment *models.Attachment) {
+	body, err := h.storage.Get(r.Context(), attachment.StorageKey)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			respondError(w, problem(codeAttachmentContentNotFound))
+			return
+		}
+		respondError(w, fmt.Errorf("failed to read attachment: %w", err))
+		return
+	}
+	defer body.Close()
+
+	w.Header().Set("Content-Type", attachment.ContentType)
+	w.Header().Set("Content-Length", strconv.FormatInt(attachment.Size, 10))
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": attachment.FileName}))
+	w.Header().Set("ETag", `"`+attachment.SHA256+`"`)
+	w.Header().Set("X-Checksum-SHA256", attachment.SHA256)
+	w.WriteHeader(http.StatusOK)
+
+	_, err = io.Copy(w, body)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "failed to write attachment", "attachment_id", attachment.ID, "error", err)
+	}
+}
+
+// tenderFromRequest loads the tender addressed by the route together with the organization
+// of the requesting user and whether that organization owns the tender. Non-owners must have
+// access to the tender. It writes the error response itself and reports false on failure.
+func (h *Handler) tenderFromRequest(w http.ResponseWriter, r *http.Request) (*models.Tender, string, bool, bool) {
+	vars := mux.Vars(r)
+
+	tenderID, err := uuid.Parse(vars["tenderId"])
+	if err != nil {
+		respondError(w, problem(codeInvalidParameter, "tenderId"))
+		return nil, "", false, false
+	}
+
+	var username string
+	for name, vals := range r.URL.Query() {
+		switch name {
+		case "username":
+			username = vals[0]
+		default:
+			respondError(w, problem(codeUnknownParameter, name))
+			return nil, "", false, false
+		}
+	}
+
+	if username == "" {
+		respondError(w, problem(codeMissingUsername))
+		return nil, "", false, false
+	}
+
+	tender, ok, err := h.repository(r).GetTenderByID(tenderID)
+	if err != nil {
+		respondError(w, fmt.Errorf("failed to get tender: %w", err))
+		return nil, "", false, false
+	}
+
+	if !ok {
+		respondError(w, problem(codeTenderNotFound))
+		return nil, "", false, false
+	}
+
+	organizationId, userFound, err := h.repository(r).GetOrganizationIDByUsername(username)
+	if err != nil {
+		respondError(w, fmt.Errorf("failed to get organization by username: %w", err))
+		return nil, "", false, false
+	}
+
+	if !userFound {
+		respondError(w, problem(codeUserNotFound, username))
+		return nil, "", false, false
+	}
+
+	allowed, err := h.canAccessTender(r, tender, organizationId)
+	if err != nil {
+		respondError(w, fmt.Errorf("failed to check tender invitation: %w", err))
+		return nil, "", false, false
+	}
+
+	if !allowed {
+		respondError(w, problem(codeTenderForbidden, username))
+		return nil, "", false, false
+	}
+
+	return tender, username, tender.OrganizationID == organizationId, true
+}
+
+// attachmentFromRequest loads the attachment addressed by the route and checks it belongs to the entity.
+func (h *Handler) attachmentFromRequest(w http.ResponseWriter, r *http.Request, entityType string, entityID string) (*models.Attachment, bool) {
+	attachmentID, err := uuid.Parse(mux.Vars(r)["attachmentId"])
+	if err != nil {
+		respondError(w, problem(codeInvalidParameter, "attachmentId"))
+		return nil, false
+	}
+
+	attachment, ok, err := h.repository(r).GetAttachmentByID(attachmentID)
+	if err != nil {
+		respondError(w, fmt.Errorf("failed to get attachment: %w", err))
+		return nil, false
+	}
+
+	if !ok || attachment.EntityType != entityType || attachment.EntityID != entityID {
+		respondError(w, problem(codeAttachmentNotFound))
+		return nil, false
+	}
+
+	return attachment, true
+}
+
+// keepTenderVersion keeps the attachment set before a change in a tender version. A change to a
+// published tender is an amendment, since the attached specifications are part of its terms, and
+// is announced as such. It is made in the transaction of the change, so a failed change leaves no
+// amendment behind.
+func keepTenderVersion(tender *models.Tender, username string, reason string) func(repo *connection.Repository) error {
+	return func(repo *connection.Repository) error {
+		var err error
+		if tender.Status == statusPublished {
+			amendment := models.TenderAmendment{
+				ID:            uuid.New().String(),
+				TenderID:      tender.ID,
+				Reason:        reason,
+				ChangedFields: []string{"attachments"},
+				CreatedBy:     username,
+			}
+			event := connection.NewTenderEvent(connection.EventTenderAmended, tender.ID, tenderAmendedEvent{Tender: tender, Amendment: &amendment}, tender.OrganizationID)
+			err = repo.AmendTender(*tender, tender, &amendment, event)
+		} else {
+			err = repo.AddTenderVersion(&models.TenderVersion{
+				TenderID:    tender.ID,
+				Name:        tender.Name,
+				Description: tender.Description,
+				Deadline:    tender.Deadline,
+			})
+		}
+		if err != nil {
+			return fmt.Errorf("failed to add tender version: %w", err)
+		}
+		return nil
+	}
+}
+
+// keepBidVersion keeps the attachment set before a change in a bid version.
+func keepBidVersion(bid *models.Bid) func(repo *connection.Repository) error {
+	return func(repo *connection.Repository) error {
+		err := repo.AddBidVersion(&models.BidVersion{
+			BidID:       bid.ID,
+			Name:        bid.Name,
+			Description: bid.Description,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to add bid version: %w", err)
+		}
+		return nil
+	}
+}
+
+// sealAttachedBid seals a bid whose attachments have changed, in the transaction of the change.
+func sealAttachedBid(repo *connection.Repository, attachment *models.Attachment) error {
+	if attachment.EntityType != attachmentEntityBid {
+		return nil
+	}
+
+	_, err := repo.SealBid(attachment.EntityID, connection.SealActionAttachment)
+	if err != nil {
+		return fmt.Errorf("failed to seal bid: %w", err)
+	}
+	return nil
+}
+
+// detachAttachment unlinks an attachment after keepVersion has kept the version of its entity
+// before the change, in one transaction with the new seal of a bid and the audit record.
+func (h *Handler) detachAttachment(r *http.Request, attachment *models.Attachment, username string,
+	keepVersion func(repo *connection.Repository) error, organizationIDs ...string) error {
+	return h.repository(r).Transaction(func(repo *connection.Repository) error {
+		err := keepVersion(repo)
+		if err != nil {
+			return err
+		}
+
+		err = repo.DetachAttachment(attachment.ID)
+		if err != nil {
+			return fmt.Errorf("failed to delete attachment: %w", err)
+		}
+
+		err = sealAttachedBid(repo, attachment)
+		if err != nil {
+			return err
+		}
+		return h.audit(r, repo, username, auditActionDelete, auditEntityAttachment, attachment.ID, attachment, nil, organizationIDs...)
+	})
+}
+
+func (h *Handler) UploadTenderAttachment(w http.ResponseWriter, r *http.Request) {
+	tender, username, isOwner, ok := h.tenderFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	if !isOwner {
+		respondError(w, problem(codeTenderForbidden, username))
+		return
+	}
+
+	if tender.Status == statusClosed || tender.Status == statusCancelled {
+		respondError(w, problem(codeTenderStatusConflict, tender.Status))
+		return
+	}
+
+	attachment, ok := h.receiveAttachment(w, r, attachmentEntityTender, tender.ID, username)
+	if !ok {
+		return
+	}
+
+	h.saveAttachment(w, r, attachment, keepTenderVersion(tender, username, fmt.Sprintf("attachment %s added", attachment.FileName)),
+		tender.OrganizationID)
+}
+
+func (h *Handler) ListTenderAttachments(w http.ResponseWriter, r *http.Request) {
+	tender, _, _, ok := h.tenderFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	attachments, err := h.repository(r).AttachmentsByEntity(attachmentEntityTender, tender.ID)
+	if err != nil {
+		respondError(w, fmt.Errorf("failed to select attachment from database: %w", err))
+		return
+	}
+
+	respondJSON(w, http.StatusOK, JSON{Attachments: &attachments})
+}
+
+func (h *Handler) DownloadTenderAttachment(w http.ResponseWriter, r *http.Request) {
+	tender, _, _, ok := h.tenderFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	attachment, ok := h.attachmentFromRequest(w, r, attachmentEntityTender, tender.ID)
+	if !ok {
+		return
+	}
+
+	h.streamAttachment(w, r, attachment)
+}
+
+func (h *Handler) DeleteTenderAttachment(w http.ResponseWriter, r *http.Request) {
+	tender, username, isOwner, ok := h.tenderFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	if !isOwner {
+		respondError(w, problem(codeTenderForbidden, username))
+		return
+	}
+
+	if tender.Status == statusClosed || tender.Status == statusCancelled {
+		respondError(w, problem(codeTenderStatusConflict, tender.Status))
+		return
+	}
+
+	attachment, ok := h.attachmentFromRequest(w, r, attachmentEntityTender, tender.ID)
+	if !ok {
+		return
+	}
+
+	err := h.detachAttachment(r, attachment, username, keepTenderVersion(tender, username, fmt.Sprintf("attachment %s removed", attachment.FileName)),
+		tender.OrganizationID)
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+	h.outbox.Wake()
+
+	respondJSON(w, http.StatusOK, attachment)
+}
+
+func (h *Handler) UploadBidAttachment(w http.ResponseWriter, r *http.Request) {
+	bid, tender, username, ok := h.authorBidFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	if bid.Status == bidStatusWithdrawn {
+		respondError(w, problem(codeBidWithdrawn))
+		return
+	}
+
+	attachment, ok := h.receiveAttachment(w, r, attachmentEntityBid, bid.ID, username)
+	if !ok {
+		return
+	}
+
+	h.saveAttachment(w, r, attachment, keepBidVersion(bid), h.bidOrganizationIDs(r, bid, tender)...)
+}
+
+func (h *Handler) DeleteBidAttachment(w http.ResponseWriter, r *http.Request) {
+	bid, tender, username, ok := h.authorBidFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	if bid.Status == bidStatusWithdrawn {
+		respondError(w, problem(codeBidWithdrawn))
+		return
+	}
+
+	attachment, ok := h.attachmentFromRequest(w, r, attachmentEntityBid, bid.ID)
+	if !ok {
+		return
+	}
+
+	err := h.detachAttachment(r, attachment, username, keepBidVersion(bid), h.bidOrganizationIDs(r, bid, tender)...)
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, attachment)
+}
+
+// bidReaderFromRequest loads the bid addressed by the route for its author or the tender
+// organization. It writes the error response itself and reports false on failure.
+func (h *Handler) bidReaderFromRequest(w http.ResponseWriter, r *http.Request) (*models.Bid, bool) {
+	bidID, err := uuid.Parse(mux.Vars(r)["bidId"])
+	if err != nil {
+		respondError(w, problem(codeInvalidParameter, "bidId"))
+		return nil, false
+	}
+
+	var username string
+	for name, vals := range r.URL.Query() {
+		switch name {
+		case "username":
+			username = vals[0]
+		default:
+			respondError(w, problem(codeUnknownParameter, name))
+			return nil, false
+		}
+	}
+
+	if username == "" {
+		respondError(w, problem(codeMissingUsername))
+		return nil, false
+	}
+
+	bid, err := h.repository(r).GetBidByID(bidID)
+	if err != nil {
+		respondError(w, err)
+		return nil, false
+	}
+
+	isAuthor, err := h.isBidAuthor(r, bid, username)
+	if err != nil {
+		respondError(w, fmt.Errorf("failed to check bid author: %w", err))
+		return nil, false
+	}
+
+	if isAuthor {
+		return bid, true
+	}
+
+	tenderID, err := uuid.Parse(bid.TenderID)
+	if err != nil {
+		respondError(w, fmt.Errorf("invalid tenderID format: %w", err))
+		return nil, false
+	}
+
+	tender, ok, err := h.repository(r).GetTenderByID(tenderID)
+	if err != nil {
+		respondError(w, fmt.Errorf("failed to get tender: %w", err))
+		return nil, false
+	}
+
+	if !ok {
+		respondError(w, problem(codeTenderNotFound))
+		return nil, false
+	}
+
+	organizationId, userFound, err := h.repository(r).GetOrganizationIDByUsername(username)
+	if err != nil {
+		respondError(w, fmt.Errorf("failed to get organization by username: %w", err))
+		return nil, false
+	}
+
+	if !userFound || tender.OrganizationID != organizationId {
+		respondError(w, problem(codeBidForbidden, username))
+		return nil, false
+	}
+
+	return bid, true
+}
+
+func (h *Handler) ListBidAttachments(w http.ResponseWriter, r *http.Request) {
+	bid, ok := h.bidReaderFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	attachments, err := h.repository(r).AttachmentsByEntity(attachmentEntityBid, bid.ID)
+	if err != nil {
+		respondError(w, fmt.Errorf("failed to select attachment from database: %w", err))
+		return
+	}
+
+	respondJSON(w, http.StatusOK, JSON{Attachments: &attachments})
+}
+
+func (h *Handler) DownloadBidAttachment(w http.ResponseWriter, r *http.Request) {
+	bid, ok := h.bidReaderFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	attachment, ok := h.attachmentFromRequest(w, r, attachmentEntityBid, bid.ID)
+	if !ok {
+		return
+	}
+
+	h.streamAttachment(w, r, attachment)
+}
+
+func sameAttachmentSet(attachments []models.Attachment, attachmentIDs []string) bool {
+	if len(attachments) != len(attachmentIDs) {
+		return false
+	}
+
+	ids := make(map[string]bool, len(attachmentIDs))
+	for _, id := range attachmentIDs {
+		ids[id] = true
+	}
+	for _, attachment := range attachments {
+		if !ids[attachment.ID] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,211 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+
+	"github.com/google/uuid"
+
+	"github.com/noctusha/tender/connection"
+	"github.com/noctusha/tender/models"
+)
+
+const (
+	auditEntityTender       = "tender"
+	auditEntityBid          = "bid"
+	auditEntityLot          = "lot"
+	auditEntityInvitation   = "invitation"
+	auditEntityQuestion     = "question"
+	auditEntityAttachment   = "attachment"
+	auditEntityServiceType  = "service_type"
+	auditEntityWebhook      = "webhook"
+	auditEntityNotification = "notification_preferences"
+	auditEntitySigningKey   = "signing_key"
+)
+
+var auditEntities = []string{
+	auditEntityTender, auditEntityBid, auditEntityLot, auditEntityInvitation, auditEntityQuestion,
+	auditEntityAttachment, auditEntityServiceType, auditEntityWebhook, auditEntityNotification, auditEntitySigningKey,
+}
+
+const (
+	auditActionCreate   = "create"
+	auditActionEdit     = "edit"
+	auditActionStatus   = "status"
+	auditActionRollback = "rollback"
+	auditActionDecision = "decision"
+	auditActionDelete   = "delete"
+	auditActionReplay   = "replay"
+)
+
+// audit records a change in the audit log, with the request id and the entity before and after
+// the change (nil for a creation or a deletion). repo is the repository of the transaction making
+// the change, so the change is not saved without its record.
+func (h *Handler) audit(r *http.Request, repo *connection.Repository, actor string, action string, entityType string, entityID string,
+	before interface{}, after interface{}, organizationIDs ...string) error {
+	setActor(r, actor)
+
+	event := models.AuditEvent{
+		Actor:           actor,
+		OrganizationIDs: uniqueOrganizationIDs(organizationIDs),
+		Action:          entityType + "." + action,
+		EntityType:      entityType,
+		EntityID:        entityID,
+		RequestID:       requestID(r),
+	}
+
+	var err error
+	if before != nil {
+		event.Before, err = json.Marshal(before)
+		if err != nil {
+			return fmt.Errorf("failed to encode audit event: %w", err)
+		}
+	}
+	if after != nil {
+		event.After, err = json.Marshal(after)
+		if err != nil {
+			return fmt.Errorf("failed to encode audit event: %w", err)
+		}
+	}
+
+	err = repo.NewAuditEvent(event)
+	if err != nil {
+		return fmt.Errorf("failed to record audit event: %w", err)
+	}
+	return nil
+}
+
+// auditTender loads the tender of an audited entity when the handler has not, for the
+// organizations the change concerns. It returns nil if the tender cannot be loaded.
+func (h *Handler) auditTender(r *http.Request, id string) *models.Tender {
+	tenderID, err := uuid.Parse(id)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "invalid tender id for audit event", "tender_id", id, "error", err)
+		return nil
+	}
+
+	tender, found, err := h.repository(r).GetTenderByID(tenderID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "failed to get tender for audit event", "tender_id", id, "error", err)
+		return nil
+	}
+	if !found {
+		return nil
+	}
+	return tender
+}
+
+func (h *Handler) auditTenderOrganizationID(r *http.Request, tenderID string) string {
+	tender := h.auditTender(r, tenderID)
+	if tender == nil {
+		return ""
+	}
+	return tender.OrganizationID
+}
+
+// auditedWebhook keeps the signing secret of a subscription out of the audit log.
+func auditedWebhook(subscription models.WebhookSubscription) models.WebhookSubscription {
+	subscription.Secret = ""
+	return subscription
+}
+
+func uniqueOrganizationIDs(organizationIDs []string) []string {
+	unique := []string{}
+	for _, id := range organizationIDs {
+		if id != "" && !slices.Contains(unique, id) {
+			unique = append(unique, id)
+		}
+	}
+	return unique
+}
+
+// ListAudit returns the audit log. Administrators see all of it, organization administrators
+// the changes concerning their organization.
+func (h *Handler) ListAudit(w http.ResponseWriter, r *http.Request) {
+	var (
+		username string
+		filter   connection.AuditFilter
+	)
+	for name, vals := range r.URL.Query() {
+		var err error
+		switch name {
+		case "username":
+			username = vals[0]
+		case "entity":
+			filter.EntityType = vals[0]
+			if !slices.Contains(auditEntities, filter.EntityType) {
+				err = problem(codeInvalidParameter, name)
+			}
+		case "entityId":
+			_, err = uuid.Parse(vals[0])
+			if err != nil {
+				err = problem(codeInvalidParameter, name)
+			}
+			filter.EntityID = vals[0]
+		case "actor":
+			filter.Actor = vals[0]
+		case "from":
+			filter.From, err = parseDateParam(name, vals[0], false)
+		case "to":
+			filter.To, err = parseDateParam(name, vals[0], true)
+		default:
+			var ok bool
+			ok, err = applyPageParam(&filter.Page, name, vals)
+			if err == nil && !ok {
+				err = problem(codeUnknownParameter, name)
+			}
+		}
+		if err != nil {
+			respondError(w, err)
+			return
+		}
+	}
+
+	if username == "" {
+		respondError(w, problem(codeMissingUsername))
+		return
+	}
+
+	_, userFound, err := h.repository(r).GetUserIDByUsername(username)
+	if err != nil {
+		respondError(w, fmt.Errorf("failed to get user: %w", err))
+		return
+	}
+
+	if !userFound {
+		respondError(w, problem(codeUserNotFound, username))
+		return
+	}
+
+	admin, err := h.repository(r).IsAdmin(username)
+	if err != nil {
+		respondError(w, fmt.Errorf("failed to check admin rights: %w", err))
+		return
+	}
+
+	if !admin {
+		organizationId, organizationAdmin, err := h.repository(r).OrganizationAdminOf(username)
+		if err != nil {
+			respondError(w, fmt.Errorf("failed to check admin rights: %w", err))
+			return
+		}
+
+		if !organizationAdmin {
+			respondError(w, problem(codeNotAdministrator, username))
+			return
+		}
+		filter.OrganizationID = organizationId
+	}
+
+	events, info, err := h.repository(r).AuditEvents(filter)
+	if err != nil {
+		respondError(w, fmt.Errorf("failed to select audit event from database: %w", err))
+		return
+	}
+
+	setPageLink(w, r, info)
+	respondJSON(w, http.StatusOK, JSON{AuditEvents: &events, NextCursor: info.NextCursor, Total: info.Total})
+}
@@ -3,167 +3,266 @@ package handlers
 import (
 	"encoding/json"
 	"fmt"
+	"log/slog"
 	"net/http"
-	"strconv"
 
 	"github.com/google/uuid"
 	"github.com/gorilla/mux"
 
+	"github.com/noctusha/tender/connection"
 	"github.com/noctusha/tender/models"
 )
 
-func (h *Handler) validateNewBid(bid models.Bid) error {
-	if bid.Name == "" {
-		return fmt.Errorf("name is mandatory")
-	}
-
-	if bid.TenderID == "" {
-		return fmt.Errorf("tenderId is mandatory")
-	}
-
-	if bid.AuthorType == "" {
-		return fmt.Errorf("authorType is mandatory")
-	}
-
-	if bid.AuthorId == "" {
-		return fmt.Errorf("authorId is mandatory")
-	}
-
+// isBidAuthor reports whether the user authored the bid: either the user itself
+// or any responsible of the organization the bid was submitted on behalf of.
+func (h *Handler) isBidAuthor(r *http.Request, bid *models.Bid, username string) (bool, error) {
 	switch bid.AuthorType {
-	case authorTypeUser, authorTypeOrganization:
-		break
-	default:
-		return fmt.Errorf("unknown author type: %s", bid.AuthorType)
+	case authorTypeUser:
+		userId, ok, err := h.repository(r).GetUserIDByUsername(username)
+		if err != nil {
+			return false, err
+		}
+		return ok && userId == bid.AuthorId, nil
+	case authorTypeOrganization:
+		organizationId, ok, err := h.repository(r).GetOrganizationIDByUsername(username)
+		if err != nil {
+			return false, err
+		}
+		return ok && organizationId == bid.AuthorId, nil
 	}
-
-	return nil
+	return false, nil
 }
 
 func (h *Handler) NewBid(w http.ResponseWriter, r *http.Request) {
-	var bid models.Bid
+	var req newBidRequest
 
-	err := json.NewDecoder(r.Body).Decode(&bid)
+	err := json.NewDecoder(r.Body).Decode(&req)
 	if err != nil {
-		respondJSONError(w, http.StatusBadRequest, fmt.Sprintf("failed to parse JSON format: %v", err))
+		respondError(w, problem(codeInvalidJSON, err))
 		return
 	}
 
-	if err := h.validateNewBid(bid); err != nil {
-		respondJSONError(w, http.StatusBadRequest, fmt.Sprintf("validation error: %v", err))
+	if !validateModel(w, &req.Bid) {
 		return
 	}
+	bid := req.Bid
 
-	bid.ID = uuid.New().String()
+	// The author picks the id of a signed bid, since the signature covers it.
+	switch {
+	case bid.ID != "":
+		id, err := uuid.Parse(bid.ID)
+		if err != nil {
+			respondError(w, problem(codeInvalidParameter, "id"))
+			return
+		}
+		bid.ID = id.String()
+	case req.Signature != "":
+		respondError(w, problem(codeBidIDRequired))
+		return
+	default:
+		bid.ID = uuid.New().String()
+	}
 	bid.Status = statusCreated
+	bid.Version = 1
 
 	tenderID, err := uuid.Parse(bid.TenderID)
 	if err != nil {
-		respondJSONError(w, http.StatusBadRequest, "invalid tenderID format")
+		respondError(w, problem(codeInvalidParameter, "tenderId"))
 		return
 	}
 
-	tender, ok, err := h.repo.GetTenderByID(tenderID)
+	tender, ok, err := h.repository(r).GetTenderByID(tenderID)
 	if err != nil {
-		respondJSONError(w, http.StatusInternalServerError, fmt.Sprintf("failed to get tender: %v", err))
+		respondError(w, fmt.Errorf("failed to get tender: %w", err))
 		return
 	}
 
 	if !ok {
-		respondJSONError(w, http.StatusNotFound, fmt.Sprintf("tender not found"))
+		respondError(w, problem(codeTenderNotFound))
+		return
+	}
+
+	if tender.Status != statusPublished {
+		respondError(w, problem(codeTenderStatusConflict, tender.Status))
+		return
+	}
+
+	if deadlinePassed(tender) {
+		respondError(w, problem(codeDeadlinePassed))
 		return
 	}
 
+	var bidderOrganizationId string
 	switch bid.AuthorType {
 	case authorTypeUser:
-		orginazationId, ok, err := h.repo.GetOrganizationIDByUserID(bid.AuthorId)
+		orginazationId, ok, err := h.repository(r).GetOrganizationIDByUserID(bid.AuthorId)
 		if err != nil {
-			respondJSONError(w, http.StatusInternalServerError, fmt.Sprintf("failed to get organization: %v", err))
+			respondError(w, fmt.Errorf("failed to get organization: %w", err))
 			return
 		}
 
 		if !ok {
-			respondJSONError(w, http.StatusUnauthorized, fmt.Sprintf("user not found"))
+			respondError(w, problem(codeUserNotFound, bid.AuthorId))
 			return
 		}
 
-		if tender.OrganizationID != orginazationId {
-			respondJSONError(w, http.StatusForbidden, fmt.Sprintf("user does not have permissions to this tender"))
+		bidderOrganizationId = orginazationId
+	case authorTypeOrganization:
+		exists, err := h.repository(r).OrganizationExists(bid.AuthorId)
+		if err != nil {
+			respondError(w, fmt.Errorf("failed to get organization: %w", err))
 			return
 		}
-	case authorTypeOrganization:
-		if tender.OrganizationID != bid.AuthorId {
-			respondJSONError(w, http.StatusForbidden, fmt.Sprintf("tender does not belong to organization %s", bid.AuthorId))
+
+		if !exists {
+			respondError(w, problem(codeOrganizationNotFound, bid.AuthorId))
 			return
 		}
+
+		bidderOrganizationId = bid.AuthorId
 	}
 
-	err = h.repo.NewBid(bid)
+	allowed, err := h.canAccessTender(r, tender, bidderOrganizationId)
 	if err != nil {
-		respondJSONError(w, http.StatusInternalServerError, fmt.Sprintf("failed to save bid: %v", err))
+		respondError(w, fmt.Errorf("failed to check tender invitation: %w", err))
 		return
 	}
 
-	respondJSON(w, http.StatusOK, bid)
+	if !allowed {
+		respondError(w, problem(codeOrganizationNotInvited, bidderOrganizationId))
+		return
+	}
+
+	hasLots, err := h.repository(r).TenderHasLots(tender.ID)
+	if err != nil {
+		respondError(w, fmt.Errorf("failed to get tender lots: %w", err))
+		return
+	}
+
+	switch {
+	case hasLots && bid.LotID == "":
+		respondError(w, problem(codeLotRequired))
+		return
+	case !hasLots && bid.LotID != "":
+		respondError(w, problem(codeTenderHasNoLots))
+		return
+	case hasLots:
+		lotID, err := uuid.Parse(bid.LotID)
+		if err != nil {
+			respondError(w, problem(codeInvalidParameter, "lotId"))
+			return
+		}
+
+		lot, ok, err := h.repository(r).GetLotByID(lotID)
+		if err != nil {
+			respondError(w, fmt.Errorf("failed to get lot: %w", err))
+			return
+		}
+
+		if !ok || lot.TenderID != tender.ID {
+			respondError(w, problem(codeLotNotFound))
+			return
+		}
+
+		if lot.Status != lotStatusOpen {
+			respondError(w, problem(codeLotStatusConflict, lot.Status))
+			return
+		}
+	}
+
+	signerID, ok := h.newBidSigner(w, r, req)
+	if !ok {
+		return
+	}
+
+	var signature *models.BidSignature
+	if signerID != "" {
+		signature, ok = h.checkBidSignature(w, r, bid, signerID, req.Signature)
+		if !ok {
+			return
+		}
+	}
+
+	organizationIDs := h.bidOrganizationIDs(r, &bid, tender)
+	var seal *models.BidSeal
+	err = h.repository(r).Transaction(func(repo *connection.Repository) error {
+		var err error
+		seal, err = repo.NewBid(bid, signature, connection.NewEvent(connection.EventBidCreated, bid, organizationIDs...))
+		if err != nil {
+			return fmt.Errorf("failed to save bid: %w", err)
+		}
+		return h.audit(r, repo, bid.AuthorId, auditActionCreate, auditEntityBid, bid.ID, nil, bid, organizationIDs...)
+	})
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+	h.outbox.Wake()
+
+	submission := bidSubmission{Bid: bid}
+	receipt, err := h.receipts.Receipt(seal)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "failed to sign receipt", "bid_id", bid.ID, "error", err)
+	} else {
+		submission.Receipt = &receipt
+	}
+
+	respondJSON(w, http.StatusOK, submission)
 }
 
 func (h *Handler) MyBids(w http.ResponseWriter, r *http.Request) {
 	var (
-		limit    int
-		offset   int
+		filter   connection.BidFilter
 		username string
-		err      error
 	)
 	for name, vals := range r.URL.Query() {
 		switch name {
 		case "username":
 			username = vals[0]
-		case "limit":
-			limit, err = strconv.Atoi(vals[0])
+		default:
+			ok, err := applyBidFilterParam(&filter, name, vals)
 			if err != nil {
-				respondJSONError(w, http.StatusBadRequest, fmt.Sprintf("invalid limit format: %v", err))
+				respondError(w, err)
 				return
 			}
-		case "offset":
-			offset, err = strconv.Atoi(vals[0])
-			if err != nil {
-				respondJSONError(w, http.StatusBadRequest, fmt.Sprintf("invalid offset format: %v", err))
+
+			if !ok {
+				respondError(w, problem(codeUnknownParameter, name))
 				return
 			}
-		default:
-			respondJSONError(w, http.StatusBadRequest, fmt.Sprintf("unknown parameter: %s", name))
-			return
 		}
 	}
 
-	userId, ok, err := h.repo.GetUserIDByUsername(username)
+	userId, ok, err := h.repository(r).GetUserIDByUsername(username)
 	if err != nil {
-		respondJSONError(w, http.StatusInternalServerError, fmt.Sprintf("failed to get user: %v", err))
+		respondError(w, fmt.Errorf("failed to get user: %w", err))
 		return
 	}
 
 	if !ok {
-		respondJSONError(w, http.StatusUnauthorized, "user not found")
+		respondError(w, problem(codeUserNotFound, username))
 		return
 	}
 
-	organizationId, ok, err := h.repo.GetOrganizationIDByUsername(username)
+	organizationId, ok, err := h.repository(r).GetOrganizationIDByUsername(username)
 	if err != nil {
-		respondJSONError(w, http.StatusInternalServerError, fmt.Sprintf("failed to get organization: %v", err))
+		respondError(w, fmt.Errorf("failed to get organization: %w", err))
 		return
 	}
 
 	if !ok {
-		respondJSONError(w, http.StatusUnauthorized, "user not found")
+		respondError(w, problem(codeUserNotFound, username))
 		return
 	}
 
-	bids, err := h.repo.MyBidsList(userId, organizationId, limit, offset)
+	bids, page, err := h.repository(r).MyBidsList(userId, organizationId, filter)
 	if err != nil {
-		respondJSONError(w, http.StatusBadRequest, fmt.Sprintf("failed to select bid from database: %v", err))
+		respondError(w, fmt.Errorf("failed to select bid from database: %w", err))
 		return
 	}
 
-	respondJSON(w, http.StatusOK, JSON{Bids: &bids})
+	setPageLink(w, r, page)
+	respondJSON(w, http.StatusOK, JSON{Bids: &bids, NextCursor: page.NextCursor, Total: page.Total})
 }
 
 func (h *Handler) ListBidsByTenderId(w http.ResponseWriter, r *http.Request) {
@@ -171,120 +270,266 @@ func (h *Handler) ListBidsByTenderId(w http.ResponseWriter, r *http.Request) {
 
 	tenderID, err := uuid.Parse(vars["tenderId"])
 	if err != nil {
-		respondJSONError(w, http.StatusBadRequest, "invalid tenderID format")
+		respondError(w, problem(codeInvalidParameter, "tenderId"))
 		return
 	}
 
 	var (
-		limit    int
-		offset   int
+		filter   connection.BidFilter
 		username string
 	)
 	for name, vals := range r.URL.Query() {
 		switch name {
 		case "username":
 			username = vals[0]
-		case "limit":
-			limit, err = strconv.Atoi(vals[0])
+		default:
+			ok, err := applyBidFilterParam(&filter, name, vals)
 			if err != nil {
-				respondJSONError(w, http.StatusBadRequest, fmt.Sprintf("invalid limit format: %v", err))
+				respondError(w, err)
 				return
 			}
-		case "offset":
-			offset, err = strconv.Atoi(vals[0])
-			if err != nil {
-				respondJSONError(w, http.StatusBadRequest, fmt.Sprintf("invalid offset format: %v", err))
+
+			if !ok {
+				respondError(w, problem(codeUnknownParameter, name))
 				return
 			}
-		default:
-			respondJSONError(w, http.StatusBadRequest, fmt.Sprintf("unknown parameter: %s", name))
-			return
 		}
 	}
 
 	if username == "" {
-		respondJSONError(w, http.StatusBadRequest, "username is mandatory")
+		respondError(w, problem(codeMissingUsername))
 		return
 	}
 
-	organizationId, ok, err := h.repo.GetOrganizationIDByUsername(username)
+	organizationId, ok, err := h.repository(r).GetOrganizationIDByUsername(username)
 	if err != nil {
-		respondJSONError(w, http.StatusInternalServerError, fmt.Sprintf("failed to get organization: %v", err))
+		respondError(w, fmt.Errorf("failed to get organization: %w", err))
 		return
 	}
 
 	if !ok {
-		respondJSONError(w, http.StatusUnauthorized, "user not found")
+		respondError(w, problem(codeUserNotFound, username))
 		return
 	}
 
-	tender, ok, err := h.repo.GetTenderByID(tenderID)
+	tender, ok, err := h.repository(r).GetTenderByID(tenderID)
 	if err != nil {
-		respondJSONError(w, http.StatusInternalServerError, fmt.Sprintf("failed to get tender: %v", err))
+		respondError(w, fmt.Errorf("failed to get tender: %w", err))
 		return
 	}
 
 	if !ok {
-		respondJSONError(w, http.StatusNotFound, "tender not found")
+		respondError(w, problem(codeTenderNotFound))
 		return
 	}
 
 	if tender.OrganizationID != organizationId {
-		respondJSONError(w, http.StatusForbidden, fmt.Sprintf("user %s does not have permissions to this tender", username))
+		respondError(w, problem(codeTenderForbidden, username))
 		return
 	}
 
-	bids, err := h.repo.BidsByTenderId(tenderID.String(), limit, offset)
+	bids, page, err := h.repository(r).BidsByTenderId(tenderID.String(), filter)
 	if err != nil {
-		respondJSONError(w, http.StatusBadRequest, fmt.Sprintf("failed to select bid from database: %v", err))
+		respondError(w, fmt.Errorf("failed to select bid from database: %w", err))
 		return
 	}
 
-	respondJSON(w, http.StatusOK, JSON{Bids: &bids})
+	setPageLink(w, r, page)
+	respondJSON(w, http.StatusOK, JSON{Bids: &bids, NextCursor: page.NextCursor, Total: page.Total})
 }
 
-func (h *Handler) EditBid(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) SubmitBidDecision(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 
 	bidID, err := uuid.Parse(vars["bidId"])
 	if err != nil {
-		respondJSONError(w, http.StatusBadRequest, "invalid bidID format")
+		respondError(w, problem(codeInvalidParameter, "bidId"))
 		return
 	}
 
-	bid, err := h.repo.GetBidByID(bidID)
+	var (
+		decision string
+		username string
+	)
+	for name, vals := range r.URL.Query() {
+		switch name {
+		case "decision":
+			decision = vals[0]
+		case "username":
+			username = vals[0]
+		default:
+			respondError(w, problem(codeUnknownParameter, name))
+			return
+		}
+	}
+
+	if username == "" {
+		respondError(w, problem(codeMissingUsername))
+		return
+	}
+
+	var status string
+	switch decision {
+	case decisionApproved:
+		status = bidStatusApproved
+	case decisionRejected:
+		status = bidStatusRejected
+	default:
+		respondError(w, problem(codeInvalidDecision, decision))
+		return
+	}
+
+	bid, err := h.repository(r).GetBidByID(bidID)
 	if err != nil {
-		respondJSONError(w, http.StatusNotFound, fmt.Sprintf("failed to get bid: %v", err))
+		respondError(w, err)
+		return
+	}
+
+	tenderID, err := uuid.Parse(bid.TenderID)
+	if err != nil {
+		respondError(w, fmt.Errorf("invalid tenderID format: %w", err))
+		return
+	}
+
+	tender, ok, err := h.repository(r).GetTenderByID(tenderID)
+	if err != nil {
+		respondError(w, fmt.Errorf("failed to get tender: %w", err))
+		return
+	}
+
+	if !ok {
+		respondError(w, problem(codeTenderNotFound))
 		return
 	}
 
-	var updatedBid models.Bid
-	err = json.NewDecoder(r.Body).Decode(&updatedBid)
+	organizationId, userFound, err := h.repository(r).GetOrganizationIDByUsername(username)
 	if err != nil {
-		respondJSONError(w, http.StatusBadRequest, "invalid JSON format")
+		respondError(w, fmt.Errorf("failed to get organization by username: %w", err))
+		return
+	}
+
+	if !userFound {
+		respondError(w, problem(codeUserNotFound, username))
+		return
+	}
+
+	if tender.OrganizationID != organizationId {
+		respondError(w, problem(codeTenderForbidden, username))
+		return
+	}
+
+	if bid.Status != statusCreated {
+		respondError(w, problem(codeBidStatusConflict, bid.Status))
+		return
+	}
+
+	if status == bidStatusApproved && bid.NeedsReconfirmation {
+		respondError(w, problem(codeBidNotReconfirmed))
+		return
+	}
+
+	if status == bidStatusApproved && tender.Status != statusPublished {
+		respondError(w, problem(codeTenderStatusConflict, tender.Status))
 		return
 	}
 
-	err = h.repo.AddBidVersion(&models.BidVersion{
-		BidID:       bid.ID,
-		Name:        bid.Name,
-		Description: bid.Description,
+	previous := *bid
+	bid.Status = status
+
+	eventType := connection.EventBidRejected
+	if status == bidStatusApproved {
+		eventType = connection.EventBidApproved
+	}
+	event := connection.NewEvent(eventType, bid, h.bidOrganizationIDs(r, bid, tender)...)
+
+	err = h.repository(r).Transaction(func(repo *connection.Repository) error {
+		var err error
+		// Approving a bid closes the tender, or its lot and the tender once every lot is settled.
+		if status == bidStatusApproved && bid.LotID != "" {
+			_, err = repo.AwardLot(bid.LotID, bid.ID, event)
+		} else {
+			err = repo.DecideBid(bid, status, event)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to submit decision: %w", err)
+		}
+		return h.audit(r, repo, username, auditActionDecision, auditEntityBid, bid.ID, previous, bid, event.OrganizationIDs...)
 	})
 	if err != nil {
-		respondJSONError(w, http.StatusInternalServerError, fmt.Sprintf("failed to add bid version: %v", err))
+		respondError(w, err)
 		return
 	}
+	h.outbox.Wake()
 
+	respondJSON(w, http.StatusOK, bid)
+}
+
+func (h *Handler) EditBid(w http.ResponseWriter, r *http.Request) {
+	bid, tender, username, ok := h.authorBidFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	if bid.Status == bidStatusWithdrawn {
+		respondError(w, problem(codeBidWithdrawn))
+		return
+	}
+
+	var updatedBid editBidRequest
+	err := json.NewDecoder(r.Body).Decode(&updatedBid)
+	if err != nil {
+		respondError(w, problem(codeInvalidJSON, err))
+		return
+	}
+
+	if !validateModelUpdate(w, &updatedBid.Bid) {
+		return
+	}
+
+	previous := *bid
 	if updatedBid.Name != "" {
 		bid.Name = updatedBid.Name
 	}
 	if updatedBid.Description != "" {
 		bid.Description = updatedBid.Description
 	}
+	bid.NeedsReconfirmation = false
+	bid.Version++
+
+	userId, ok := h.userByUsername(w, r, username)
+	if !ok {
+		return
+	}
 
-	err = h.repo.UpdateBid(bid)
+	signature, ok := h.checkBidSignature(w, r, *bid, userId, updatedBid.Signature)
+	if !ok {
+		return
+	}
+
+	organizationIDs := h.bidOrganizationIDs(r, bid, tender)
+	err = h.repository(r).Transaction(func(repo *connection.Repository) error {
+		err := repo.AddBidVersion(&models.BidVersion{
+			BidID:       bid.ID,
+			Name:        previous.Name,
+			Description: previous.Description,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to add bid version: %w", err)
+		}
+
+		err = repo.UpdateBid(bid, signature)
+		if err != nil {
+			return fmt.Errorf("failed to update bid: %w", err)
+		}
+
+		_, err = repo.SealBid(bid.ID, connection.SealActionEdit)
+		if err != nil {
+			return fmt.Errorf("failed to seal bid: %w", err)
+		}
+		return h.audit(r, repo, username, auditActionEdit, auditEntityBid, bid.ID, previous, bid, organizationIDs...)
+	})
 	if err != nil {
-		respondJSONError(w, http.StatusInternalServerError, fmt.Sprintf("failed to update bid: %v", err))
+		respondError(w, err)
 		return
 	}
 
@@ -294,41 +539,60 @@ func (h *Handler) EditBid(w http.ResponseWriter, r *http.Request) {
 func (h *Handler) RollbackBid(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 
-	bidID, err := uuid.Parse(vars["bidId"])
-	if err != nil {
-		respondJSONError(w, http.StatusBadRequest, "invalid bidID format")
+	bid, tender, username, ok := h.authorBidFromRequest(w, r)
+	if !ok {
 		return
 	}
 
-	bid, err := h.repo.GetBidByID(bidID)
-	if err != nil {
-		respondJSONError(w, http.StatusNotFound, fmt.Sprintf("failed to get bid: %v", err))
+	if bid.Status == bidStatusWithdrawn {
+		respondError(w, problem(codeBidWithdrawn))
 		return
 	}
 
 	version, err := uuid.Parse(vars["version"])
 	if err != nil {
-		respondJSONError(w, http.StatusBadRequest, "invalid version format")
+		respondError(w, problem(codeInvalidParameter, "version"))
 		return
 	}
 
-	bidVer, err := h.repo.GetBidVersionByID(version)
+	bidVer, err := h.repository(r).GetBidVersionByID(version)
 	if err != nil {
-		respondJSONError(w, http.StatusNotFound, fmt.Sprintf("failed to get bid version: %v", err))
+		respondError(w, err)
 		return
 	}
 
 	if bidVer.BidID != bid.ID {
-		respondJSONError(w, http.StatusForbidden, "bid version does not belong to bid")
+		respondError(w, problem(codeBidVersionMismatch))
 		return
 	}
 
+	previous := *bid
 	bid.Name = bidVer.Name
 	bid.Description = bidVer.Description
+	bid.NeedsReconfirmation = false
+	bid.Version++
+
+	organizationIDs := h.bidOrganizationIDs(r, bid, tender)
+	err = h.repository(r).Transaction(func(repo *connection.Repository) error {
+		// The version comes back with the signature it was submitted with.
+		err := repo.UpdateBid(bid, bidVer.Signature)
+		if err != nil {
+			return fmt.Errorf("failed to update bid: %w", err)
+		}
 
-	err = h.repo.UpdateBid(bid)
+		err = repo.RestoreAttachments(attachmentEntityBid, bid.ID, bidVer.AttachmentIDs)
+		if err != nil {
+			return fmt.Errorf("failed to restore attachments: %w", err)
+		}
+
+		_, err = repo.SealBid(bid.ID, connection.SealActionRollback)
+		if err != nil {
+			return fmt.Errorf("failed to seal bid: %w", err)
+		}
+		return h.audit(r, repo, username, auditActionRollback, auditEntityBid, bid.ID, previous, bid, organizationIDs...)
+	})
 	if err != nil {
-		respondJSONError(w, http.StatusInternalServerError, fmt.Sprintf("failed to update bid: %v", err))
+		respondError(w, err)
 		return
 	}
 
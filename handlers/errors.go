@@ -0,0 +1,335 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/noctusha/tender/connection"
+)
+
+// Error codes are part of the API: clients branch on them, so a code is never renamed or reused.
+const (
+	codeInternal         = "internal_error"
+	codeRouteNotFound    = "route_not_found"
+	codeMethodNotAllowed = "method_not_allowed"
+
+	codeValidation               = "validation_error"
+	codeUnknownParameter         = "unknown_parameter"
+	codeInvalidParameter         = "invalid_parameter"
+	codeInvalidDate              = "invalid_date"
+	codeInvalidStatus            = "invalid_status"
+	codeInvalidNotificationState = "invalid_notification_status"
+	codeInvalidLimit             = "invalid_limit"
+	codeInvalidOffset            = "invalid_offset"
+	codeInvalidSort              = "invalid_sort"
+	codeInvalidCursor            = "invalid_cursor"
+	codeInvalidDecision          = "invalid_decision"
+	codeInvalidJSON              = "invalid_json"
+	codeInvalidMultipart         = "invalid_multipart"
+	codeInvalidLastEventID       = "invalid_last_event_id"
+	codeMissingUsername          = "missing_username"
+	codeMissingSearchQuery       = "missing_search_query"
+	codeSearchQueryTooLong       = "search_query_too_long"
+	codeMissingFile              = "missing_file"
+	codeEmptyFile                = "empty_file"
+	codeInvalidFileName          = "invalid_file_name"
+	codeAttachmentTooLarge       = "attachment_too_large"
+	codeContentTypeNotAllowed    = "content_type_not_allowed"
+	codeContentTypeMismatch      = "content_type_mismatch"
+	codeUnknownServiceType       = "unknown_service_type"
+	codeServiceTypeNameRequired  = "service_type_name_required"
+	codeServiceTypeNameTooLong   = "service_type_name_too_long"
+	codeServiceTypeNameReserved  = "service_type_name_reserved"
+	codeServiceTypeCycle         = "service_type_cycle"
+	codeInvalidDeadline          = "invalid_deadline"
+	codeLotRequired              = "lot_required"
+	codeTenderHasNoLots          = "tender_has_no_lots"
+	codeReasonRequired           = "reason_required"
+	codeQuestionRequired         = "question_required"
+	codeAnswerRequired           = "answer_required"
+	codeInvalidEmail             = "invalid_email"
+	codeInvalidWebhookURL        = "invalid_webhook_url"
+	codeWebhookURLNotPublic      = "webhook_url_not_public"
+	codeInvalidPublicKey         = "invalid_public_key"
+	codeSignatureRequired        = "signature_required"
+	codeSignerHasNoKey           = "signer_has_no_key"
+	codeSignedByRequired         = "signed_by_required"
+	codeInvalidSignature         = "invalid_signature"
+	codeBidIDRequired            = "bid_id_required"
+	codeSelfInvitation           = "self_invitation"
+
+	codeUserNotFound = "user_not_found"
+
+	codeTenderForbidden             = "tender_forbidden"
+	codeBidForbidden                = "bid_forbidden"
+	codeNotBidAuthor                = "not_bid_author"
+	codeNotAdministrator            = "not_administrator"
+	codeNotOrganizationMember       = "not_organization_member"
+	codeOrganizationNotInvited      = "organization_not_invited"
+	codeTenderVersionMismatch       = "tender_version_mismatch"
+	codeBidVersionMismatch          = "bid_version_mismatch"
+	codeOwnTenderQuestion           = "own_tender_question"
+	codeInvitationRevokeForbidden   = "invitation_revoke_forbidden"
+	codeInvitationOtherOrganization = "invitation_other_organization"
+
+	codeTenderNotFound            = "tender_not_found"
+	codeBidNotFound               = "bid_not_found"
+	codeLotNotFound               = "lot_not_found"
+	codeTenderVersionNotFound     = "tender_version_not_found"
+	codeBidVersionNotFound        = "bid_version_not_found"
+	codeOrganizationNotFound      = "organization_not_found"
+	codeWebhookNotFound           = "webhook_not_found"
+	codeWebhookDeliveryNotFound   = "webhook_delivery_not_found"
+	codeSigningKeyNotFound        = "signing_key_not_found"
+	codeServiceTypeNotFound       = "service_type_not_found"
+	codeParentServiceTypeNotFound = "parent_service_type_not_found"
+	codeQuestionNotFound          = "question_not_found"
+	codeNotificationNotFound      = "notification_not_found"
+	codeInvitationNotFound        = "invitation_not_found"
+	codeBidNotSigned              = "bid_not_signed"
+	codeBidNotSealed              = "bid_not_sealed"
+	codeAttachmentNotFound        = "attachment_not_found"
+	codeAttachmentContentNotFound = "attachment_content_not_found"
+
+	codeBidWithdrawn               = "bid_withdrawn"
+	codeTenderStatusConflict       = "tender_status_conflict"
+	codeLotStatusConflict          = "lot_status_conflict"
+	codeBidStatusConflict          = "bid_status_conflict"
+	codeBidExists                  = "bid_exists"
+	codeBidChanged                 = "bid_changed"
+	codeInvitationStatusConflict   = "invitation_status_conflict"
+	codeDeadlinePassed             = "deadline_passed"
+	codeServiceTypeInUse           = "service_type_in_use"
+	codeServiceTypeExists          = "service_type_exists"
+	codeOrganizationAlreadyInvited = "organization_already_invited"
+	codeDeliveryNotFailed          = "delivery_not_failed"
+	codeTenderClosedForLots        = "tender_closed_for_lots"
+	codeBidNotWithdrawable         = "bid_not_withdrawable"
+	codeBidNotWithdrawn            = "bid_not_withdrawn"
+	codeTenderNotPublished         = "tender_not_published"
+	codeTenderNotInviteOnly        = "tender_not_invite_only"
+	codeBidNotReconfirmed          = "bid_not_reconfirmed"
+	codeBidReconfirmationNotNeeded = "bid_reconfirmation_not_needed"
+	codeTenderHasOpenLots          = "tender_has_open_lots"
+)
+
+type errorEntry struct {
+	status  int
+	message string
+}
+
+// errorCatalog gives every code its HTTP status and the English format of its message; the
+// arguments of the error fill the format in. errorMessages holds the other languages.
+var errorCatalog = map[string]errorEntry{
+	codeInternal:         {http.StatusInternalServerError, "internal server error"},
+	codeRouteNotFound:    {http.StatusNotFound, "route not found"},
+	codeMethodNotAllowed: {http.StatusMethodNotAllowed, "method not allowed"},
+
+	codeValidation:               {http.StatusBadRequest, "validation error: %s"},
+	codeUnknownParameter:         {http.StatusBadRequest, "unknown parameter: %s"},
+	codeInvalidParameter:         {http.StatusBadRequest, "invalid %s format"},
+	codeInvalidDate:              {http.StatusBadRequest, "invalid %s format: expected RFC 3339 timestamp or YYYY-MM-DD date"},
+	codeInvalidStatus:            {http.StatusBadRequest, "invalid status: %s"},
+	codeInvalidNotificationState: {http.StatusBadRequest, "invalid status: %s, expected one of %s, %s, %s"},
+	codeInvalidLimit:             {http.StatusBadRequest, "limit must be between 1 and %d"},
+	codeInvalidOffset:            {http.StatusBadRequest, "offset must not be negative"},
+	codeInvalidSort:              {http.StatusBadRequest, "unknown sort field: %s"},
+	codeInvalidCursor:            {http.StatusBadRequest, "invalid cursor"},
+	codeInvalidDecision:          {http.StatusBadRequest, "invalid decision: %s"},
+	codeInvalidJSON:              {http.StatusBadRequest, "failed to parse JSON format: %v"},
+	codeInvalidMultipart:         {http.StatusBadRequest, "failed to parse multipart form: %v"},
+	codeInvalidLastEventID:       {http.StatusBadRequest, "invalid last event id"},
+	codeMissingUsername:          {http.StatusBadRequest, "missing username"},
+	codeMissingSearchQuery:       {http.StatusBadRequest, "missing search query"},
+	codeSearchQueryTooLong:       {http.StatusBadRequest, "search query is longer than %d characters"},
+	codeMissingFile:              {http.StatusBadRequest, "missing file"},
+	codeEmptyFile:                {http.StatusBadRequest, "file is empty"},
+	codeInvalidFileName:          {http.StatusBadRequest, "invalid file name"},
+	codeAttachmentTooLarge:       {http.StatusRequestEntityTooLarge, "attachment exceeds %d bytes"},
+	codeContentTypeNotAllowed:    {http.StatusUnsupportedMediaType, "content type %q is not allowed"},
+	codeContentTypeMismatch:      {http.StatusUnsupportedMediaType, "file content (%s) does not match content type %s"},
+	codeUnknownServiceType:       {http.StatusBadRequest, "unknown service type: %s"},
+	codeServiceTypeNameRequired:  {http.StatusBadRequest, "validation error: name is mandatory"},
+	codeServiceTypeNameTooLong:   {http.StatusBadRequest, "validation error: name is longer than %d characters"},
+	codeServiceTypeNameReserved:  {http.StatusBadRequest, "validation error: name %s is reserved"},
+	codeServiceTypeCycle:         {http.StatusBadRequest, "service type cannot be moved under itself or its child"},
+	codeInvalidDeadline:          {http.StatusBadRequest, "validation error: deadline must be in the future"},
+	codeLotRequired:              {http.StatusBadRequest, "validation error: lotId is mandatory for a tender with lots"},
+	codeTenderHasNoLots:          {http.StatusBadRequest, "validation error: tender has no lots"},
+	codeReasonRequired:           {http.StatusBadRequest, "validation error: reason is mandatory"},
+	codeQuestionRequired:         {http.StatusBadRequest, "validation error: question is mandatory"},
+	codeAnswerRequired:           {http.StatusBadRequest, "validation error: answer is mandatory"},
+	codeInvalidEmail:             {http.StatusBadRequest, "validation error: invalid email address"},
+	codeInvalidWebhookURL:        {http.StatusBadRequest, "validation error: url must be an absolute http or https URL"},
+	codeWebhookURLNotPublic:      {http.StatusBadRequest, "validation error: url must resolve to public addresses only"},
+	codeInvalidPublicKey:         {http.StatusBadRequest, "validation error: publicKey must be a base64 %s public key of %d bytes"},
+	codeSignatureRequired:        {http.StatusBadRequest, "validation error: signature is mandatory, the signer has registered a signing key"},
+	codeSignerHasNoKey:           {http.StatusBadRequest, "validation error: the signer has not registered a signing key"},
+	codeSignedByRequired:         {http.StatusBadRequest, "validation error: signedBy is mandatory for a signed bid of an organization"},
+	codeInvalidSignature:         {http.StatusBadRequest, "validation error: invalid bid signature"},
+	codeBidIDRequired:            {http.StatusBadRequest, "validation error: id is mandatory for a signed bid"},
+	codeSelfInvitation:           {http.StatusBadRequest, "organization cannot invite itself"},
+
+	codeUserNotFound: {http.StatusUnauthorized, "user not found: %s"},
+
+	codeTenderForbidden:             {http.StatusForbidden, "user %s does not have permissions to this tender"},
+	codeBidForbidden:                {http.StatusForbidden, "user %s does not have permissions to this bid"},
+	codeNotBidAuthor:                {http.StatusForbidden, "user %s is not the author of this bid"},
+	codeNotAdministrator:            {http.StatusForbidden, "user %s is not an administrator"},
+	codeNotOrganizationMember:       {http.StatusForbidden, "user %s does not belong to organization %s"},
+	codeOrganizationNotInvited:      {http.StatusForbidden, "organization %s is not invited to this tender"},
+	codeTenderVersionMismatch:       {http.StatusForbidden, "tender version does not belong to tender"},
+	codeBidVersionMismatch:          {http.StatusForbidden, "bid version does not belong to bid"},
+	codeOwnTenderQuestion:           {http.StatusForbidden, "tender organization cannot ask questions on its own tender"},
+	codeInvitationRevokeForbidden:   {http.StatusForbidden, "only the tender organization can revoke invitations"},
+	codeInvitationOtherOrganization: {http.StatusForbidden, "invitation is addressed to another organization"},
+
+	codeTenderNotFound:            {http.StatusNotFound, "tender not found"},
+	codeBidNotFound:               {http.StatusNotFound, "bid not found"},
+	codeLotNotFound:               {http.StatusNotFound, "lot not found"},
+	codeTenderVersionNotFound:     {http.StatusNotFound, "tender version not found"},
+	codeBidVersionNotFound:        {http.StatusNotFound, "bid version not found"},
+	codeOrganizationNotFound:      {http.StatusNotFound, "organization not found: %s"},
+	codeWebhookNotFound:           {http.StatusNotFound, "webhook subscription not found"},
+	codeWebhookDeliveryNotFound:   {http.StatusNotFound, "webhook delivery not found"},
+	codeSigningKeyNotFound:        {http.StatusNotFound, "signing key not registered"},
+	codeServiceTypeNotFound:       {http.StatusNotFound, "service type not found"},
+	codeParentServiceTypeNotFound: {http.StatusNotFound, "parent service type not found"},
+	codeQuestionNotFound:          {http.StatusNotFound, "question not found"},
+	codeNotificationNotFound:      {http.StatusNotFound, "notification not found"},
+	codeInvitationNotFound:        {http.StatusNotFound, "invitation not found"},
+	codeBidNotSigned:              {http.StatusNotFound, "bid is not signed"},
+	codeBidNotSealed:              {http.StatusNotFound, "bid has not been sealed"},
+	codeAttachmentNotFound:        {http.StatusNotFound, "attachment not found"},
+	codeAttachmentContentNotFound: {http.StatusNotFound, "attachment content not found"},
+
+	codeBidWithdrawn:               {http.StatusConflict, "withdrawn bid cannot be edited"},
+	codeTenderStatusConflict:       {http.StatusConflict, "tender is %s"},
+	codeLotStatusConflict:          {http.StatusConflict, "lot is already %s"},
+	codeBidStatusConflict:          {http.StatusConflict, "bid is already %s"},
+	codeBidExists:                  {http.StatusConflict, "bid %s already exists"},
+	codeBidChanged:                 {http.StatusConflict, "bid has been changed by another request"},
+	codeInvitationStatusConflict:   {http.StatusConflict, "invitation is already %s"},
+	codeDeadlinePassed:             {http.StatusConflict, "tender deadline has passed"},
+	codeServiceTypeInUse:           {http.StatusConflict, "service type %s has child categories or is used by tenders"},
+	codeServiceTypeExists:          {http.StatusConflict, "service type %s already exists"},
+	codeOrganizationAlreadyInvited: {http.StatusConflict, "organization %s is already invited"},
+	codeDeliveryNotFailed:          {http.StatusConflict, "only failed deliveries can be replayed, delivery is %s"},
+	codeTenderClosedForLots:        {http.StatusConflict, "cannot add lot to tender in status %s"},
+	codeBidNotWithdrawable:         {http.StatusConflict, "bid in status %s cannot be withdrawn"},
+	codeBidNotWithdrawn:            {http.StatusConflict, "only a withdrawn bid can be resubmitted"},
+	codeTenderNotPublished:         {http.StatusConflict, "questions can only be asked on a published tender"},
+	codeTenderNotInviteOnly:        {http.StatusConflict, "invitations are only available for invite-only tenders"},
+	codeBidNotReconfirmed:          {http.StatusConflict, "bid has not been reconfirmed after the tender was amended"},
+	codeBidReconfirmationNotNeeded: {http.StatusConflict, "bid does not need reconfirmation"},
+	codeTenderHasOpenLots:          {http.StatusConflict, "tender has open lots"},
+}
+
+// apiError is an error the client caused or may act on, named by a code of the catalog.
+type apiError struct {
+	code string
+	args []interface{}
+}
+
+func problem(code string, args ...interface{}) error {
+	return &apiError{code: code, args: args}
+}
+
+func (e *apiError) Error() string {
+	return formatMessage(languageEnglish, e.code, e.args)
+}
+
+// problemDetails is an RFC 7807 problem. Type is about:blank, so Title is the text of Status;
+// Code tells the problems apart.
+type problemDetails struct {
+	Type      string `json:"type"`
+	Title     string `json:"title"`
+	Status    int    `json:"status"`
+	Detail    string `json:"detail"`
+	Code      string `json:"code"`
+	RequestID string `json:"requestId,omitempty"`
+
+	// Errors lists the fields of a validation problem, each with the rule it broke.
+	Errors []fieldError `json:"errors,omitempty"`
+}
+
+// classifyError maps an error to its code, status and message in language. Errors of the catalog
+// and domain errors of the repository keep their code; anything else is a failure of the
+// service, and its message is not shown to the client.
+func classifyError(err error, language string) (string, int, string) {
+	var apiErr *apiError
+	if errors.As(err, &apiErr) {
+		return apiErr.code, errorCatalog[apiErr.code].status, formatMessage(language, apiErr.code, apiErr.args)
+	}
+
+	var domainErr *connection.Error
+	if errors.As(err, &domainErr) {
+		if entry, ok := errorCatalog[domainErr.Code]; ok {
+			return domainErr.Code, entry.status, formatMessage(language, domainErr.Code, domainErr.Args)
+		}
+		switch {
+		case errors.Is(domainErr, connection.ErrNotFound):
+			return domainErr.Code, http.StatusNotFound, domainErr.Error()
+		case errors.Is(domainErr, connection.ErrConflict):
+			return domainErr.Code, http.StatusConflict, domainErr.Error()
+		case errors.Is(domainErr, connection.ErrForbidden):
+			return domainErr.Code, http.StatusForbidden, domainErr.Error()
+		case errors.Is(domainErr, connection.ErrInvalid):
+			return domainErr.Code, http.StatusBadRequest, domainErr.Error()
+		}
+	}
+
+	if errors.Is(err, connection.ErrInvalidCursor) {
+		return codeInvalidCursor, http.StatusBadRequest, formatMessage(language, codeInvalidCursor, nil)
+	}
+
+	return codeInternal, http.StatusInternalServerError, formatMessage(language, codeInternal, nil)
+}
+
+// respondError writes err as an application/problem+json response. This is the only place that
+// decides the status of an error. The message is in the language the Language middleware chose.
+func respondError(w http.ResponseWriter, err error) {
+	language := responseLanguage(w)
+	code, status, message := classifyError(err, language)
+
+	requestID := responseRequestID(w)
+	if status == http.StatusInternalServerError {
+		slog.Error("request failed", "request_id", requestID, "error", err)
+	}
+
+	response, marshalErr := json.Marshal(problemDetails{
+		Type:      "about:blank",
+		Title:     http.StatusText(status),
+		Status:    status,
+		Detail:    message,
+		Code:      code,
+		RequestID: requestID,
+		Errors:    fieldErrors(err, language),
+	})
+	if marshalErr != nil {
+		slog.Error("failed to encode problem", "request_id", requestID, "error", marshalErr)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(status)
+	_, writeErr := w.Write(response)
+	if writeErr != nil {
+		slog.Error("failed to write response", "request_id", requestID, "error", writeErr)
+	}
+}
+
+// NotFound answers a request no route matches, in the format of the other errors.
+func NotFound(w http.ResponseWriter, r *http.Request) {
+	setLanguage(w, r)
+	respondError(w, problem(codeRouteNotFound))
+}
+
+// MethodNotAllowed answers a request to a known path with a method it does not serve.
+func MethodNotAllowed(w http.ResponseWriter, r *http.Request) {
+	setLanguage(w, r)
+	respondError(w, problem(codeMethodNotAllowed))
+}
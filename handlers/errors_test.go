@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/noctusha/tender/connection"
+)
+
+func TestClassifyError(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		language   string
+		wantCode   string
+		wantStatus int
+		wantDetail string
+	}{
+		{name: "catalog error", err: problem(codeTenderNotFound), language: languageEnglish,
+			wantCode: codeTenderNotFound, wantStatus: http.StatusNotFound, wantDetail: "tender not found"},
+		{name: "catalog error in russian", err: problem(codeInvalidParameter, "tenderId"), language: languageRussian,
+			wantCode: codeInvalidParameter, wantStatus: http.StatusBadRequest, wantDetail: "неверный формат tenderId"},
+		{name: "wrapped catalog error", err: fmt.Errorf("failed to resubmit bid: %w", problem(codeBidNotWithdrawn)), language: languageEnglish,
+			wantCode: codeBidNotWithdrawn, wantStatus: http.StatusConflict, wantDetail: formatMessage(languageEnglish, codeBidNotWithdrawn, nil)},
+		{name: "domain error of the catalog", err: fmt.Errorf("failed to cancel lot: %w", connection.Conflict(codeLotStatusConflict, "lot is already %s", "AWARDED")),
+			language: languageRussian, wantCode: codeLotStatusConflict, wantStatus: http.StatusConflict, wantDetail: "лот уже в статусе AWARDED"},
+		{name: "domain not found", err: connection.NotFound("thing_not_found", "thing %s not found", "x"), language: languageRussian,
+			wantCode: "thing_not_found", wantStatus: http.StatusNotFound, wantDetail: "thing x not found"},
+		{name: "domain conflict", err: connection.Conflict("thing_conflict", "thing is busy"), language: languageEnglish,
+			wantCode: "thing_conflict", wantStatus: http.StatusConflict, wantDetail: "thing is busy"},
+		{name: "domain forbidden", err: connection.Forbidden("thing_forbidden", "not yours"), language: languageEnglish,
+			wantCode: "thing_forbidden", wantStatus: http.StatusForbidden, wantDetail: "not yours"},
+		{name: "domain invalid", err: connection.Invalid("thing_invalid", "bad thing"), language: languageEnglish,
+			wantCode: "thing_invalid", wantStatus: http.StatusBadRequest, wantDetail: "bad thing"},
+		{name: "invalid cursor", err: fmt.Errorf("%w: cursor was issued for a different sort", connection.ErrInvalidCursor), language: languageEnglish,
+			wantCode: codeInvalidCursor, wantStatus: http.StatusBadRequest, wantDetail: "invalid cursor"},
+		{name: "failure of the service", err: errors.New("pq: connection refused"), language: languageEnglish,
+			wantCode: codeInternal, wantStatus: http.StatusInternalServerError, wantDetail: "internal server error"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, status, detail := classifyError(tt.err, tt.language)
+			if code != tt.wantCode || status != tt.wantStatus || detail != tt.wantDetail {
+				t.Errorf("classifyError = %q, %d, %q, want %q, %d, %q", code, status, detail, tt.wantCode, tt.wantStatus, tt.wantDetail)
+			}
+		})
+	}
+}
+
+func TestErrorCatalog(t *testing.T) {
+	for code, entry := range errorCatalog {
+		if entry.status < 400 || entry.status > 599 {
+			t.Errorf("%s has status %d, want an error status", code, entry.status)
+		}
+		if entry.message == "" {
+			t.Errorf("%s has no message", code)
+		}
+	}
+}
+
+func TestRespondError(t *testing.T) {
+	vs := []violation{{field: "name", rule: "required"}, {field: "authorId", rule: "format", limit: "uuid"}}
+
+	tests := []struct {
+		name     string
+		language string
+		err      error
+		want     problemDetails
+	}{
+		{
+			name:     "problem",
+			language: languageEnglish,
+			err:      problem(codeTenderNotFound),
+			want: problemDetails{Type: "about:blank", Title: "Not Found", Status: http.StatusNotFound,
+				Detail: "tender not found", Code: codeTenderNotFound, RequestID: "req-1"},
+		},
+		{
+			name:     "internal error hides its cause",
+			language: languageRussian,
+			err:      errors.New("pq: password authentication failed"),
+			want: problemDetails{Type: "about:blank", Title: "Internal Server Error", Status: http.StatusInternalServerError,
+				Detail: "внутренняя ошибка сервера", Code: codeInternal, RequestID: "req-1"},
+		},
+		{
+			name:     "validation problem lists the fields",
+			language: languageRussian,
+			err:      validationProblem(vs),
+			want: problemDetails{Type: "about:blank", Title: "Bad Request", Status: http.StatusBadRequest,
+				Detail: "ошибка валидации: name: обязательное значение не указано; authorId: значение не является корректным uuid",
+				Code:   codeValidation, RequestID: "req-1",
+				Errors: []fieldError{
+					{Field: "name", Rule: "required", Message: "обязательное значение не указано"},
+					{Field: "authorId", Rule: "format", Message: "значение не является корректным uuid"},
+				}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			w.Header().Set("Content-Language", tt.language)
+			w.Header().Set("X-Request-ID", "req-1")
+
+			respondError(w, tt.err)
+
+			if w.Code != tt.want.Status {
+				t.Errorf("status = %d, want %d", w.Code, tt.want.Status)
+			}
+			if got := w.Header().Get("Content-Type"); got != "application/problem+json" {
+				t.Errorf("Content-Type = %q, want application/problem+json", got)
+			}
+			var got problemDetails
+			err := json.Unmarshal(w.Body.Bytes(), &got)
+			if err != nil {
+				t.Fatalf("decode problem: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("problem = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
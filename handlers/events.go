@@ -0,0 +1,190 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+
+	"github.com/noctusha/tender/connection"
+	"github.com/noctusha/tender/models"
+)
+
+const defaultHeartbeatInterval = 15 * time.Second
+
+// TenderEvents streams the events of a tender as Server-Sent Events: status changes, new versions,
+// amendments and answers to questions. Each event carries its stream position as the SSE id, so a
+// client that reconnects with Last-Event-ID (or ?lastEventId=) receives what it missed.
+func (h *Handler) TenderEvents(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	tenderID, err := uuid.Parse(vars["tenderId"])
+	if err != nil {
+		respondError(w, problem(codeInvalidParameter, "tenderId"))
+		return
+	}
+
+	var (
+		username    string
+		lastEventID = r.Header.Get("Last-Event-ID")
+	)
+	for name, vals := range r.URL.Query() {
+		switch name {
+		case "username":
+			username = vals[0]
+		case "lastEventId":
+			if lastEventID == "" {
+				lastEventID = vals[0]
+			}
+		default:
+			respondError(w, problem(codeUnknownParameter, name))
+			return
+		}
+	}
+
+	if username == "" {
+		respondError(w, problem(codeMissingUsername))
+		return
+	}
+
+	var last connection.StreamPosition
+	if lastEventID != "" {
+		last, err = connection.ParseStreamPosition(lastEventID)
+		if err != nil {
+			respondError(w, problem(codeInvalidLastEventID))
+			return
+		}
+	}
+
+	tender, ok, err := h.repository(r).GetTenderByID(tenderID)
+	if err != nil {
+		respondError(w, fmt.Errorf("failed to get tender: %w", err))
+		return
+	}
+
+	if !ok {
+		respondError(w, problem(codeTenderNotFound))
+		return
+	}
+
+	organizationId, userFound, err := h.repository(r).GetOrganizationIDByUsername(username)
+	if err != nil {
+		respondError(w, fmt.Errorf("failed to get organization by username: %w", err))
+		return
+	}
+
+	if !userFound {
+		respondError(w, problem(codeUserNotFound, username))
+		return
+	}
+
+	allowed, err := h.canAccessTender(r, tender, organizationId)
+	if err != nil {
+		respondError(w, fmt.Errorf("failed to check tender invitation: %w", err))
+		return
+	}
+
+	if !allowed || (tender.Status == statusCreated && tender.OrganizationID != organizationId) {
+		respondError(w, problem(codeTenderForbidden, username))
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		respondError(w, fmt.Errorf("streaming is not supported"))
+		return
+	}
+
+	// Subscribe before reading the outbox, so nothing recorded in between is missed.
+	var live <-chan models.Event
+	if h.broker != nil {
+		events, unsubscribe := h.broker.Subscribe()
+		defer unsubscribe()
+		live = events
+	}
+
+	if lastEventID == "" {
+		last, err = h.repository(r).LastTenderEventPosition(tender.ID)
+		if err != nil {
+			respondError(w, fmt.Errorf("failed to get tender events: %w", err))
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	// sendPending writes the tender events after the last one sent. The stream reads them from
+	// the outbox rather than the broker, which keeps them in order and also picks up events
+	// published by other instances or dropped for a slow subscriber. An event waits there until
+	// the transactions older than it have finished, so none can be passed over.
+	sendPending := func() bool {
+		events, positions, err := h.repository(r).TenderEventsAfter(tender.ID, last)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "failed to get tender events", "tender_id", tender.ID, "error", err)
+			return false
+		}
+
+		for i, event := range events {
+			last = positions[i]
+			if event.Restricted && !slices.Contains(event.OrganizationIDs, organizationId) {
+				continue
+			}
+
+			data, err := json.Marshal(event)
+			if err != nil {
+				slog.ErrorContext(r.Context(), "failed to encode event", "event_id", event.ID, "error", err)
+				return false
+			}
+
+			_, err = fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", last, event.Type, data)
+			if err != nil {
+				return false
+			}
+		}
+
+		flusher.Flush()
+		return true
+	}
+
+	if !sendPending() {
+		return
+	}
+
+	heartbeat := time.NewTicker(h.heartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case event, ok := <-live:
+			if !ok {
+				return
+			}
+			if event.TenderID != tender.ID {
+				continue
+			}
+			if !sendPending() {
+				return
+			}
+		case <-heartbeat.C:
+			_, err := fmt.Fprint(w, ": heartbeat\n\n")
+			if err != nil {
+				return
+			}
+			if !sendPending() {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package handlers
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/noctusha/tender/connection"
+)
+
+// applyTenderFilterParam fills the filter from a query parameter shared by the tender lists.
+// It reports false for a parameter it does not know, so the caller can handle it or reject it.
+// Service types are checked against the registry by the caller.
+func applyTenderFilterParam(filter *connection.TenderFilter, name string, vals []string) (bool, error) {
+	var err error
+	switch name {
+	case "service_type":
+		filter.ServiceTypes = append(filter.ServiceTypes, vals...)
+	case "organizationId":
+		if _, err := uuid.Parse(vals[0]); err != nil {
+			return true, problem(codeInvalidParameter, name)
+		}
+		filter.OrganizationID = vals[0]
+	case "createdFrom":
+		filter.CreatedFrom, err = parseDateParam(name, vals[0], false)
+	case "createdTo":
+		filter.CreatedTo, err = parseDateParam(name, vals[0], true)
+	case "updatedFrom":
+		filter.UpdatedFrom, err = parseDateParam(name, vals[0], false)
+	case "updatedTo":
+		filter.UpdatedTo, err = parseDateParam(name, vals[0], true)
+	case "budgetMin":
+		filter.BudgetMin, err = parseBudgetParam(name, vals[0])
+	case "budgetMax":
+		filter.BudgetMax, err = parseBudgetParam(name, vals[0])
+	case "sort":
+		filter.Sort, err = connection.ParseTenderSort(vals[0])
+	default:
+		return applyPageParam(&filter.Page, name, vals)
+	}
+	return true, err
+}
+
+func applyBidFilterParam(filter *connection.BidFilter, name string, vals []string) (bool, error) {
+	var err error
+	switch name {
+	case "status":
+		for _, status := range vals {
+			status = strings.ToUpper(status)
+			switch status {
+			case statusCreated, bidStatusApproved, bidStatusRejected, bidStatusWithdrawn:
+				filter.Statuses = append(filter.Statuses, status)
+			default:
+				return true, problem(codeInvalidStatus, status)
+			}
+		}
+	case "createdFrom":
+		filter.CreatedFrom, err = parseDateParam(name, vals[0], false)
+	case "createdTo":
+		filter.CreatedTo, err = parseDateParam(name, vals[0], true)
+	case "updatedFrom":
+		filter.UpdatedFrom, err = parseDateParam(name, vals[0], false)
+	case "updatedTo":
+		filter.UpdatedTo, err = parseDateParam(name, vals[0], true)
+	case "sort":
+		filter.Sort, err = connection.ParseBidSort(vals[0])
+	default:
+		return applyPageParam(&filter.Page, name, vals)
+	}
+	return true, err
+}
+
+func applyPageParam(page *connection.Page, name string, vals []string) (bool, error) {
+	var err error
+	switch name {
+	case "limit":
+		page.Limit, err = parseIntParam(name, vals[0])
+		if err == nil && (page.Limit < 1 || page.Limit > connection.MaxListLimit) {
+			err = problem(codeInvalidLimit, connection.MaxListLimit)
+		}
+	case "offset":
+		page.Offset, err = parseIntParam(name, vals[0])
+		if err == nil && page.Offset < 0 {
+			err = problem(codeInvalidOffset)
+		}
+	case "cursor":
+		page.Cursor = vals[0]
+	case "total":
+		page.WithTotal, err = strconv.ParseBool(vals[0])
+		if err != nil {
+			err = problem(codeInvalidParameter, name)
+		}
+	default:
+		return false, nil
+	}
+	return true, err
+}
+
+// setPageLink points the Link header at the next page: the same request continued from the cursor.
+func setPageLink(w http.ResponseWriter, r *http.Request, info connection.PageInfo) {
+	if info.NextCursor == "" {
+		return
+	}
+
+	query := r.URL.Query()
+	query.Del("offset")
+	query.Set("cursor", info.NextCursor)
+	next := url.URL{Path: r.URL.Path, RawQuery: query.Encode()}
+	w.Header().Set("Link", fmt.Sprintf("<%s>; rel=\"next\"", next.String()))
+}
+
+// parseTenderStatuses validates repeated status values of a tender list.
+func parseTenderStatuses(vals []string) ([]string, error) {
+	statuses := []string{}
+	for _, status := range vals {
+		status = strings.ToUpper(status)
+		switch status {
+		case statusCreated, statusPublished, statusCancelled, statusClosed:
+			statuses = append(statuses, status)
+		default:
+			return nil, problem(codeInvalidStatus, status)
+		}
+	}
+	return statuses, nil
+}
+
+// parseDateParam accepts an RFC 3339 timestamp or a YYYY-MM-DD date. A bare date used as the
+// upper bound of a range covers the whole day.
+func parseDateParam(name string, value string, endOfDay bool) (*time.Time, error) {
+	t, err := time.Parse(time.RFC3339, value)
+	if err == nil {
+		t = t.UTC()
+		return &t, nil
+	}
+
+	t, err = time.Parse(time.DateOnly, value)
+	if err != nil {
+		return nil, problem(codeInvalidDate, name)
+	}
+	if endOfDay {
+		t = t.Add(24*time.Hour - time.Nanosecond)
+	}
+	return &t, nil
+}
+
+func parseBudgetParam(name string, value string) (*float64, error) {
+	budget, err := strconv.ParseFloat(value, 64)
+	if err != nil || math.IsNaN(budget) || math.IsInf(budget, 0) {
+		return nil, problem(codeInvalidParameter, name)
+	}
+	return &budget, nil
+}
+
+func parseIntParam(name string, value string) (int, error) {
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, problem(codeInvalidParameter, name)
+	}
+	return n, nil
+}
@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"errors"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/noctusha/tender/connection"
+)
+
+// errorCode is the code of a problem, empty for nil.
+func errorCode(err error) string {
+	var apiErr *apiError
+	if errors.As(err, &apiErr) {
+		return apiErr.code
+	}
+	if err != nil {
+		return err.Error()
+	}
+	return ""
+}
+
+func TestApplyPageParam(t *testing.T) {
+	tests := []struct {
+		name     string
+		param    string
+		value    string
+		want     connection.Page
+		notPage  bool
+		wantCode string
+	}{
+		{name: "limit", param: "limit", value: "20", want: connection.Page{Limit: 20}},
+		{name: "smallest limit", param: "limit", value: "1", want: connection.Page{Limit: 1}},
+		{name: "largest limit", param: "limit", value: strconv.Itoa(connection.MaxListLimit), want: connection.Page{Limit: connection.MaxListLimit}},
+		{name: "zero limit", param: "limit", value: "0", wantCode: codeInvalidLimit},
+		{name: "negative limit", param: "limit", value: "-5", wantCode: codeInvalidLimit},
+		{name: "limit over the maximum", param: "limit", value: strconv.Itoa(connection.MaxListLimit + 1), wantCode: codeInvalidLimit},
+		{name: "limit not a number", param: "limit", value: "ten", wantCode: codeInvalidParameter},
+		{name: "offset", param: "offset", value: "40", want: connection.Page{Offset: 40}},
+		{name: "zero offset", param: "offset", value: "0", want: connection.Page{}},
+		{name: "negative offset", param: "offset", value: "-1", wantCode: codeInvalidOffset},
+		{name: "offset not a number", param: "offset", value: "1.5", wantCode: codeInvalidParameter},
+		{name: "cursor", param: "cursor", value: "eyJzIjoiIn0", want: connection.Page{Cursor: "eyJzIjoiIn0"}},
+		{name: "total", param: "total", value: "true", want: connection.Page{WithTotal: true}},
+		{name: "total not a boolean", param: "total", value: "yes", wantCode: codeInvalidParameter},
+		{name: "other parameter", param: "status", value: "PUBLISHED", notPage: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var page connection.Page
+			ok, err := applyPageParam(&page, tt.param, []string{tt.value})
+			if ok == tt.notPage {
+				t.Fatalf("applyPageParam(%s) handled = %v, want %v", tt.param, ok, !tt.notPage)
+			}
+			if code := errorCode(err); code != tt.wantCode {
+				t.Fatalf("applyPageParam(%s=%s) error = %v, want %q", tt.param, tt.value, err, tt.wantCode)
+			}
+			if err == nil && page != tt.want {
+				t.Errorf("applyPageParam(%s=%s) page = %+v, want %+v", tt.param, tt.value, page, tt.want)
+			}
+		})
+	}
+}
+
+func TestSetPageLink(t *testing.T) {
+	tests := []struct {
+		name   string
+		target string
+		info   connection.PageInfo
+		want   string
+	}{
+		{name: "last page", target: "/api/tenders?limit=5", info: connection.PageInfo{}, want: ""},
+		{name: "next page", target: "/api/tenders?limit=5&status=PUBLISHED", info: connection.PageInfo{NextCursor: "abc"},
+			want: `</api/tenders?cursor=abc&limit=5&status=PUBLISHED>; rel="next"`},
+		{name: "offset replaced by the cursor", target: "/api/bids/my?username=user1&offset=10&cursor=old", info: connection.PageInfo{NextCursor: "new"},
+			want: `</api/bids/my?cursor=new&username=user1>; rel="next"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			setPageLink(w, httptest.NewRequest("GET", tt.target, nil), tt.info)
+			if got := w.Header().Get("Link"); got != tt.want {
+				t.Errorf("Link = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -2,11 +2,18 @@ package handlers
 
 import (
 	"encoding/json"
-	"log"
+	"log/slog"
 	"net/http"
+	"os"
+	"strconv"
+	"time"
 
 	"github.com/noctusha/tender/connection"
 	"github.com/noctusha/tender/models"
+	"github.com/noctusha/tender/outbox"
+	"github.com/noctusha/tender/receipts"
+	"github.com/noctusha/tender/storage"
+	"github.com/noctusha/tender/webhooks"
 )
 
 const (
@@ -15,37 +22,105 @@ const (
 	statusClosed    = "CLOSED"
 	statusCancelled = "CANCELLED"
 
+	bidStatusApproved  = "APPROVED"
+	bidStatusRejected  = "REJECTED"
+	bidStatusWithdrawn = "WITHDRAWN"
+
+	decisionApproved = "Approved"
+	decisionRejected = "Rejected"
+
+	lotStatusOpen      = "OPEN"
+	lotStatusAwarded   = "AWARDED"
+	lotStatusCancelled = "CANCELLED"
+
+	visibilityPublic     = "PUBLIC"
+	visibilityInviteOnly = "INVITE_ONLY"
+
+	invitationStatusPending  = "PENDING"
+	invitationStatusAccepted = "ACCEPTED"
+	invitationStatusDeclined = "DECLINED"
+
 	authorTypeUser         = "User"
 	authorTypeOrganization = "Organization"
-
-	serviceTypeConstruction = "Construction"
-	serviceTypeDelivery     = "Delivery"
-	serviceTypeManufacture  = "Manufacture"
 )
 
 type Handler struct {
-	repo *connection.Repository
+	repo     *connection.Repository
+	storage  storage.BlobStorage
+	outbox   *outbox.Dispatcher
+	webhooks *webhooks.Dispatcher
+	broker   *outbox.Broker
+	receipts *receipts.Signer
+
+	maxAttachmentSize int64
+	heartbeatInterval time.Duration
 }
 
 type JSON struct {
-	Err     string           `json:"error,omitempty"`
 	Tenders *[]models.Tender `json:"tender,omitempty"`
 	Bids    *[]models.Bid    `json:"bid,omitempty"`
+	Lots    *[]models.Lot    `json:"lot,omitempty"`
+
+	Invitations *[]models.TenderInvitation `json:"invitation,omitempty"`
+	Questions   *[]models.TenderQuestion   `json:"question,omitempty"`
+	Amendments  *[]models.TenderAmendment  `json:"amendment,omitempty"`
+	BidHistory  *[]models.BidStatusChange  `json:"history,omitempty"`
+	Attachments *[]models.Attachment       `json:"attachment,omitempty"`
+
+	SearchResults *[]models.TenderSearchResult `json:"result,omitempty"`
+	ServiceTypes  *[]models.ServiceType        `json:"serviceType,omitempty"`
+
+	Webhooks          *[]models.WebhookSubscription `json:"webhook,omitempty"`
+	WebhookDeliveries *[]models.WebhookDelivery     `json:"delivery,omitempty"`
+
+	Notifications *[]models.Notification `json:"notification,omitempty"`
+	Unread        *int                   `json:"unread,omitempty"`
+	Marked        *int                   `json:"marked,omitempty"`
+
+	AuditEvents *[]models.AuditEvent `json:"audit,omitempty"`
+
+	NextCursor string `json:"nextCursor,omitempty"`
+	Total      *int   `json:"total,omitempty"`
 }
 
-func NewHandler(repo *connection.Repository) *Handler {
+func NewHandler(repo *connection.Repository, blobs storage.BlobStorage, events *outbox.Dispatcher, hooks *webhooks.Dispatcher,
+	broker *outbox.Broker, signer *receipts.Signer) *Handler {
+	maxAttachmentSize := int64(defaultMaxAttachmentSize)
+	if v, err := strconv.ParseInt(os.Getenv("ATTACHMENT_MAX_SIZE"), 10, 64); err == nil && v > 0 {
+		maxAttachmentSize = v
+	}
+
+	heartbeatInterval := defaultHeartbeatInterval
+	if v, err := time.ParseDuration(os.Getenv("SSE_HEARTBEAT_INTERVAL")); err == nil && v > 0 {
+		heartbeatInterval = v
+	}
+
 	return &Handler{
-		repo: repo,
+		repo:              repo,
+		storage:           blobs,
+		outbox:            events,
+		webhooks:          hooks,
+		broker:            broker,
+		receipts:          signer,
+		maxAttachmentSize: maxAttachmentSize,
+		heartbeatInterval: heartbeatInterval,
 	}
 }
 
+// repository returns the repository acting for a request, so its statements are traced under
+// the span of the request.
+func (h *Handler) repository(r *http.Request) *connection.Repository {
+	return h.repo.WithContext(r.Context())
+}
+
 func respondJSON(w http.ResponseWriter, statusCode int, payload interface{}) {
 	response, err := json.Marshal(payload)
 	if err != nil {
+		slog.Error("failed to encode response", "request_id", responseRequestID(w), "error", err)
 		w.WriteHeader(http.StatusInternalServerError)
 		_, writeErr := w.Write([]byte("Internal server error"))
 		if writeErr != nil {
-			log.Printf("error writing an error in respondJSON: %v", writeErr)
+			slog.Error("failed to write response", "request_id", responseRequestID(w), "error", writeErr)
 		}
 		return
 	}
@@ -53,19 +128,15 @@ func respondJSON(w http.ResponseWriter, statusCode int, payload interface{}) {
 	w.WriteHeader(statusCode)
 	_, writeErr := w.Write(response)
 	if writeErr != nil {
-		log.Printf("error writing response in respondJSON: %v", writeErr)
+		slog.Error("failed to write response", "request_id", responseRequestID(w), "error", writeErr)
 	}
 }
 
-func respondJSONError(w http.ResponseWriter, statusCode int, message string) {
-	respondJSON(w, statusCode, JSON{Err: message})
-}
-
 func (h *Handler) PingHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "text/plain")
 	w.WriteHeader(http.StatusOK)
 	_, err := w.Write([]byte("ok"))
 	if err != nil {
-		log.Printf("error writing response in PingHandler: %v", err)
+		slog.ErrorContext(r.Context(), "failed to write response", "error", err)
 	}
 }
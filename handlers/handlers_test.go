@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+// requestTest is a request a handler answers with a problem before it reaches the repository.
+type requestTest struct {
+	name       string
+	target     string
+	vars       map[string]string
+	body       string
+	wantStatus int
+	wantCode   string
+}
+
+// runRequestTests serves every request with handler, a method of a Handler without a
+// repository, and checks the problem it answers with.
+func runRequestTests(t *testing.T, method string, handler func(h *Handler) http.HandlerFunc, tests []requestTest) {
+	t.Helper()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(method, tt.target, strings.NewReader(tt.body))
+			r = mux.SetURLVars(r, tt.vars)
+			w := httptest.NewRecorder()
+
+			handler(&Handler{}).ServeHTTP(w, r)
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+			var problem problemDetails
+			err := json.Unmarshal(w.Body.Bytes(), &problem)
+			if err != nil {
+				t.Fatalf("decode problem: %v", err)
+			}
+			if problem.Code != tt.wantCode {
+				t.Errorf("code = %q, want %q", problem.Code, tt.wantCode)
+			}
+		})
+	}
+}
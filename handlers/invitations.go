@@ -0,0 +1,391 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+
+	"github.com/noctusha/tender/connection"
+	"github.com/noctusha/tender/models"
+)
+
+// canAccessTender reports whether the organization may see and bid on the tender:
+// public tenders are open to everyone, invite-only ones to the owner and invitees that did not decline.
+func (h *Handler) canAccessTender(r *http.Request, tender *models.Tender, organizationId string) (bool, error) {
+	if tender.Visibility != visibilityInviteOnly || tender.OrganizationID == organizationId {
+		return true, nil
+	}
+
+	return h.repository(r).IsOrganizationInvited(tender.ID, organizationId)
+}
+
+func (h *Handler) NewInvitation(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	tenderID, err := uuid.Parse(vars["tenderId"])
+	if err != nil {
+		respondError(w, problem(codeInvalidParameter, "tenderId"))
+		return
+	}
+
+	var username string
+	for name, vals := range r.URL.Query() {
+		switch name {
+		case "username":
+			username = vals[0]
+		default:
+			respondError(w, problem(codeUnknownParameter, name))
+			return
+		}
+	}
+
+	if username == "" {
+		respondError(w, problem(codeMissingUsername))
+		return
+	}
+
+	var invitation models.TenderInvitation
+	err = json.NewDecoder(r.Body).Decode(&invitation)
+	if err != nil {
+		respondError(w, problem(codeInvalidJSON, err))
+		return
+	}
+
+	if !validateModel(w, &invitation) {
+		return
+	}
+
+	tender, ok, err := h.repository(r).GetTenderByID(tenderID)
+	if err != nil {
+		respondError(w, fmt.Errorf("failed to get tender: %w", err))
+		return
+	}
+
+	if !ok {
+		respondError(w, problem(codeTenderNotFound))
+		return
+	}
+
+	organizationId, userFound, err := h.repository(r).GetOrganizationIDByUsername(username)
+	if err != nil {
+		respondError(w, fmt.Errorf("failed to get organization by username: %w", err))
+		return
+	}
+
+	if !userFound {
+		respondError(w, problem(codeUserNotFound, username))
+		return
+	}
+
+	if tender.OrganizationID != organizationId {
+		respondError(w, problem(codeTenderForbidden, username))
+		return
+	}
+
+	if tender.Visibility != visibilityInviteOnly {
+		respondError(w, problem(codeTenderNotInviteOnly))
+		return
+	}
+
+	if invitation.OrganizationID == tender.OrganizationID {
+		respondError(w, problem(codeSelfInvitation))
+		return
+	}
+
+	exists, err := h.repository(r).OrganizationExists(invitation.OrganizationID)
+	if err != nil {
+		respondError(w, fmt.Errorf("failed to get organization: %w", err))
+		return
+	}
+
+	if !exists {
+		respondError(w, problem(codeOrganizationNotFound, invitation.OrganizationID))
+		return
+	}
+
+	invited, err := h.repository(r).InvitationExists(tender.ID, invitation.OrganizationID)
+	if err != nil {
+		respondError(w, fmt.Errorf("failed to check tender invitation: %w", err))
+		return
+	}
+
+	if invited {
+		respondError(w, problem(codeOrganizationAlreadyInvited, invitation.OrganizationID))
+		return
+	}
+
+	invitation.ID = uuid.New().String()
+	invitation.TenderID = tender.ID
+	invitation.Status = invitationStatusPending
+
+	err = h.repository(r).Transaction(func(repo *connection.Repository) error {
+		err := repo.NewInvitation(invitation)
+		if err != nil {
+			return fmt.Errorf("failed to save tender invitation: %w", err)
+		}
+		return h.audit(r, repo, username, auditActionCreate, auditEntityInvitation, invitation.ID, nil, invitation, tender.OrganizationID, invitation.OrganizationID)
+	})
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, invitation)
+}
+
+// ListInvitations shows the tender owner every invitation and an invitee only its own one.
+func (h *Handler) ListInvitations(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	tenderID, err := uuid.Parse(vars["tenderId"])
+	if err != nil {
+		respondError(w, problem(codeInvalidParameter, "tenderId"))
+		return
+	}
+
+	var username string
+	for name, vals := range r.URL.Query() {
+		switch name {
+		case "username":
+			username = vals[0]
+		default:
+			respondError(w, problem(codeUnknownParameter, name))
+			return
+		}
+	}
+
+	if username == "" {
+		respondError(w, problem(codeMissingUsername))
+		return
+	}
+
+	tender, ok, err := h.repository(r).GetTenderByID(tenderID)
+	if err != nil {
+		respondError(w, fmt.Errorf("failed to get tender: %w", err))
+		return
+	}
+
+	if !ok {
+		respondError(w, problem(codeTenderNotFound))
+		return
+	}
+
+	organizationId, userFound, err := h.repository(r).GetOrganizationIDByUsername(username)
+	if err != nil {
+		respondError(w, fmt.Errorf("failed to get organization by username: %w", err))
+		return
+	}
+
+	if !userFound {
+		respondError(w, problem(codeUserNotFound, username))
+		return
+	}
+
+	invitations, err := h.repository(r).InvitationsByTenderID(tender.ID)
+	if err != nil {
+		respondError(w, fmt.Errorf("failed to select tender invitation from database: %w", err))
+		return
+	}
+
+	if tender.OrganizationID != organizationId {
+		own := []models.TenderInvitation{}
+		for _, invitation := range invitations {
+			if invitation.OrganizationID == organizationId {
+				own = append(own, invitation)
+			}
+		}
+
+		if len(own) == 0 {
+			respondError(w, problem(codeTenderForbidden, username))
+			return
+		}
+		invitations = own
+	}
+
+	respondJSON(w, http.StatusOK, JSON{Invitations: &invitations})
+}
+
+func (h *Handler) MyInvitations(w http.ResponseWriter, r *http.Request) {
+	var username string
+	for name, vals := range r.URL.Query() {
+		switch name {
+		case "username":
+			username = vals[0]
+		default:
+			respondError(w, problem(codeUnknownParameter, name))
+			return
+		}
+	}
+
+	if username == "" {
+		respondError(w, problem(codeMissingUsername))
+		return
+	}
+
+	organizationId, userFound, err := h.repository(r).GetOrganizationIDByUsername(username)
+	if err != nil {
+		respondError(w, fmt.Errorf("failed to get organization by username: %w", err))
+		return
+	}
+
+	if !userFound {
+		respondError(w, problem(codeUserNotFound, username))
+		return
+	}
+
+	invitations, err := h.repository(r).InvitationsByOrganizationID(organizationId)
+	if err != nil {
+		respondError(w, fmt.Errorf("failed to select tender invitation from database: %w", err))
+		return
+	}
+
+	respondJSON(w, http.StatusOK, JSON{Invitations: &invitations})
+}
+
+func (h *Handler) AcceptInvitation(w http.ResponseWriter, r *http.Request) {
+	h.respondInvitation(w, r, invitationStatusAccepted)
+}
+
+func (h *Handler) DeclineInvitation(w http.ResponseWriter, r *http.Request) {
+	h.respondInvitation(w, r, invitationStatusDeclined)
+}
+
+func (h *Handler) respondInvitation(w http.ResponseWriter, r *http.Request, status string) {
+	invitation, organizationId, ok := h.invitationFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	if invitation.OrganizationID != organizationId {
+		respondError(w, problem(codeInvitationOtherOrganization))
+		return
+	}
+
+	if invitation.Status != invitationStatusPending {
+		respondError(w, problem(codeInvitationStatusConflict, invitation.Status))
+		return
+	}
+
+	previous := *invitation
+	invitation.Status = status
+	tenderOrganizationID := h.auditTenderOrganizationID(r, invitation.TenderID)
+	err := h.repository(r).Transaction(func(repo *connection.Repository) error {
+		err := repo.UpdateInvitationStatus(invitation.ID, status)
+		if err != nil {
+			return fmt.Errorf("failed to update tender invitation: %w", err)
+		}
+		return h.audit(r, repo, r.URL.Query().Get("username"), auditActionStatus, auditEntityInvitation, invitation.ID, previous, invitation,
+			tenderOrganizationID, invitation.OrganizationID)
+	})
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, invitation)
+}
+
+func (h *Handler) DeleteInvitation(w http.ResponseWriter, r *http.Request) {
+	invitation, organizationId, ok := h.invitationFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	tenderID, err := uuid.Parse(invitation.TenderID)
+	if err != nil {
+		respondError(w, fmt.Errorf("invalid tenderID format: %w", err))
+		return
+	}
+
+	tender, found, err := h.repository(r).GetTenderByID(tenderID)
+	if err != nil {
+		respondError(w, fmt.Errorf("failed to get tender: %w", err))
+		return
+	}
+
+	if !found {
+		respondError(w, problem(codeTenderNotFound))
+		return
+	}
+
+	if tender.OrganizationID != organizationId {
+		respondError(w, problem(codeInvitationRevokeForbidden))
+		return
+	}
+
+	err = h.repository(r).Transaction(func(repo *connection.Repository) error {
+		err := repo.DeleteInvitation(invitation.ID)
+		if err != nil {
+			return fmt.Errorf("failed to delete tender invitation: %w", err)
+		}
+		return h.audit(r, repo, r.URL.Query().Get("username"), auditActionDelete, auditEntityInvitation, invitation.ID, invitation, nil,
+			tender.OrganizationID, invitation.OrganizationID)
+	})
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, invitation)
+}
+
+// invitationFromRequest loads the invitation addressed by the route and the organization
+// of the requesting user. It writes the error response itself and reports false on failure.
+func (h *Handler) invitationFromRequest(w http.ResponseWriter, r *http.Request) (*models.TenderInvitation, string, bool) {
+	vars := mux.Vars(r)
+
+	tenderID, err := uuid.Parse(vars["tenderId"])
+	if err != nil {
+		respondError(w, problem(codeInvalidParameter, "tenderId"))
+		return nil, "", false
+	}
+
+	invitationID, err := uuid.Parse(vars["invitationId"])
+	if err != nil {
+		respondError(w, problem(codeInvalidParameter, "invitationId"))
+		return nil, "", false
+	}
+
+	var username string
+	for name, vals := range r.URL.Query() {
+		switch name {
+		case "username":
+			username = vals[0]
+		default:
+			respondError(w, problem(codeUnknownParameter, name))
+			return nil, "", false
+		}
+	}
+
+	if username == "" {
+		respondError(w, problem(codeMissingUsername))
+		return nil, "", false
+	}
+
+	organizationId, userFound, err := h.repository(r).GetOrganizationIDByUsername(username)
+	if err != nil {
+		respondError(w, fmt.Errorf("failed to get organization by username: %w", err))
+		return nil, "", false
+	}
+
+	if !userFound {
+		respondError(w, problem(codeUserNotFound, username))
+		return nil, "", false
+	}
+
+	invitation, ok, err := h.repository(r).GetInvitationByID(invitationID)
+	if err != nil {
+		respondError(w, fmt.Errorf("failed to get tender invitation: %w", err))
+		return nil, "", false
+	}
+
+	if !ok || invitation.TenderID != tenderID.String() {
+		respondError(w, problem(codeInvitationNotFound))
+		return nil, "", false
+	}
+
+	return invitation, organizationId, true
+}
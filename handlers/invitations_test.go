@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/noctusha/tender/models"
+)
+
+func TestCanAccessTender(t *testing.T) {
+	const (
+		owner  = "a1b2c3d4-e5f6-7890-abcd-ef1234567890"
+		bidder = "7c9e6679-7425-40de-944b-e07fc1f90ae7"
+	)
+
+	// These cases are decided without asking the repository whether the bidder was invited.
+	tests := []struct {
+		name         string
+		visibility   string
+		organization string
+	}{
+		{name: "public tender", visibility: visibilityPublic, organization: bidder},
+		{name: "tender without visibility", organization: bidder},
+		{name: "owner of an invite-only tender", visibility: visibilityInviteOnly, organization: owner},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tender := &models.Tender{ID: testTenderID, OrganizationID: owner, Visibility: tt.visibility}
+			allowed, err := (&Handler{}).canAccessTender(httptest.NewRequest(http.MethodGet, "/", nil), tender, tt.organization)
+			if err != nil || !allowed {
+				t.Errorf("canAccessTender = %v, %v, want true", allowed, err)
+			}
+		})
+	}
+}
+
+func TestNewInvitationRequest(t *testing.T) {
+	vars := map[string]string{"tenderId": testTenderID}
+	runRequestTests(t, http.MethodPost, func(h *Handler) http.HandlerFunc { return h.NewInvitation }, []requestTest{
+		{name: "tender id", target: "/?username=user1", vars: map[string]string{"tenderId": "42"},
+			wantStatus: http.StatusBadRequest, wantCode: codeInvalidParameter},
+		{name: "no username", target: "/", vars: vars, body: `{"organizationId":"7c9e6679-7425-40de-944b-e07fc1f90ae7"}`,
+			wantStatus: http.StatusBadRequest, wantCode: codeMissingUsername},
+		{name: "no organization", target: "/?username=user1", vars: vars, body: `{}`,
+			wantStatus: http.StatusBadRequest, wantCode: codeValidation},
+		{name: "organization id", target: "/?username=user1", vars: vars, body: `{"organizationId":"org1"}`,
+			wantStatus: http.StatusBadRequest, wantCode: codeValidation},
+	})
+}
+
+func TestInvitationRequest(t *testing.T) {
+	vars := map[string]string{"tenderId": testTenderID, "invitationId": "7c9e6679-7425-40de-944b-e07fc1f90ae7"}
+	runRequestTests(t, http.MethodPut, func(h *Handler) http.HandlerFunc { return h.AcceptInvitation }, []requestTest{
+		{name: "invitation id", target: "/?username=user1", vars: map[string]string{"tenderId": testTenderID, "invitationId": "42"},
+			wantStatus: http.StatusBadRequest, wantCode: codeInvalidParameter},
+		{name: "unknown parameter", target: "/?username=user1&status=ACCEPTED", vars: vars,
+			wantStatus: http.StatusBadRequest, wantCode: codeUnknownParameter},
+		{name: "no username", target: "/", vars: vars,
+			wantStatus: http.StatusBadRequest, wantCode: codeMissingUsername},
+	})
+}
@@ -0,0 +1,240 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+
+	"github.com/noctusha/tender/connection"
+	"github.com/noctusha/tender/models"
+)
+
+func (h *Handler) ListLots(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	tenderID, err := uuid.Parse(vars["tenderId"])
+	if err != nil {
+		respondError(w, problem(codeInvalidParameter, "tenderId"))
+		return
+	}
+
+	_, ok, err := h.repository(r).GetTenderByID(tenderID)
+	if err != nil {
+		respondError(w, fmt.Errorf("failed to get tender: %w", err))
+		return
+	}
+
+	if !ok {
+		respondError(w, problem(codeTenderNotFound))
+		return
+	}
+
+	lots, err := h.repository(r).LotsByTenderID(tenderID.String())
+	if err != nil {
+		respondError(w, fmt.Errorf("failed to select lot from database: %w", err))
+		return
+	}
+
+	respondJSON(w, http.StatusOK, JSON{Lots: &lots})
+}
+
+func (h *Handler) NewLot(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	tenderID, err := uuid.Parse(vars["tenderId"])
+	if err != nil {
+		respondError(w, problem(codeInvalidParameter, "tenderId"))
+		return
+	}
+
+	var username string
+	for name, vals := range r.URL.Query() {
+		switch name {
+		case "username":
+			username = vals[0]
+		default:
+			respondError(w, problem(codeUnknownParameter, name))
+			return
+		}
+	}
+
+	if username == "" {
+		respondError(w, problem(codeMissingUsername))
+		return
+	}
+
+	var lot models.Lot
+	err = json.NewDecoder(r.Body).Decode(&lot)
+	if err != nil {
+		respondError(w, problem(codeInvalidJSON, err))
+		return
+	}
+
+	if !validateModel(w, &lot) {
+		return
+	}
+
+	if !h.checkServiceTypes(w, r, lot.ServiceType) {
+		return
+	}
+
+	tender, ok, err := h.repository(r).GetTenderByID(tenderID)
+	if err != nil {
+		respondError(w, fmt.Errorf("failed to get tender: %w", err))
+		return
+	}
+
+	if !ok {
+		respondError(w, problem(codeTenderNotFound))
+		return
+	}
+
+	organizationId, userFound, err := h.repository(r).GetOrganizationIDByUsername(username)
+	if err != nil {
+		respondError(w, fmt.Errorf("failed to get organization by username: %w", err))
+		return
+	}
+
+	if !userFound {
+		respondError(w, problem(codeUserNotFound, username))
+		return
+	}
+
+	if tender.OrganizationID != organizationId {
+		respondError(w, problem(codeTenderForbidden, username))
+		return
+	}
+
+	if tender.Status == statusClosed || tender.Status == statusCancelled {
+		respondError(w, problem(codeTenderClosedForLots, tender.Status))
+		return
+	}
+
+	lot.ID = uuid.New().String()
+	lot.TenderID = tender.ID
+	lot.Status = lotStatusOpen
+	lot.WinnerBidID = ""
+
+	err = h.repository(r).Transaction(func(repo *connection.Repository) error {
+		err := repo.NewLot(lot)
+		if err != nil {
+			return fmt.Errorf("failed to save lot: %w", err)
+		}
+		return h.audit(r, repo, username, auditActionCreate, auditEntityLot, lot.ID, nil, lot, tender.OrganizationID)
+	})
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, lot)
+}
+
+// SetLotStatus only supports cancelling a lot: lots are awarded through a bid decision.
+func (h *Handler) SetLotStatus(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	tenderID, err := uuid.Parse(vars["tenderId"])
+	if err != nil {
+		respondError(w, problem(codeInvalidParameter, "tenderId"))
+		return
+	}
+
+	lotID, err := uuid.Parse(vars["lotId"])
+	if err != nil {
+		respondError(w, problem(codeInvalidParameter, "lotId"))
+		return
+	}
+
+	var status, username string
+	for name, vals := range r.URL.Query() {
+		switch name {
+		case "status":
+			status = vals[0]
+		case "username":
+			username = vals[0]
+		default:
+			respondError(w, problem(codeUnknownParameter, name))
+			return
+		}
+	}
+
+	if !strings.EqualFold(status, lotStatusCancelled) {
+		respondError(w, problem(codeInvalidStatus, status))
+		return
+	}
+
+	if username == "" {
+		respondError(w, problem(codeMissingUsername))
+		return
+	}
+
+	tender, ok, err := h.repository(r).GetTenderByID(tenderID)
+	if err != nil {
+		respondError(w, fmt.Errorf("failed to get tender: %w", err))
+		return
+	}
+
+	if !ok {
+		respondError(w, problem(codeTenderNotFound))
+		return
+	}
+
+	organizationId, userFound, err := h.repository(r).GetOrganizationIDByUsername(username)
+	if err != nil {
+		respondError(w, fmt.Errorf("failed to get organization by username: %w", err))
+		return
+	}
+
+	if !userFound {
+		respondError(w, problem(codeUserNotFound, username))
+		return
+	}
+
+	if tender.OrganizationID != organizationId {
+		respondError(w, problem(codeTenderForbidden, username))
+		return
+	}
+
+	if tender.Status != statusPublished {
+		respondError(w, problem(codeTenderStatusConflict, tender.Status))
+		return
+	}
+
+	lot, ok, err := h.repository(r).GetLotByID(lotID)
+	if err != nil {
+		respondError(w, fmt.Errorf("failed to get lot: %w", err))
+		return
+	}
+
+	if !ok || lot.TenderID != tender.ID {
+		respondError(w, problem(codeLotNotFound))
+		return
+	}
+
+	if lot.Status != lotStatusOpen {
+		respondError(w, problem(codeLotStatusConflict, lot.Status))
+		return
+	}
+
+	previous := *lot
+	lot.Status = lotStatusCancelled
+	err = h.repository(r).Transaction(func(repo *connection.Repository) error {
+		_, err := repo.CancelLot(lot.ID)
+		if err != nil {
+			return fmt.Errorf("failed to cancel lot: %w", err)
+		}
+		return h.audit(r, repo, username, auditActionStatus, auditEntityLot, lot.ID, previous, lot, tender.OrganizationID)
+	})
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+	h.outbox.Wake()
+
+	respondJSON(w, http.StatusOK, lot)
+}
@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+)
+
+const (
+	testTenderID = "550e8400-e29b-41d4-a716-446655440000"
+	testLotID    = "6ba7b810-9dad-11d1-80b4-00c04fd430c8"
+)
+
+func TestNewLotRequest(t *testing.T) {
+	vars := map[string]string{"tenderId": testTenderID}
+	runRequestTests(t, http.MethodPost, func(h *Handler) http.HandlerFunc { return h.NewLot }, []requestTest{
+		{name: "tender id", target: "/?username=user1", vars: map[string]string{"tenderId": "42"},
+			wantStatus: http.StatusBadRequest, wantCode: codeInvalidParameter},
+		{name: "unknown parameter", target: "/?username=user1&status=OPEN", vars: vars,
+			wantStatus: http.StatusBadRequest, wantCode: codeUnknownParameter},
+		{name: "no username", target: "/", vars: vars, body: `{}`,
+			wantStatus: http.StatusBadRequest, wantCode: codeMissingUsername},
+		{name: "not JSON", target: "/?username=user1", vars: vars, body: `{"description":`,
+			wantStatus: http.StatusBadRequest, wantCode: codeInvalidJSON},
+		{name: "invalid lot", target: "/?username=user1", vars: vars, body: `{"quantity":10}`,
+			wantStatus: http.StatusBadRequest, wantCode: codeValidation},
+	})
+}
+
+func TestSetLotStatusRequest(t *testing.T) {
+	vars := map[string]string{"tenderId": testTenderID, "lotId": testLotID}
+	runRequestTests(t, http.MethodPut, func(h *Handler) http.HandlerFunc { return h.SetLotStatus }, []requestTest{
+		{name: "tender id", target: "/?status=CANCELLED&username=user1", vars: map[string]string{"tenderId": "42", "lotId": testLotID},
+			wantStatus: http.StatusBadRequest, wantCode: codeInvalidParameter},
+		{name: "lot id", target: "/?status=CANCELLED&username=user1", vars: map[string]string{"tenderId": testTenderID, "lotId": "42"},
+			wantStatus: http.StatusBadRequest, wantCode: codeInvalidParameter},
+		{name: "unknown parameter", target: "/?status=CANCELLED&username=user1&reason=x", vars: vars,
+			wantStatus: http.StatusBadRequest, wantCode: codeUnknownParameter},
+		{name: "awarded through the status", target: "/?status=AWARDED&username=user1", vars: vars,
+			wantStatus: http.StatusBadRequest, wantCode: codeInvalidStatus},
+		{name: "no status", target: "/?username=user1", vars: vars,
+			wantStatus: http.StatusBadRequest, wantCode: codeInvalidStatus},
+		{name: "no username", target: "/?status=cancelled", vars: vars,
+			wantStatus: http.StatusBadRequest, wantCode: codeMissingUsername},
+	})
+}
@@ -0,0 +1,198 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Languages of error messages. English is the language of errorCatalog and the fallback for
+// codes a catalog does not translate.
+const (
+	languageEnglish = "en"
+	languageRussian = "ru"
+)
+
+// errorMessages translates the messages of errorCatalog, keyed by language and then by code.
+// A translation takes the same arguments as the English message.
+var errorMessages = map[string]map[string]string{
+	languageRussian: {
+		codeInternal:         "внутренняя ошибка сервера",
+		codeRouteNotFound:    "маршрут не найден",
+		codeMethodNotAllowed: "метод не поддерживается",
+
+		codeValidation:               "ошибка валидации: %s",
+		codeUnknownParameter:         "неизвестный параметр: %s",
+		codeInvalidParameter:         "неверный формат %s",
+		codeInvalidDate:              "неверный формат %s: ожидается время в формате RFC 3339 или дата YYYY-MM-DD",
+		codeInvalidStatus:            "неверный статус: %s",
+		codeInvalidNotificationState: "неверный статус: %s, ожидается один из %s, %s, %s",
+		codeInvalidLimit:             "limit должен быть от 1 до %d",
+		codeInvalidOffset:            "offset не может быть отрицательным",
+		codeInvalidSort:              "неизвестное поле сортировки: %s",
+		codeInvalidCursor:            "неверный курсор",
+		codeInvalidDecision:          "неверное решение: %s",
+		codeInvalidJSON:              "не удалось разобрать JSON: %v",
+		codeInvalidMultipart:         "не удалось разобрать multipart-форму: %v",
+		codeInvalidLastEventID:       "неверный идентификатор последнего события",
+		codeMissingUsername:          "не указано имя пользователя",
+		codeMissingSearchQuery:       "не указан поисковый запрос",
+		codeSearchQueryTooLong:       "поисковый запрос длиннее %d символов",
+		codeMissingFile:              "не передан файл",
+		codeEmptyFile:                "файл пуст",
+		codeInvalidFileName:          "недопустимое имя файла",
+		codeAttachmentTooLarge:       "вложение больше %d байт",
+		codeContentTypeNotAllowed:    "тип содержимого %q не разрешён",
+		codeContentTypeMismatch:      "содержимое файла (%s) не соответствует типу %s",
+		codeUnknownServiceType:       "неизвестный тип услуги: %s",
+		codeServiceTypeNameRequired:  "ошибка валидации: name обязательно",
+		codeServiceTypeNameTooLong:   "ошибка валидации: name длиннее %d символов",
+		codeServiceTypeNameReserved:  "ошибка валидации: имя %s зарезервировано",
+		codeServiceTypeCycle:         "тип услуги нельзя перенести в него самого или в его потомка",
+		codeInvalidDeadline:          "ошибка валидации: срок подачи должен быть в будущем",
+		codeLotRequired:              "ошибка валидации: lotId обязателен для тендера с лотами",
+		codeTenderHasNoLots:          "ошибка валидации: у тендера нет лотов",
+		codeReasonRequired:           "ошибка валидации: reason обязательно",
+		codeQuestionRequired:         "ошибка валидации: question обязательно",
+		codeAnswerRequired:           "ошибка валидации: answer обязательно",
+		codeInvalidEmail:             "ошибка валидации: неверный адрес электронной почты",
+		codeInvalidWebhookURL:        "ошибка валидации: url должен быть абсолютным адресом http или https",
+		codeWebhookURLNotPublic:      "ошибка валидации: url должен указывать только на публичные адреса",
+		codeInvalidPublicKey:         "ошибка валидации: publicKey должен быть открытым ключом %s в base64 длиной %d байт",
+		codeSignatureRequired:        "ошибка валидации: signature обязательна, у подписанта зарегистрирован ключ подписи",
+		codeSignerHasNoKey:           "ошибка валидации: подписант не зарегистрировал ключ подписи",
+		codeSignedByRequired:         "ошибка валидации: signedBy обязателен для подписанного предложения организации",
+		codeInvalidSignature:         "ошибка валидации: неверная подпись предложения",
+		codeBidIDRequired:            "ошибка валидации: id обязателен для подписанного предложения",
+		codeSelfInvitation:           "организация не может пригласить саму себя",
+
+		codeUserNotFound: "пользователь не найден: %s",
+
+		codeTenderForbidden:             "у пользователя %s нет прав на этот тендер",
+		codeBidForbidden:                "у пользователя %s нет прав на это предложение",
+		codeNotBidAuthor:                "пользователь %s не является автором предложения",
+		codeNotAdministrator:            "пользователь %s не является администратором",
+		codeNotOrganizationMember:       "пользователь %s не состоит в организации %s",
+		codeOrganizationNotInvited:      "организация %s не приглашена в этот тендер",
+		codeTenderVersionMismatch:       "версия не относится к этому тендеру",
+		codeBidVersionMismatch:          "версия не относится к этому предложению",
+		codeOwnTenderQuestion:           "организация тендера не может задавать вопросы по своему тендеру",
+		codeInvitationRevokeForbidden:   "отзывать приглашения может только организация тендера",
+		codeInvitationOtherOrganization: "приглашение адресовано другой организации",
+
+		codeTenderNotFound:            "тендер не найден",
+		codeBidNotFound:               "предложение не найдено",
+		codeLotNotFound:               "лот не найден",
+		codeTenderVersionNotFound:     "версия тендера не найдена",
+		codeBidVersionNotFound:        "версия предложения не найдена",
+		codeOrganizationNotFound:      "организация не найдена: %s",
+		codeWebhookNotFound:           "подписка на вебхук не найдена",
+		codeWebhookDeliveryNotFound:   "доставка вебхука не найдена",
+		codeSigningKeyNotFound:        "ключ подписи не зарегистрирован",
+		codeServiceTypeNotFound:       "тип услуги не найден",
+		codeParentServiceTypeNotFound: "родительский тип услуги не найден",
+		codeQuestionNotFound:          "вопрос не найден",
+		codeNotificationNotFound:      "уведомление не найдено",
+		codeInvitationNotFound:        "приглашение не найдено",
+		codeBidNotSigned:              "предложение не подписано",
+		codeBidNotSealed:              "предложение не запечатано",
+		codeAttachmentNotFound:        "вложение не найдено",
+		codeAttachmentContentNotFound: "содержимое вложения не найдено",
+
+		codeBidWithdrawn:               "отозванное предложение нельзя редактировать",
+		codeTenderStatusConflict:       "тендер в статусе %s",
+		codeLotStatusConflict:          "лот уже в статусе %s",
+		codeBidStatusConflict:          "предложение уже в статусе %s",
+		codeBidExists:                  "предложение %s уже существует",
+		codeBidChanged:                 "предложение изменено другим запросом",
+		codeInvitationStatusConflict:   "приглашение уже в статусе %s",
+		codeDeadlinePassed:             "срок подачи по тендеру истёк",
+		codeServiceTypeInUse:           "у типа услуги %s есть дочерние категории или он используется в тендерах",
+		codeServiceTypeExists:          "тип услуги %s уже существует",
+		codeOrganizationAlreadyInvited: "организация %s уже приглашена",
+		codeDeliveryNotFailed:          "повторить можно только неудачную доставку, статус доставки %s",
+		codeTenderClosedForLots:        "нельзя добавить лот в тендер в статусе %s",
+		codeBidNotWithdrawable:         "предложение в статусе %s нельзя отозвать",
+		codeBidNotWithdrawn:            "повторно подать можно только отозванное предложение",
+		codeTenderNotPublished:         "вопросы можно задавать только по опубликованному тендеру",
+		codeTenderNotInviteOnly:        "приглашения доступны только для закрытых тендеров",
+		codeBidNotReconfirmed:          "предложение не подтверждено после изменения тендера",
+		codeBidReconfirmationNotNeeded: "предложение не требует подтверждения",
+		codeTenderHasOpenLots:          "у тендера есть открытые лоты",
+	},
+}
+
+// localized is an argument of a message that is itself written in the language of the response.
+type localized interface {
+	localize(language string) string
+}
+
+// formatMessage builds the message of code in language, falling back to English.
+func formatMessage(language, code string, args []interface{}) string {
+	format, ok := errorMessages[language][code]
+	if !ok {
+		format = errorCatalog[code].message
+	}
+
+	values := make([]interface{}, len(args))
+	for i, arg := range args {
+		if l, ok := arg.(localized); ok {
+			values[i] = l.localize(language)
+			continue
+		}
+		values[i] = arg
+	}
+	return fmt.Sprintf(format, values...)
+}
+
+// Language picks the language of error messages from the Accept-Language header and names it
+// in the Content-Language header of the response, where respondError finds it.
+func Language(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		setLanguage(w, r)
+		next.ServeHTTP(w, r)
+	})
+}
+
+func setLanguage(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Language", negotiateLanguage(r.Header.Get("Accept-Language")))
+}
+
+// negotiateLanguage returns the supported language the client prefers most, English when it
+// accepts none of them. Regional variants count as their language, so ru-RU is Russian.
+func negotiateLanguage(header string) string {
+	best, bestWeight := languageEnglish, 0.0
+	for _, item := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(item), ";")
+		tag, _, _ = strings.Cut(strings.ToLower(strings.TrimSpace(tag)), "-")
+		if tag != languageEnglish && tag != languageRussian {
+			continue
+		}
+
+		weight := 1.0
+		for _, param := range strings.Split(params, ";") {
+			name, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+			if name != "q" {
+				continue
+			}
+			q, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				q = 0
+			}
+			weight = q
+		}
+
+		if weight > bestWeight {
+			best, bestWeight = tag, weight
+		}
+	}
+	return best
+}
+
+func responseLanguage(w http.ResponseWriter) string {
+	if w.Header().Get("Content-Language") == languageRussian {
+		return languageRussian
+	}
+	return languageEnglish
+}
@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNegotiateLanguage(t *testing.T) {
+	tests := []struct {
+		header string
+		want   string
+	}{
+		{header: "", want: languageEnglish},
+		{header: "ru", want: languageRussian},
+		{header: "ru-RU", want: languageRussian},
+		{header: "RU-ru", want: languageRussian},
+		{header: "en-US,en;q=0.9", want: languageEnglish},
+		{header: "ru-RU,ru;q=0.9,en-US;q=0.8,en;q=0.7", want: languageRussian},
+		{header: "en;q=0.5, ru;q=0.8", want: languageRussian},
+		{header: "ru;q=0.5, en", want: languageEnglish},
+		{header: "de-DE,de;q=0.9", want: languageEnglish},
+		{header: "de, ru;q=0.1", want: languageRussian},
+		{header: "ru;q=0", want: languageEnglish},
+		{header: "ru;q=abc", want: languageEnglish},
+		{header: "*", want: languageEnglish},
+	}
+	for _, tt := range tests {
+		t.Run(tt.header, func(t *testing.T) {
+			if got := negotiateLanguage(tt.header); got != tt.want {
+				t.Errorf("negotiateLanguage(%q) = %q, want %q", tt.header, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatMessage(t *testing.T) {
+	vs := violations{
+		{field: "name", rule: "maxLength", limit: "100"},
+		{field: "authorId", rule: "format", limit: "uuid"},
+		{field: "status", rule: "unknownRule", reason: "value is odd"},
+	}
+
+	tests := []struct {
+		name     string
+		language string
+		code     string
+		args     []interface{}
+		want     string
+	}{
+		{name: "english", language: languageEnglish, code: codeTenderNotFound, want: "tender not found"},
+		{name: "russian", language: languageRussian, code: codeTenderNotFound, want: "тендер не найден"},
+		{name: "unsupported language", language: "de", code: codeTenderNotFound, want: "tender not found"},
+		{name: "arguments", language: languageRussian, code: codeInvalidParameter, args: []interface{}{"tenderId"},
+			want: "неверный формат tenderId"},
+		{name: "english violations", language: languageEnglish, code: codeValidation, args: []interface{}{vs},
+			want: "validation error: name: maximum string length is 100; authorId: value is not a valid uuid; status: value is odd"},
+		{name: "russian violations", language: languageRussian, code: codeValidation, args: []interface{}{vs},
+			want: "ошибка валидации: name: максимальная длина строки 100; authorId: значение не является корректным uuid; status: value is odd"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := formatMessage(tt.language, tt.code, tt.args); got != tt.want {
+				t.Errorf("formatMessage = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestErrorMessages checks that the translations take the arguments of the English messages.
+func TestErrorMessages(t *testing.T) {
+	for language, messages := range errorMessages {
+		for code, message := range messages {
+			entry, ok := errorCatalog[code]
+			if !ok {
+				t.Errorf("%s: %s is not in errorCatalog", language, code)
+				continue
+			}
+			if got, want := strings.Count(message, "%"), strings.Count(entry.message, "%"); got != want {
+				t.Errorf("%s: %s has %d verbs, want %d as in %q", language, code, got, want, entry.message)
+			}
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/noctusha/tender/internal/httpx"
+)
+
+type contextKey int
+
+const (
+	requestIDKey contextKey = iota
+	requestLogKey
+)
+
+const maxRequestIDLength = 100
+
+// RequestID gives every request an id, taken from the X-Request-ID header when the client
+// sends a usable one and generated otherwise, and returns it in the response header.
+func RequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get("X-Request-ID")
+		if !validRequestID(id) {
+			id = uuid.New().String()
+		}
+
+		w.Header().Set("X-Request-ID", id)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestIDKey, id)))
+	})
+}
+
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, c := range id {
+		if c < 0x21 || c > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+func requestID(r *http.Request) string {
+	id, _ := r.Context().Value(requestIDKey).(string)
+	return id
+}
+
+// responseRequestID is the request id the RequestID middleware put in the response header, for
+// code that has the response but not the request.
+func responseRequestID(w http.ResponseWriter) string {
+	return w.Header().Get("X-Request-ID")
+}
+
+// LogHandler adds the request id of the context to every record, so whatever is logged with
+// the context of a request, a failure of the repository included, can be traced to it. When the
+// request is traced, the record names its trace and span as well.
+type LogHandler struct {
+	slog.Handler
+}
+
+func (h LogHandler) Handle(ctx context.Context, record slog.Record) error {
+	if id, ok := ctx.Value(requestIDKey).(string); ok {
+		record.AddAttrs(slog.String("request_id", id))
+	}
+	if spanContext := trace.SpanContextFromContext(ctx); spanContext.IsValid() {
+		record.AddAttrs(slog.String("trace_id", spanContext.TraceID().String()), slog.String("span_id", spanContext.SpanID().String()))
+	}
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h LogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return LogHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h LogHandler) WithGroup(name string) slog.Handler {
+	return LogHandler{h.Handler.WithGroup(name)}
+}
+
+// requestLog is what AccessLog learns about a request while it is served.
+type requestLog struct {
+	actor string
+}
+
+// AccessLog logs every request once it is served: its method, route template, status, latency
+// and actor. The actor is the username of the query, or the one a handler records with setActor.
+func AccessLog(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		entry := &requestLog{actor: r.URL.Query().Get("username")}
+		recorder := httpx.NewStatusRecorder(w)
+
+		next.ServeHTTP(recorder, r.WithContext(context.WithValue(r.Context(), requestLogKey, entry)))
+
+		var route string
+		if current := mux.CurrentRoute(r); current != nil {
+			route, _ = current.GetPathTemplate()
+		}
+
+		slog.InfoContext(r.Context(), "request",
+			"method", r.Method,
+			"route", route,
+			"status", recorder.Status,
+			"latency_ms", float64(time.Since(start).Microseconds())/1000,
+			"actor", entry.actor,
+		)
+	})
+}
+
+// setActor names the user a request acts for in the access log, when the query does not.
+func setActor(r *http.Request, actor string) {
+	if entry, ok := r.Context().Value(requestLogKey).(*requestLog); ok && actor != "" {
+		entry.actor = actor
+	}
+}
@@ -0,0 +1,288 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/mail"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+
+	"github.com/noctusha/tender/connection"
+)
+
+type notificationPreferencesRequest struct {
+	Email        *string  `json:"email"`
+	EmailEnabled *bool    `json:"emailEnabled"`
+	Language     *string  `json:"language"`
+	MutedEvents  []string `json:"mutedEvents"`
+}
+
+const (
+	notificationStatusUnread = "unread"
+	notificationStatusRead   = "read"
+	notificationStatusAll    = "all"
+)
+
+type markNotificationsRequest struct {
+	IDs []string `json:"ids"`
+}
+
+// ListNotifications returns the inbox of the user, unread notifications by default
+// (?status=read or ?status=all for the others), with the number of unread ones.
+func (h *Handler) ListNotifications(w http.ResponseWriter, r *http.Request) {
+	var (
+		username string
+		read     *bool
+		page     connection.Page
+	)
+	read = new(bool)
+
+	for name, vals := range r.URL.Query() {
+		switch name {
+		case "username":
+			username = vals[0]
+		case "status":
+			switch vals[0] {
+			case notificationStatusUnread:
+				read = new(bool)
+			case notificationStatusRead:
+				read = new(bool)
+				*read = true
+			case notificationStatusAll:
+				read = nil
+			default:
+				respondError(w, problem(codeInvalidNotificationState,
+					vals[0], notificationStatusUnread, notificationStatusRead, notificationStatusAll))
+				return
+			}
+		default:
+			ok, err := applyPageParam(&page, name, vals)
+			if err != nil {
+				respondError(w, err)
+				return
+			}
+
+			if !ok {
+				respondError(w, problem(codeUnknownParameter, name))
+				return
+			}
+		}
+	}
+
+	userId, ok := h.userByUsername(w, r, username)
+	if !ok {
+		return
+	}
+
+	inbox, info, err := h.repository(r).Notifications(userId, read, page)
+	if err != nil {
+		respondError(w, fmt.Errorf("failed to select notification from database: %w", err))
+		return
+	}
+
+	unread, err := h.repository(r).UnreadNotificationsCount(userId)
+	if err != nil {
+		respondError(w, fmt.Errorf("failed to count notifications: %w", err))
+		return
+	}
+
+	setPageLink(w, r, info)
+	respondJSON(w, http.StatusOK, JSON{Notifications: &inbox, Unread: &unread, NextCursor: info.NextCursor, Total: info.Total})
+}
+
+func (h *Handler) MarkNotificationRead(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	notificationID, err := uuid.Parse(vars["notificationId"])
+	if err != nil {
+		respondError(w, problem(codeInvalidParameter, "notificationId"))
+		return
+	}
+
+	userId, ok := h.userFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	notification, found, err := h.repository(r).GetNotificationByID(notificationID, userId)
+	if err != nil {
+		respondError(w, fmt.Errorf("failed to get notification: %w", err))
+		return
+	}
+
+	if !found {
+		respondError(w, problem(codeNotificationNotFound))
+		return
+	}
+
+	if !notification.Read {
+		_, err = h.repository(r).MarkNotificationsRead(userId, []string{notification.ID})
+		if err != nil {
+			respondError(w, fmt.Errorf("failed to mark notification read: %w", err))
+			return
+		}
+
+		notification, _, err = h.repository(r).GetNotificationByID(notificationID, userId)
+		if err != nil {
+			respondError(w, fmt.Errorf("failed to get notification: %w", err))
+			return
+		}
+	}
+
+	respondJSON(w, http.StatusOK, notification)
+}
+
+// MarkNotificationsRead marks the listed notifications of the user as read, or every unread one
+// when the request has no ids.
+func (h *Handler) MarkNotificationsRead(w http.ResponseWriter, r *http.Request) {
+	userId, ok := h.userFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	var req markNotificationsRequest
+	if r.ContentLength != 0 {
+		err := json.NewDecoder(r.Body).Decode(&req)
+		if err != nil {
+			respondError(w, problem(codeInvalidJSON, err))
+			return
+		}
+	}
+
+	marked, err := h.repository(r).MarkNotificationsRead(userId, req.IDs)
+	if err != nil {
+		respondError(w, fmt.Errorf("failed to mark notifications read: %w", err))
+		return
+	}
+
+	unread, err := h.repository(r).UnreadNotificationsCount(userId)
+	if err != nil {
+		respondError(w, fmt.Errorf("failed to count notifications: %w", err))
+		return
+	}
+
+	respondJSON(w, http.StatusOK, JSON{Marked: &marked, Unread: &unread})
+}
+
+func (h *Handler) GetNotificationPreferences(w http.ResponseWriter, r *http.Request) {
+	userId, ok := h.userFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	preferences, found, err := h.repository(r).NotificationPreferencesByUserID(userId)
+	if err != nil {
+		respondError(w, fmt.Errorf("failed to get notification preferences: %w", err))
+		return
+	}
+
+	if !found {
+		respondError(w, problem(codeUserNotFound, r.URL.Query().Get("username")))
+		return
+	}
+
+	respondJSON(w, http.StatusOK, preferences)
+}
+
+// UpdateNotificationPreferences changes the fields present in the request and keeps the others.
+func (h *Handler) UpdateNotificationPreferences(w http.ResponseWriter, r *http.Request) {
+	userId, ok := h.userFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	var req notificationPreferencesRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		respondError(w, problem(codeInvalidJSON, err))
+		return
+	}
+
+	preferences, found, err := h.repository(r).NotificationPreferencesByUserID(userId)
+	if err != nil {
+		respondError(w, fmt.Errorf("failed to get notification preferences: %w", err))
+		return
+	}
+
+	if !found {
+		respondError(w, problem(codeUserNotFound, r.URL.Query().Get("username")))
+		return
+	}
+
+	previous := preferences
+	if req.Email != nil {
+		email := strings.TrimSpace(*req.Email)
+		if email != "" {
+			address, err := mail.ParseAddress(email)
+			if err != nil || address.Address != email {
+				respondError(w, problem(codeInvalidEmail))
+				return
+			}
+		}
+		preferences.Email = email
+	}
+
+	if req.EmailEnabled != nil {
+		preferences.EmailEnabled = *req.EmailEnabled
+	}
+
+	if req.Language != nil {
+		preferences.Language = *req.Language
+	}
+
+	if req.MutedEvents != nil {
+		preferences.MutedEvents = req.MutedEvents
+	}
+
+	err = h.repository(r).Transaction(func(repo *connection.Repository) error {
+		err := repo.UpdateNotificationPreferences(userId, &preferences)
+		if err != nil {
+			return fmt.Errorf("failed to save notification preferences: %w", err)
+		}
+		return h.audit(r, repo, r.URL.Query().Get("username"), auditActionEdit, auditEntityNotification, userId, previous, preferences)
+	})
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, preferences)
+}
+
+func (h *Handler) userFromRequest(w http.ResponseWriter, r *http.Request) (string, bool) {
+	var username string
+	for name, vals := range r.URL.Query() {
+		switch name {
+		case "username":
+			username = vals[0]
+		default:
+			respondError(w, problem(codeUnknownParameter, name))
+			return "", false
+		}
+	}
+
+	return h.userByUsername(w, r, username)
+}
+
+func (h *Handler) userByUsername(w http.ResponseWriter, r *http.Request, username string) (string, bool) {
+	if username == "" {
+		respondError(w, problem(codeMissingUsername))
+		return "", false
+	}
+
+	userId, found, err := h.repository(r).GetUserIDByUsername(username)
+	if err != nil {
+		respondError(w, fmt.Errorf("failed to get user by username: %w", err))
+		return "", false
+	}
+
+	if !found {
+		respondError(w, problem(codeUserNotFound, username))
+		return "", false
+	}
+
+	return userId, true
+}
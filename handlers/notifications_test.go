@@ -0,0 +1,29 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestListNotificationsRequest(t *testing.T) {
+	runRequestTests(t, http.MethodGet, func(h *Handler) http.HandlerFunc { return h.ListNotifications }, []requestTest{
+		{name: "status", target: "/?username=user1&status=new",
+			wantStatus: http.StatusBadRequest, wantCode: codeInvalidNotificationState},
+		{name: "unknown parameter", target: "/?username=user1&kind=bid.created",
+			wantStatus: http.StatusBadRequest, wantCode: codeUnknownParameter},
+		{name: "no username", target: "/?status=all",
+			wantStatus: http.StatusBadRequest, wantCode: codeMissingUsername},
+	})
+}
+
+func TestMarkNotificationReadRequest(t *testing.T) {
+	vars := map[string]string{"notificationId": "7c9e6679-7425-40de-944b-e07fc1f90ae7"}
+	runRequestTests(t, http.MethodPut, func(h *Handler) http.HandlerFunc { return h.MarkNotificationRead }, []requestTest{
+		{name: "notification id", target: "/?username=user1", vars: map[string]string{"notificationId": "42"},
+			wantStatus: http.StatusBadRequest, wantCode: codeInvalidParameter},
+		{name: "unknown parameter", target: "/?username=user1&status=read", vars: vars,
+			wantStatus: http.StatusBadRequest, wantCode: codeUnknownParameter},
+		{name: "no username", target: "/", vars: vars,
+			wantStatus: http.StatusBadRequest, wantCode: codeMissingUsername},
+	})
+}
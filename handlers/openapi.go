@@ -0,0 +1,247 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/gorilla/mux"
+
+	"github.com/noctusha/tender/internal/httpx"
+	"github.com/noctusha/tender/openapi"
+)
+
+// OpenAPI serves the OpenAPI document of the API.
+func (h *Handler) OpenAPI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(http.StatusOK)
+	_, err := w.Write(openapi.Document())
+	if err != nil {
+		slog.ErrorContext(r.Context(), "failed to write response", "error", err)
+	}
+}
+
+// ValidateRequests checks the parameters and the body of every request against the operation
+// of doc serving its route, before the handler runs. Handlers still check what the document
+// cannot express: unknown parameters, ownership and the state of the tender. Every violation is
+// reported, not only the first. Uploads are streamed to storage, so their bodies are not read
+// here.
+//
+// With OPENAPI_VALIDATE_RESPONSES=true JSON responses are checked as well, and mismatches are
+// logged; the response is sent unchanged.
+func ValidateRequests(doc *openapi3.T) mux.MiddlewareFunc {
+	validateResponses, _ := strconv.ParseBool(os.Getenv("OPENAPI_VALIDATE_RESPONSES"))
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			path, item, operation := openapi.Operation(doc, r)
+			if operation == nil {
+				slog.WarnContext(r.Context(), "no openapi operation for route", "method", r.Method, "route", path)
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			input := &openapi3filter.RequestValidationInput{
+				Request:     r,
+				PathParams:  mux.Vars(r),
+				QueryParams: r.URL.Query(),
+				Route: &routers.Route{
+					Spec:      doc,
+					Path:      path,
+					PathItem:  item,
+					Method:    r.Method,
+					Operation: operation,
+				},
+				Options: &openapi3filter.Options{
+					ExcludeRequestBody:  isMultipart(r),
+					SkipSettingDefaults: true,
+					MultiError:          true,
+				},
+			}
+
+			// Clients were never asked for a Content-Type, and every body but an upload is JSON.
+			if r.Header.Get("Content-Type") == "" && r.ContentLength != 0 {
+				r.Header.Set("Content-Type", "application/json")
+			}
+
+			err := openapi3filter.ValidateRequest(r.Context(), input)
+			if err != nil {
+				respondError(w, validationProblem(validationViolations(err)))
+				return
+			}
+
+			if !validateResponses {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			recorder := &responseRecorder{StatusRecorder: httpx.NewStatusRecorder(w)}
+			next.ServeHTTP(recorder, r)
+
+			if !isJSON(recorder.Header()) {
+				return
+			}
+
+			err = openapi3filter.ValidateResponse(r.Context(), &openapi3filter.ResponseValidationInput{
+				RequestValidationInput: input,
+				Status:                 recorder.Status,
+				Header:                 recorder.Header(),
+				Body:                   io.NopCloser(&recorder.body),
+				Options:                &openapi3filter.Options{IncludeResponseStatus: true},
+			})
+			if err != nil {
+				slog.WarnContext(r.Context(), "response does not match openapi document", "method", r.Method, "route", path, "error", err)
+			}
+		})
+	}
+}
+
+// validationViolations names every parameter and body field that failed validation and the rule
+// it broke.
+func validationViolations(err error) []violation {
+	switch err := err.(type) {
+	case openapi3.MultiError:
+		var vs []violation
+		for _, e := range err {
+			vs = append(vs, validationViolations(e)...)
+		}
+		return vs
+	case *openapi3filter.RequestError:
+		return requestViolations(err)
+	}
+	return []violation{{reason: err.Error()}}
+}
+
+func requestViolations(requestErr *openapi3filter.RequestError) []violation {
+	var field string
+	if requestErr.Parameter != nil {
+		field = requestErr.Parameter.Name
+	}
+
+	if vs := schemaViolations(field, requestErr.Err); len(vs) > 0 {
+		return vs
+	}
+
+	if errors.Is(requestErr.Err, openapi3filter.ErrInvalidRequired) {
+		return []violation{{field: field, rule: "required"}}
+	}
+
+	var parseErr *openapi3filter.ParseError
+	if errors.As(requestErr.Err, &parseErr) && requestErr.Parameter != nil && requestErr.Parameter.Schema != nil {
+		if schema := requestErr.Parameter.Schema.Value; schema != nil && schema.Type != "array" {
+			return []violation{{field: field, rule: "type", limit: schema.Type, reason: parseErr.Error()}}
+		}
+	}
+
+	reason := requestErr.Reason
+	if reason == "" && requestErr.Err != nil {
+		reason = requestErr.Err.Error()
+	}
+	return []violation{{field: field, reason: reason}}
+}
+
+// schemaViolations lists the schema errors in err, nil when it holds none.
+func schemaViolations(field string, err error) []violation {
+	switch err := err.(type) {
+	case openapi3.MultiError:
+		var vs []violation
+		for _, e := range err {
+			nested := schemaViolations(field, e)
+			if nested == nil {
+				nested = []violation{{field: field, reason: e.Error()}}
+			}
+			vs = append(vs, nested...)
+		}
+		return vs
+	case *openapi3.SchemaError:
+		if pointer := strings.Join(err.JSONPointer(), "."); pointer != "" {
+			field = strings.TrimPrefix(field+"."+pointer, ".")
+		}
+		return []violation{{
+			field:  field,
+			rule:   err.SchemaField,
+			limit:  ruleLimit(err),
+			reason: err.Reason,
+		}}
+	}
+	return nil
+}
+
+// ruleLimit is the value of the rule a schema error broke, as it reads in a message.
+func ruleLimit(err *openapi3.SchemaError) string {
+	schema := err.Schema
+	if schema == nil {
+		return ""
+	}
+
+	switch err.SchemaField {
+	case "enum":
+		values, marshalErr := json.Marshal(schema.Enum)
+		if marshalErr != nil {
+			return ""
+		}
+		return string(values)
+	case "pattern":
+		return schema.Pattern
+	case "format":
+		return schema.Format
+	case "type":
+		return schema.Type
+	case "minLength":
+		return strconv.FormatUint(schema.MinLength, 10)
+	case "maxLength":
+		if schema.MaxLength != nil {
+			return strconv.FormatUint(*schema.MaxLength, 10)
+		}
+	case "minimum":
+		if schema.Min != nil {
+			return strconv.FormatFloat(*schema.Min, 'g', -1, 64)
+		}
+	case "maximum":
+		if schema.Max != nil {
+			return strconv.FormatFloat(*schema.Max, 'g', -1, 64)
+		}
+	case "minItems":
+		return strconv.FormatUint(schema.MinItems, 10)
+	case "maxItems":
+		if schema.MaxItems != nil {
+			return strconv.FormatUint(*schema.MaxItems, 10)
+		}
+	}
+	return ""
+}
+
+func isMultipart(r *http.Request) bool {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return err == nil && mediaType == "multipart/form-data"
+}
+
+// isJSON reports whether a response is JSON, a problem included.
+func isJSON(header http.Header) bool {
+	mediaType, _, err := mime.ParseMediaType(header.Get("Content-Type"))
+	return err == nil && (mediaType == "application/json" || mediaType == "application/problem+json")
+}
+
+// responseRecorder passes a response through and keeps its status and a copy of its body for
+// validation.
+type responseRecorder struct {
+	*httpx.StatusRecorder
+	body bytes.Buffer
+}
+
+func (rec *responseRecorder) Write(b []byte) (int, error) {
+	if isJSON(rec.Header()) {
+		rec.body.Write(b)
+	}
+	return rec.ResponseWriter.Write(b)
+}
@@ -0,0 +1,328 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+
+	"github.com/noctusha/tender/connection"
+	"github.com/noctusha/tender/models"
+)
+
+func (h *Handler) NewQuestion(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	tenderID, err := uuid.Parse(vars["tenderId"])
+	if err != nil {
+		respondError(w, problem(codeInvalidParameter, "tenderId"))
+		return
+	}
+
+	var username string
+	for name, vals := range r.URL.Query() {
+		switch name {
+		case "username":
+			username = vals[0]
+		default:
+			respondError(w, problem(codeUnknownParameter, name))
+			return
+		}
+	}
+
+	if username == "" {
+		respondError(w, problem(codeMissingUsername))
+		return
+	}
+
+	var question models.TenderQuestion
+	err = json.NewDecoder(r.Body).Decode(&question)
+	if err != nil {
+		respondError(w, problem(codeInvalidJSON, err))
+		return
+	}
+
+	question.Question = strings.TrimSpace(question.Question)
+	if question.Question == "" {
+		respondError(w, problem(codeQuestionRequired))
+		return
+	}
+
+	tender, ok, err := h.repository(r).GetTenderByID(tenderID)
+	if err != nil {
+		respondError(w, fmt.Errorf("failed to get tender: %w", err))
+		return
+	}
+
+	if !ok {
+		respondError(w, problem(codeTenderNotFound))
+		return
+	}
+
+	organizationId, userFound, err := h.repository(r).GetOrganizationIDByUsername(username)
+	if err != nil {
+		respondError(w, fmt.Errorf("failed to get organization by username: %w", err))
+		return
+	}
+
+	if !userFound {
+		respondError(w, problem(codeUserNotFound, username))
+		return
+	}
+
+	if tender.OrganizationID == organizationId {
+		respondError(w, problem(codeOwnTenderQuestion))
+		return
+	}
+
+	allowed, err := h.canAccessTender(r, tender, organizationId)
+	if err != nil {
+		respondError(w, fmt.Errorf("failed to check tender invitation: %w", err))
+		return
+	}
+
+	if !allowed {
+		respondError(w, problem(codeTenderForbidden, username))
+		return
+	}
+
+	if tender.Status != statusPublished {
+		respondError(w, problem(codeTenderNotPublished))
+		return
+	}
+
+	question = models.TenderQuestion{
+		ID:             uuid.New().String(),
+		TenderID:       tender.ID,
+		Question:       question.Question,
+		AuthorUsername: username,
+		OrganizationID: organizationId,
+	}
+
+	err = h.repository(r).Transaction(func(repo *connection.Repository) error {
+		err := repo.NewQuestion(question)
+		if err != nil {
+			return fmt.Errorf("failed to save question: %w", err)
+		}
+		return h.audit(r, repo, username, auditActionCreate, auditEntityQuestion, question.ID, nil, question, tender.OrganizationID, organizationId)
+	})
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, question)
+}
+
+// ListQuestions returns the whole thread to the tender organization. Other organizations see
+// their own questions and the published answers to everyone else's, with the asker hidden.
+func (h *Handler) ListQuestions(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	tenderID, err := uuid.Parse(vars["tenderId"])
+	if err != nil {
+		respondError(w, problem(codeInvalidParameter, "tenderId"))
+		return
+	}
+
+	var username string
+	for name, vals := range r.URL.Query() {
+		switch name {
+		case "username":
+			username = vals[0]
+		default:
+			respondError(w, problem(codeUnknownParameter, name))
+			return
+		}
+	}
+
+	if username == "" {
+		respondError(w, problem(codeMissingUsername))
+		return
+	}
+
+	tender, ok, err := h.repository(r).GetTenderByID(tenderID)
+	if err != nil {
+		respondError(w, fmt.Errorf("failed to get tender: %w", err))
+		return
+	}
+
+	if !ok {
+		respondError(w, problem(codeTenderNotFound))
+		return
+	}
+
+	organizationId, userFound, err := h.repository(r).GetOrganizationIDByUsername(username)
+	if err != nil {
+		respondError(w, fmt.Errorf("failed to get organization by username: %w", err))
+		return
+	}
+
+	if !userFound {
+		respondError(w, problem(codeUserNotFound, username))
+		return
+	}
+
+	allowed, err := h.canAccessTender(r, tender, organizationId)
+	if err != nil {
+		respondError(w, fmt.Errorf("failed to check tender invitation: %w", err))
+		return
+	}
+
+	if !allowed {
+		respondError(w, problem(codeTenderForbidden, username))
+		return
+	}
+
+	questions, err := h.repository(r).QuestionsByTenderID(tender.ID)
+	if err != nil {
+		respondError(w, fmt.Errorf("failed to select question from database: %w", err))
+		return
+	}
+
+	if tender.OrganizationID != organizationId {
+		questions = visibleQuestions(questions, organizationId)
+	}
+
+	respondJSON(w, http.StatusOK, JSON{Questions: &questions})
+}
+
+// visibleQuestions is the part of the thread an organization other than the tender organization
+// sees: its own questions and the published answers, with the asker hidden.
+func visibleQuestions(questions []models.TenderQuestion, organizationId string) []models.TenderQuestion {
+	visible := []models.TenderQuestion{}
+	for _, question := range questions {
+		switch {
+		case question.OrganizationID == organizationId:
+			visible = append(visible, question)
+		case question.Public && question.AnsweredAt != "":
+			question.AuthorUsername = ""
+			question.OrganizationID = ""
+			visible = append(visible, question)
+		}
+	}
+	return visible
+}
+
+// answeredQuestion is the data of a question.answered event. A public answer is shown to every
+// participant, so the asker is hidden as in ListQuestions.
+type answeredQuestion struct {
+	question *models.TenderQuestion
+}
+
+func (a answeredQuestion) MarshalJSON() ([]byte, error) {
+	question := *a.question
+	if question.Public {
+		question.AuthorUsername = ""
+		question.OrganizationID = ""
+	}
+	return json.Marshal(question)
+}
+
+func (h *Handler) AnswerQuestion(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	tenderID, err := uuid.Parse(vars["tenderId"])
+	if err != nil {
+		respondError(w, problem(codeInvalidParameter, "tenderId"))
+		return
+	}
+
+	questionID, err := uuid.Parse(vars["questionId"])
+	if err != nil {
+		respondError(w, problem(codeInvalidParameter, "questionId"))
+		return
+	}
+
+	var username string
+	for name, vals := range r.URL.Query() {
+		switch name {
+		case "username":
+			username = vals[0]
+		default:
+			respondError(w, problem(codeUnknownParameter, name))
+			return
+		}
+	}
+
+	if username == "" {
+		respondError(w, problem(codeMissingUsername))
+		return
+	}
+
+	var answer models.TenderQuestion
+	err = json.NewDecoder(r.Body).Decode(&answer)
+	if err != nil {
+		respondError(w, problem(codeInvalidJSON, err))
+		return
+	}
+
+	answer.Answer = strings.TrimSpace(answer.Answer)
+	if answer.Answer == "" {
+		respondError(w, problem(codeAnswerRequired))
+		return
+	}
+
+	tender, ok, err := h.repository(r).GetTenderByID(tenderID)
+	if err != nil {
+		respondError(w, fmt.Errorf("failed to get tender: %w", err))
+		return
+	}
+
+	if !ok {
+		respondError(w, problem(codeTenderNotFound))
+		return
+	}
+
+	organizationId, userFound, err := h.repository(r).GetOrganizationIDByUsername(username)
+	if err != nil {
+		respondError(w, fmt.Errorf("failed to get organization by username: %w", err))
+		return
+	}
+
+	if !userFound {
+		respondError(w, problem(codeUserNotFound, username))
+		return
+	}
+
+	if tender.OrganizationID != organizationId {
+		respondError(w, problem(codeTenderForbidden, username))
+		return
+	}
+
+	question, ok, err := h.repository(r).GetQuestionByID(questionID)
+	if err != nil {
+		respondError(w, fmt.Errorf("failed to get question: %w", err))
+		return
+	}
+
+	if !ok || question.TenderID != tender.ID {
+		respondError(w, problem(codeQuestionNotFound))
+		return
+	}
+
+	previous := *question
+	question.Answer = answer.Answer
+	question.AnsweredBy = username
+	question.Public = answer.Public
+
+	event := connection.NewTenderEvent(connection.EventQuestionAnswered, tender.ID, answeredQuestion{question}, tender.OrganizationID, question.OrganizationID)
+	event.Restricted = !question.Public
+	err = h.repository(r).Transaction(func(repo *connection.Repository) error {
+		err := repo.AnswerQuestion(question, event)
+		if err != nil {
+			return fmt.Errorf("failed to answer question: %w", err)
+		}
+		return h.audit(r, repo, username, auditActionEdit, auditEntityQuestion, question.ID, previous, question, tender.OrganizationID, question.OrganizationID)
+	})
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+	h.outbox.Wake()
+
+	respondJSON(w, http.StatusOK, question)
+}
@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/noctusha/tender/models"
+)
+
+func TestVisibleQuestions(t *testing.T) {
+	const (
+		ownOrganization   = "a1b2c3d4-e5f6-7890-abcd-ef1234567890"
+		otherOrganization = "7c9e6679-7425-40de-944b-e07fc1f90ae7"
+	)
+
+	own := models.TenderQuestion{ID: "1", Question: "Сроки?", AuthorUsername: "user1", OrganizationID: ownOrganization}
+	ownAnswered := models.TenderQuestion{ID: "2", Question: "Оплата?", AuthorUsername: "user1", OrganizationID: ownOrganization,
+		Answer: "По факту", AnsweredAt: "2024-03-01T12:00:00Z"}
+	otherPublic := models.TenderQuestion{ID: "3", Question: "Доставка?", AuthorUsername: "user2", OrganizationID: otherOrganization,
+		Answer: "Включена", Public: true, AnsweredAt: "2024-03-01T12:00:00Z"}
+	otherPrivate := models.TenderQuestion{ID: "4", Question: "Скидка?", AuthorUsername: "user2", OrganizationID: otherOrganization,
+		Answer: "Нет", AnsweredAt: "2024-03-01T12:00:00Z"}
+	otherUnanswered := models.TenderQuestion{ID: "5", Question: "Гарантия?", AuthorUsername: "user2", OrganizationID: otherOrganization,
+		Public: true}
+
+	hiddenAsker := otherPublic
+	hiddenAsker.AuthorUsername = ""
+	hiddenAsker.OrganizationID = ""
+
+	tests := []struct {
+		name      string
+		questions []models.TenderQuestion
+		want      []models.TenderQuestion
+	}{
+		{name: "no questions", want: []models.TenderQuestion{}},
+		{name: "own questions", questions: []models.TenderQuestion{own, ownAnswered}, want: []models.TenderQuestion{own, ownAnswered}},
+		{name: "public answer without the asker", questions: []models.TenderQuestion{otherPublic}, want: []models.TenderQuestion{hiddenAsker}},
+		{name: "private answer", questions: []models.TenderQuestion{otherPrivate}, want: []models.TenderQuestion{}},
+		{name: "public question not answered yet", questions: []models.TenderQuestion{otherUnanswered}, want: []models.TenderQuestion{}},
+		{name: "thread", questions: []models.TenderQuestion{own, otherPrivate, otherPublic, otherUnanswered, ownAnswered},
+			want: []models.TenderQuestion{own, hiddenAsker, ownAnswered}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := visibleQuestions(tt.questions, ownOrganization); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("visibleQuestions = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAnsweredQuestionJSON(t *testing.T) {
+	tests := []struct {
+		name      string
+		public    bool
+		wantAsker bool
+	}{
+		{name: "public answer hides the asker", public: true},
+		{name: "private answer goes to the asker", public: false, wantAsker: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			question := &models.TenderQuestion{ID: "1", Question: "Сроки?", AuthorUsername: "user1",
+				OrganizationID: "a1b2c3d4-e5f6-7890-abcd-ef1234567890", Answer: "Май", Public: tt.public}
+
+			data, err := json.Marshal(answeredQuestion{question: question})
+			if err != nil {
+				t.Fatalf("marshal: %v", err)
+			}
+			var got models.TenderQuestion
+			err = json.Unmarshal(data, &got)
+			if err != nil {
+				t.Fatalf("unmarshal: %v", err)
+			}
+
+			if hasAsker := got.AuthorUsername != "" || got.OrganizationID != ""; hasAsker != tt.wantAsker {
+				t.Errorf("event = %s, asker shown %v, want %v", data, hasAsker, tt.wantAsker)
+			}
+			if got.Answer != question.Answer || question.AuthorUsername != "user1" {
+				t.Errorf("event = %s, the question itself was changed: %+v", data, question)
+			}
+		})
+	}
+}
+
+func TestQuestionRequests(t *testing.T) {
+	vars := map[string]string{"tenderId": testTenderID, "questionId": "7c9e6679-7425-40de-944b-e07fc1f90ae7"}
+
+	t.Run("new", func(t *testing.T) {
+		runRequestTests(t, http.MethodPost, func(h *Handler) http.HandlerFunc { return h.NewQuestion }, []requestTest{
+			{name: "tender id", target: "/?username=user1", vars: map[string]string{"tenderId": "42"}, body: `{"question":"Сроки?"}`,
+				wantStatus: http.StatusBadRequest, wantCode: codeInvalidParameter},
+			{name: "no username", target: "/", vars: vars, body: `{"question":"Сроки?"}`,
+				wantStatus: http.StatusBadRequest, wantCode: codeMissingUsername},
+			{name: "blank question", target: "/?username=user1", vars: vars, body: `{"question":"  \n"}`,
+				wantStatus: http.StatusBadRequest, wantCode: codeQuestionRequired},
+		})
+	})
+
+	t.Run("answer", func(t *testing.T) {
+		runRequestTests(t, http.MethodPut, func(h *Handler) http.HandlerFunc { return h.AnswerQuestion }, []requestTest{
+			{name: "question id", target: "/?username=user1", vars: map[string]string{"tenderId": testTenderID, "questionId": "42"},
+				body: `{"answer":"Май"}`, wantStatus: http.StatusBadRequest, wantCode: codeInvalidParameter},
+			{name: "unknown parameter", target: "/?username=user1&public=true", vars: vars, body: `{"answer":"Май"}`,
+				wantStatus: http.StatusBadRequest, wantCode: codeUnknownParameter},
+			{name: "blank answer", target: "/?username=user1", vars: vars, body: `{"answer":" ","public":true}`,
+				wantStatus: http.StatusBadRequest, wantCode: codeAnswerRequired},
+		})
+	})
+}
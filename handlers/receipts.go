@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+
+	"github.com/noctusha/tender/models"
+)
+
+// bidSubmission is a new bid with the signed receipt of its submission.
+type bidSubmission struct {
+	models.Bid
+	Receipt *models.BidReceipt `json:"receipt,omitempty"`
+}
+
+type publicKeyInfo struct {
+	Algorithm string `json:"algorithm"`
+	PublicKey string `json:"publicKey"`
+}
+
+// BidReceipt returns the signed receipt of the latest sealed version of a bid.
+func (h *Handler) BidReceipt(w http.ResponseWriter, r *http.Request) {
+	bid, ok := h.bidReaderFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	seal, found, err := h.repository(r).LastBidSeal(bid.ID)
+	if err != nil {
+		respondError(w, fmt.Errorf("failed to get bid seal: %w", err))
+		return
+	}
+
+	if !found {
+		respondError(w, problem(codeBidNotSealed))
+		return
+	}
+
+	receipt, err := h.receipts.Receipt(seal)
+	if err != nil {
+		respondError(w, fmt.Errorf("failed to sign receipt: %w", err))
+		return
+	}
+
+	respondJSON(w, http.StatusOK, receipt)
+}
+
+// ReceiptKey publishes the public key that receipts are signed with.
+func (h *Handler) ReceiptKey(w http.ResponseWriter, r *http.Request) {
+	for name := range r.URL.Query() {
+		respondError(w, problem(codeUnknownParameter, name))
+		return
+	}
+
+	respondJSON(w, http.StatusOK, publicKeyInfo{
+		Algorithm: "Ed25519",
+		PublicKey: base64.StdEncoding.EncodeToString(h.receipts.PublicKey()),
+	})
+}
+
+// VerifyTender walks the chain of the bids of a tender and reports the first broken link.
+func (h *Handler) VerifyTender(w http.ResponseWriter, r *http.Request) {
+	tender, _, _, ok := h.tenderFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	verification, err := h.repository(r).VerifyBidChain(tender.ID)
+	if err != nil {
+		respondError(w, fmt.Errorf("failed to verify bid chain: %w", err))
+		return
+	}
+
+	respondJSON(w, http.StatusOK, verification)
+}
+
+// VerifyAudit walks the whole audit log and reports the first broken link. Administrators only.
+func (h *Handler) VerifyAudit(w http.ResponseWriter, r *http.Request) {
+	if !h.adminFromRequest(w, r) {
+		return
+	}
+
+	verification, err := h.repository(r).VerifyAuditChain()
+	if err != nil {
+		respondError(w, fmt.Errorf("failed to verify audit chain: %w", err))
+		return
+	}
+
+	respondJSON(w, http.StatusOK, verification)
+}
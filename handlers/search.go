@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/noctusha/tender/connection"
+)
+
+const maxSearchQueryLength = 200
+
+func (h *Handler) SearchTenders(w http.ResponseWriter, r *http.Request) {
+	var (
+		filter   connection.TenderSearchFilter
+		username string
+		err      error
+	)
+	for name, vals := range r.URL.Query() {
+		switch name {
+		case "q":
+			filter.Query = strings.TrimSpace(vals[0])
+		case "status":
+			filter.Statuses, err = parseTenderStatuses(vals)
+			if err != nil {
+				respondError(w, err)
+				return
+			}
+		case "sort":
+			filter.Sort, err = connection.ParseSearchSort(vals[0])
+			if err != nil {
+				respondError(w, err)
+				return
+			}
+		case "username":
+			username = vals[0]
+		default:
+			ok, err := applyTenderFilterParam(&filter.TenderFilter, name, vals)
+			if err != nil {
+				respondError(w, err)
+				return
+			}
+
+			if !ok {
+				respondError(w, problem(codeUnknownParameter, name))
+				return
+			}
+		}
+	}
+
+	if filter.Query == "" {
+		respondError(w, problem(codeMissingSearchQuery))
+		return
+	}
+
+	if utf8.RuneCountInString(filter.Query) > maxSearchQueryLength {
+		respondError(w, problem(codeSearchQueryTooLong, maxSearchQueryLength))
+		return
+	}
+
+	if !h.checkServiceTypes(w, r, filter.ServiceTypes...) {
+		return
+	}
+
+	if username != "" {
+		organizationId, userFound, err := h.repository(r).GetOrganizationIDByUsername(username)
+		if err != nil {
+			respondError(w, fmt.Errorf("failed to get organization by username: %w", err))
+			return
+		}
+
+		if !userFound {
+			respondError(w, problem(codeUserNotFound, username))
+			return
+		}
+		filter.ViewerOrganizationID = organizationId
+	}
+
+	results, page, err := h.repository(r).SearchTenders(filter)
+	if err != nil {
+		respondError(w, fmt.Errorf("failed to search tender: %w", err))
+		return
+	}
+
+	setPageLink(w, r, page)
+	respondJSON(w, http.StatusOK, JSON{SearchResults: &results, NextCursor: page.NextCursor, Total: page.Total})
+}
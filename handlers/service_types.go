@@ -0,0 +1,320 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+
+	"github.com/noctusha/tender/connection"
+	"github.com/noctusha/tender/models"
+)
+
+const maxServiceTypeNameLength = 100
+
+// checkServiceTypes reports whether every name is a registered service type.
+// It writes the error response itself and reports false otherwise.
+func (h *Handler) checkServiceTypes(w http.ResponseWriter, r *http.Request, names ...string) bool {
+	unknown, err := h.repository(r).UnknownServiceTypes(names)
+	if err != nil {
+		respondError(w, fmt.Errorf("failed to check service types: %w", err))
+		return false
+	}
+
+	if len(unknown) > 0 {
+		respondError(w, problem(codeUnknownServiceType, strings.Join(unknown, ", ")))
+		return false
+	}
+
+	return true
+}
+
+func (h *Handler) ListServiceTypes(w http.ResponseWriter, r *http.Request) {
+	for name := range r.URL.Query() {
+		respondError(w, problem(codeUnknownParameter, name))
+		return
+	}
+
+	serviceTypes, err := h.repository(r).ServiceTypesList()
+	if err != nil {
+		respondError(w, fmt.Errorf("failed to select service type from database: %w", err))
+		return
+	}
+
+	respondJSON(w, http.StatusOK, JSON{ServiceTypes: &serviceTypes})
+}
+
+func (h *Handler) NewServiceType(w http.ResponseWriter, r *http.Request) {
+	if !h.adminFromRequest(w, r) {
+		return
+	}
+
+	var serviceType models.ServiceType
+	err := json.NewDecoder(r.Body).Decode(&serviceType)
+	if err != nil {
+		respondError(w, problem(codeInvalidJSON, err))
+		return
+	}
+
+	serviceType.Name = strings.TrimSpace(serviceType.Name)
+	if err := validateServiceTypeName(serviceType.Name); err != nil {
+		respondError(w, err)
+		return
+	}
+
+	unknown, err := h.repository(r).UnknownServiceTypes([]string{serviceType.Name})
+	if err != nil {
+		respondError(w, fmt.Errorf("failed to check service types: %w", err))
+		return
+	}
+
+	if len(unknown) == 0 {
+		respondError(w, problem(codeServiceTypeExists, serviceType.Name))
+		return
+	}
+
+	serviceType.ID = uuid.New().String()
+	if !h.checkServiceTypeParent(w, r, serviceType) {
+		return
+	}
+
+	err = h.repository(r).Transaction(func(repo *connection.Repository) error {
+		err := repo.NewServiceType(&serviceType)
+		if err != nil {
+			return fmt.Errorf("failed to save service type: %w", err)
+		}
+		return h.audit(r, repo, r.URL.Query().Get("username"), auditActionCreate, auditEntityServiceType, serviceType.ID, nil, serviceType)
+	})
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, serviceType)
+}
+
+// EditServiceType renames, describes or moves a service type within the tree.
+// An empty parentId leaves the parent as is, "root" makes the type a top-level category.
+func (h *Handler) EditServiceType(w http.ResponseWriter, r *http.Request) {
+	serviceType, ok := h.serviceTypeFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	var update models.ServiceType
+	err := json.NewDecoder(r.Body).Decode(&update)
+	if err != nil {
+		respondError(w, problem(codeInvalidJSON, err))
+		return
+	}
+
+	previous := *serviceType
+	previousName := serviceType.Name
+
+	update.Name = strings.TrimSpace(update.Name)
+	if update.Name != "" && update.Name != serviceType.Name {
+		if err := validateServiceTypeName(update.Name); err != nil {
+			respondError(w, err)
+			return
+		}
+
+		unknown, err := h.repository(r).UnknownServiceTypes([]string{update.Name})
+		if err != nil {
+			respondError(w, fmt.Errorf("failed to check service types: %w", err))
+			return
+		}
+
+		if len(unknown) == 0 {
+			respondError(w, problem(codeServiceTypeExists, update.Name))
+			return
+		}
+		serviceType.Name = update.Name
+	}
+	if update.Description != "" {
+		serviceType.Description = update.Description
+	}
+	switch update.ParentID {
+	case "":
+		break
+	case "root":
+		serviceType.ParentID = ""
+	default:
+		serviceType.ParentID = update.ParentID
+		if !h.checkServiceTypeParent(w, r, *serviceType) {
+			return
+		}
+	}
+
+	err = h.repository(r).Transaction(func(repo *connection.Repository) error {
+		err := repo.UpdateServiceType(serviceType, previousName)
+		if err != nil {
+			return fmt.Errorf("failed to update service type: %w", err)
+		}
+		return h.audit(r, repo, r.URL.Query().Get("username"), auditActionEdit, auditEntityServiceType, serviceType.ID, previous, serviceType)
+	})
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, serviceType)
+}
+
+func (h *Handler) DeleteServiceType(w http.ResponseWriter, r *http.Request) {
+	serviceType, ok := h.serviceTypeFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	used, err := h.repository(r).ServiceTypeInUse(*serviceType)
+	if err != nil {
+		respondError(w, fmt.Errorf("failed to check service type usage: %w", err))
+		return
+	}
+
+	if used {
+		respondError(w, problem(codeServiceTypeInUse, serviceType.Name))
+		return
+	}
+
+	err = h.repository(r).Transaction(func(repo *connection.Repository) error {
+		err := repo.DeleteServiceType(serviceType.ID)
+		if err != nil {
+			return fmt.Errorf("failed to delete service type: %w", err)
+		}
+		return h.audit(r, repo, r.URL.Query().Get("username"), auditActionDelete, auditEntityServiceType, serviceType.ID, serviceType, nil)
+	})
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, serviceType)
+}
+
+func validateServiceTypeName(name string) error {
+	if name == "" {
+		return problem(codeServiceTypeNameRequired)
+	}
+
+	if utf8.RuneCountInString(name) > maxServiceTypeNameLength {
+		return problem(codeServiceTypeNameTooLong, maxServiceTypeNameLength)
+	}
+
+	if name == "root" {
+		return problem(codeServiceTypeNameReserved, name)
+	}
+
+	return nil
+}
+
+// checkServiceTypeParent makes sure the parent exists and is not the type itself or one of its
+// children, which would turn the tree into a cycle.
+func (h *Handler) checkServiceTypeParent(w http.ResponseWriter, r *http.Request, serviceType models.ServiceType) bool {
+	if serviceType.ParentID == "" {
+		return true
+	}
+
+	parentID, err := uuid.Parse(serviceType.ParentID)
+	if err != nil {
+		respondError(w, problem(codeInvalidParameter, "parentId"))
+		return false
+	}
+
+	_, found, err := h.repository(r).GetServiceTypeByID(parentID)
+	if err != nil {
+		respondError(w, fmt.Errorf("failed to get service type: %w", err))
+		return false
+	}
+
+	if !found {
+		respondError(w, problem(codeParentServiceTypeNotFound))
+		return false
+	}
+
+	cycle, err := h.repository(r).IsServiceTypeDescendant(parentID.String(), serviceType.ID)
+	if err != nil {
+		respondError(w, fmt.Errorf("failed to check service type tree: %w", err))
+		return false
+	}
+
+	if cycle {
+		respondError(w, problem(codeServiceTypeCycle))
+		return false
+	}
+
+	return true
+}
+
+// adminFromRequest checks that the username parameter names an administrator.
+// It writes the error response itself and reports false otherwise.
+func (h *Handler) adminFromRequest(w http.ResponseWriter, r *http.Request) bool {
+	var username string
+	for name, vals := range r.URL.Query() {
+		switch name {
+		case "username":
+			username = vals[0]
+		default:
+			respondError(w, problem(codeUnknownParameter, name))
+			return false
+		}
+	}
+
+	if username == "" {
+		respondError(w, problem(codeMissingUsername))
+		return false
+	}
+
+	_, userFound, err := h.repository(r).GetUserIDByUsername(username)
+	if err != nil {
+		respondError(w, fmt.Errorf("failed to get user: %w", err))
+		return false
+	}
+
+	if !userFound {
+		respondError(w, problem(codeUserNotFound, username))
+		return false
+	}
+
+	admin, err := h.repository(r).IsAdmin(username)
+	if err != nil {
+		respondError(w, fmt.Errorf("failed to check admin rights: %w", err))
+		return false
+	}
+
+	if !admin {
+		respondError(w, problem(codeNotAdministrator, username))
+		return false
+	}
+
+	return true
+}
+
+func (h *Handler) serviceTypeFromRequest(w http.ResponseWriter, r *http.Request) (*models.ServiceType, bool) {
+	serviceTypeID, err := uuid.Parse(mux.Vars(r)["serviceTypeId"])
+	if err != nil {
+		respondError(w, problem(codeInvalidParameter, "serviceTypeId"))
+		return nil, false
+	}
+
+	if !h.adminFromRequest(w, r) {
+		return nil, false
+	}
+
+	serviceType, found, err := h.repository(r).GetServiceTypeByID(serviceTypeID)
+	if err != nil {
+		respondError(w, fmt.Errorf("failed to get service type: %w", err))
+		return nil, false
+	}
+
+	if !found {
+		respondError(w, problem(codeServiceTypeNotFound))
+		return nil, false
+	}
+
+	return serviceType, true
+}
@@ -0,0 +1,38 @@
+package handlers
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestValidateServiceTypeName(t *testing.T) {
+	tests := []struct {
+		name     string
+		in       string
+		wantCode string
+	}{
+		{name: "plain", in: "Construction"},
+		{name: "cyrillic", in: "Строительство"},
+		{name: "longest", in: strings.Repeat("я", maxServiceTypeNameLength)},
+		{name: "empty", in: "", wantCode: codeServiceTypeNameRequired},
+		{name: "too long", in: strings.Repeat("я", maxServiceTypeNameLength+1), wantCode: codeServiceTypeNameTooLong},
+		{name: "root", in: "root", wantCode: codeServiceTypeNameReserved},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateServiceTypeName(tt.in)
+			if tt.wantCode == "" {
+				if err != nil {
+					t.Errorf("validateServiceTypeName(%q) = %v, want nil", tt.in, err)
+				}
+				return
+			}
+
+			var apiErr *apiError
+			if !errors.As(err, &apiErr) || apiErr.code != tt.wantCode {
+				t.Errorf("validateServiceTypeName(%q) = %v, want %s", tt.in, err, tt.wantCode)
+			}
+		})
+	}
+}
@@ -0,0 +1,270 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/noctusha/tender/connection"
+	"github.com/noctusha/tender/models"
+)
+
+const signatureAlgorithm = "Ed25519"
+
+type signingKeyRequest struct {
+	PublicKey string `json:"publicKey"`
+}
+
+// newBidRequest is a bid with the signature of its version. SignedBy names the employee who
+// signed a bid on behalf of an organization; a user's bid is signed by its author.
+type newBidRequest struct {
+	models.Bid
+	Signature string `json:"signature"`
+	SignedBy  string `json:"signedBy"`
+}
+
+type editBidRequest struct {
+	models.Bid
+	Signature string `json:"signature"`
+}
+
+// signedBidVersion is what the author of a bid signs: these fields in this order, as compact
+// JSON without HTML escaping. The bid id and version make a signature good for one version of
+// one bid only, so it cannot be replayed to submit another bid or to restore an edited one.
+type signedBidVersion struct {
+	AuthorID    string `json:"authorId"`
+	AuthorType  string `json:"authorType"`
+	BidID       string `json:"bidId"`
+	Description string `json:"description"`
+	LotID       string `json:"lotId"`
+	Name        string `json:"name"`
+	TenderID    string `json:"tenderId"`
+	Version     int    `json:"version"`
+}
+
+type bidSignatureVerification struct {
+	Valid bool `json:"valid"`
+	models.BidSignature
+	Payload string `json:"payload"`
+}
+
+// bidSignaturePayload is the payload signed for the given version of a bid.
+func bidSignaturePayload(bid models.Bid, version int) ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	err := encoder.Encode(signedBidVersion{
+		AuthorID:    bid.AuthorId,
+		AuthorType:  bid.AuthorType,
+		BidID:       bid.ID,
+		Description: bid.Description,
+		LotID:       bid.LotID,
+		Name:        bid.Name,
+		TenderID:    bid.TenderID,
+		Version:     version,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode bid version: %w", err)
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+func parsePublicKey(encoded string) (ed25519.PublicKey, error) {
+	key, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return nil, problem(codeInvalidPublicKey, signatureAlgorithm, ed25519.PublicKeySize)
+	}
+	return key, nil
+}
+
+func verifyBidSignature(bid models.Bid, signature models.BidSignature) (bool, error) {
+	key, err := parsePublicKey(signature.PublicKey)
+	if err != nil {
+		return false, err
+	}
+
+	sig, err := base64.StdEncoding.DecodeString(signature.Signature)
+	if err != nil {
+		return false, nil
+	}
+
+	payload, err := bidSignaturePayload(bid, signature.Version)
+	if err != nil {
+		return false, err
+	}
+	return ed25519.Verify(key, payload, sig), nil
+}
+
+// checkBidSignature verifies the signature of the version bid.Version of a bid by the employee
+// signerID against the key the employee registered. Once an employee has registered a key, the versions they submit
+// must be signed. It writes the error response itself and reports false on failure.
+func (h *Handler) checkBidSignature(w http.ResponseWriter, r *http.Request, bid models.Bid, signerID string, signature string) (*models.BidSignature, bool) {
+	publicKey, err := h.repository(r).SigningKey(signerID)
+	if err != nil {
+		respondError(w, fmt.Errorf("failed to get signing key: %w", err))
+		return nil, false
+	}
+
+	if signature == "" {
+		if publicKey != "" {
+			respondError(w, problem(codeSignatureRequired))
+			return nil, false
+		}
+		return nil, true
+	}
+
+	if publicKey == "" {
+		respondError(w, problem(codeSignerHasNoKey))
+		return nil, false
+	}
+
+	bidSignature := models.BidSignature{SignedBy: signerID, PublicKey: publicKey, Signature: signature, Version: bid.Version}
+	valid, err := verifyBidSignature(bid, bidSignature)
+	if err != nil {
+		respondError(w, fmt.Errorf("failed to verify signature: %w", err))
+		return nil, false
+	}
+
+	if !valid {
+		respondError(w, problem(codeInvalidSignature))
+		return nil, false
+	}
+
+	return &bidSignature, true
+}
+
+// newBidSigner resolves the employee who signs a new bid: the author of a user's bid, or the
+// employee named by signedBy, who must belong to the organization a bid is submitted for.
+// It writes the error response itself and reports false on failure.
+func (h *Handler) newBidSigner(w http.ResponseWriter, r *http.Request, req newBidRequest) (string, bool) {
+	if req.AuthorType == authorTypeUser {
+		return req.AuthorId, true
+	}
+
+	if req.SignedBy == "" {
+		if req.Signature != "" {
+			respondError(w, problem(codeSignedByRequired))
+			return "", false
+		}
+		return "", true
+	}
+
+	userId, ok := h.userByUsername(w, r, req.SignedBy)
+	if !ok {
+		return "", false
+	}
+
+	organizationId, _, err := h.repository(r).GetOrganizationIDByUsername(req.SignedBy)
+	if err != nil {
+		respondError(w, fmt.Errorf("failed to get organization by username: %w", err))
+		return "", false
+	}
+
+	if organizationId != req.AuthorId {
+		respondError(w, problem(codeNotOrganizationMember, req.SignedBy, req.AuthorId))
+		return "", false
+	}
+
+	return userId, true
+}
+
+func (h *Handler) GetSigningKey(w http.ResponseWriter, r *http.Request) {
+	userId, ok := h.userFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	publicKey, err := h.repository(r).SigningKey(userId)
+	if err != nil {
+		respondError(w, fmt.Errorf("failed to get signing key: %w", err))
+		return
+	}
+
+	if publicKey == "" {
+		respondError(w, problem(codeSigningKeyNotFound))
+		return
+	}
+
+	respondJSON(w, http.StatusOK, publicKeyInfo{Algorithm: signatureAlgorithm, PublicKey: publicKey})
+}
+
+// UpdateSigningKey registers the public key the user signs bids with. An empty key removes it.
+// Bids signed before keep the key they were signed with.
+func (h *Handler) UpdateSigningKey(w http.ResponseWriter, r *http.Request) {
+	userId, ok := h.userFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	var req signingKeyRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		respondError(w, problem(codeInvalidJSON, err))
+		return
+	}
+
+	if req.PublicKey != "" {
+		if _, err := parsePublicKey(req.PublicKey); err != nil {
+			respondError(w, err)
+			return
+		}
+	}
+
+	previous, err := h.repository(r).SigningKey(userId)
+	if err != nil {
+		respondError(w, fmt.Errorf("failed to get signing key: %w", err))
+		return
+	}
+
+	err = h.repository(r).Transaction(func(repo *connection.Repository) error {
+		err := repo.SetSigningKey(userId, req.PublicKey)
+		if err != nil {
+			return fmt.Errorf("failed to save signing key: %w", err)
+		}
+		return h.audit(r, repo, r.URL.Query().Get("username"), auditActionEdit, auditEntitySigningKey, userId,
+			publicKeyInfo{Algorithm: signatureAlgorithm, PublicKey: previous}, publicKeyInfo{Algorithm: signatureAlgorithm, PublicKey: req.PublicKey})
+	})
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, publicKeyInfo{Algorithm: signatureAlgorithm, PublicKey: req.PublicKey})
+}
+
+// VerifyBidSignature checks the signature of the current version of a bid, so the tender owner
+// can show that the author submitted exactly these terms. The response carries the signed payload.
+func (h *Handler) VerifyBidSignature(w http.ResponseWriter, r *http.Request) {
+	bid, ok := h.bidReaderFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	signature, found, err := h.repository(r).BidSignature(bid.ID)
+	if err != nil {
+		respondError(w, fmt.Errorf("failed to get bid signature: %w", err))
+		return
+	}
+
+	if !found {
+		respondError(w, problem(codeBidNotSigned))
+		return
+	}
+
+	payload, err := bidSignaturePayload(*bid, signature.Version)
+	if err != nil {
+		respondError(w, fmt.Errorf("failed to verify signature: %w", err))
+		return
+	}
+
+	valid, err := verifyBidSignature(*bid, *signature)
+	if err != nil {
+		respondError(w, fmt.Errorf("failed to verify signature: %w", err))
+		return
+	}
+
+	respondJSON(w, http.StatusOK, bidSignatureVerification{Valid: valid, BidSignature: *signature, Payload: string(payload)})
+}
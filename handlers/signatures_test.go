@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"testing"
+
+	"github.com/noctusha/tender/models"
+)
+
+func testBid() models.Bid {
+	return models.Bid{
+		ID:          "3f2b8c1e-9d4a-4c7b-8e21-6a5f0d9b7c13",
+		Name:        "Поставка труб <ДУ-50>",
+		Description: "Сталь & чугун",
+		TenderID:    "550e8400-e29b-41d4-a716-446655440000",
+		AuthorType:  authorTypeUser,
+		AuthorId:    "a1b2c3d4-e5f6-7890-abcd-ef1234567890",
+		Version:     2,
+	}
+}
+
+func TestBidSignaturePayload(t *testing.T) {
+	payload, err := bidSignaturePayload(testBid(), 2)
+	if err != nil {
+		t.Fatalf("bidSignaturePayload: %v", err)
+	}
+
+	want := `{"authorId":"a1b2c3d4-e5f6-7890-abcd-ef1234567890","authorType":"User","bidId":"3f2b8c1e-9d4a-4c7b-8e21-6a5f0d9b7c13",` +
+		`"description":"Сталь & чугун","lotId":"","name":"Поставка труб <ДУ-50>","tenderId":"550e8400-e29b-41d4-a716-446655440000","version":2}`
+	if string(payload) != want {
+		t.Errorf("payload = %s, want %s", payload, want)
+	}
+}
+
+func TestVerifyBidSignature(t *testing.T) {
+	key := ed25519.NewKeyFromSeed(make([]byte, ed25519.SeedSize))
+	publicKey := base64.StdEncoding.EncodeToString(key.Public().(ed25519.PublicKey))
+
+	signed := testBid()
+	payload, err := bidSignaturePayload(signed, signed.Version)
+	if err != nil {
+		t.Fatalf("bidSignaturePayload: %v", err)
+	}
+	signature := models.BidSignature{
+		PublicKey: publicKey,
+		Signature: base64.StdEncoding.EncodeToString(ed25519.Sign(key, payload)),
+		Version:   signed.Version,
+	}
+
+	otherKey := ed25519.NewKeyFromSeed(append(make([]byte, ed25519.SeedSize-1), 1))
+	otherPublicKey := base64.StdEncoding.EncodeToString(otherKey.Public().(ed25519.PublicKey))
+
+	tests := []struct {
+		name      string
+		bid       func(bid *models.Bid)
+		signature func(signature *models.BidSignature)
+		want      bool
+		wantErr   bool
+	}{
+		{name: "signed version", want: true},
+		{name: "other bid", bid: func(bid *models.Bid) { bid.ID = "7c9e6679-7425-40de-944b-e07fc1f90ae7" }},
+		{name: "other tender", bid: func(bid *models.Bid) { bid.TenderID = "6ba7b810-9dad-11d1-80b4-00c04fd430c8" }},
+		{name: "other version", signature: func(signature *models.BidSignature) { signature.Version = 3 }},
+		{name: "changed name", bid: func(bid *models.Bid) { bid.Name = "Поставка труб" }},
+		{name: "other author", bid: func(bid *models.Bid) { bid.AuthorId = "6ba7b810-9dad-11d1-80b4-00c04fd430c8" }},
+		{name: "lot added", bid: func(bid *models.Bid) { bid.LotID = "6ba7b810-9dad-11d1-80b4-00c04fd430c8" }},
+		{name: "other key", signature: func(signature *models.BidSignature) { signature.PublicKey = otherPublicKey }},
+		{name: "signature not base64", signature: func(signature *models.BidSignature) { signature.Signature = "not base64!" }},
+		{name: "invalid public key", signature: func(signature *models.BidSignature) { signature.PublicKey = "c2hvcnQ=" }, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bid, sig := signed, signature
+			if tt.bid != nil {
+				tt.bid(&bid)
+			}
+			if tt.signature != nil {
+				tt.signature(&sig)
+			}
+
+			valid, err := verifyBidSignature(bid, sig)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("verifyBidSignature error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if valid != tt.want {
+				t.Errorf("verifyBidSignature = %v, want %v", valid, tt.want)
+			}
+		})
+	}
+}
@@ -4,59 +4,69 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
-	"strconv"
 	"strings"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/gorilla/mux"
 
+	"github.com/noctusha/tender/connection"
 	"github.com/noctusha/tender/models"
 )
 
 func (h *Handler) ListTenders(w http.ResponseWriter, r *http.Request) {
 	var (
-		limit       int
-		offset      int
-		serviceType string
-		err         error
+		filter   connection.TenderFilter
+		username string
 	)
 	for name, vals := range r.URL.Query() {
 		switch name {
-		case "service_type":
-			serviceType = vals[0]
-		case "limit":
-			limit, err = strconv.Atoi(vals[0])
+		case "username":
+			username = vals[0]
+		default:
+			ok, err := applyTenderFilterParam(&filter, name, vals)
 			if err != nil {
-				respondJSONError(w, http.StatusBadRequest, fmt.Sprintf("invalid limit format: %v", err))
+				respondError(w, err)
 				return
 			}
-		case "offset":
-			offset, err = strconv.Atoi(vals[0])
-			if err != nil {
-				respondJSONError(w, http.StatusBadRequest, fmt.Sprintf("invalid offset format: %v", err))
+
+			if !ok {
+				respondError(w, problem(codeUnknownParameter, name))
 				return
 			}
-		default:
-			respondJSONError(w, http.StatusBadRequest, fmt.Sprintf("unknown parameter: %s", name))
-			return
 		}
 	}
 
-	switch serviceType {
-	case "", serviceTypeConstruction, serviceTypeDelivery, serviceTypeManufacture:
-		break
-	default:
-		respondJSONError(w, http.StatusBadRequest, fmt.Sprintf("unknown service type: %s", serviceType))
+	if !h.checkServiceTypes(w, r, filter.ServiceTypes...) {
 		return
 	}
 
-	tenders, err := h.repo.TendersList(serviceType, limit, offset)
+	var organizationId string
+	if username != "" {
+		var (
+			userFound bool
+			err       error
+		)
+		organizationId, userFound, err = h.repository(r).GetOrganizationIDByUsername(username)
+		if err != nil {
+			respondError(w, fmt.Errorf("failed to get organization by username: %w", err))
+			return
+		}
+
+		if !userFound {
+			respondError(w, problem(codeUserNotFound, username))
+			return
+		}
+	}
+
+	tenders, page, err := h.repository(r).TendersList(organizationId, filter)
 	if err != nil {
-		respondJSONError(w, http.StatusBadRequest, fmt.Sprintf("failed to select tender from database: %v", err))
+		respondError(w, fmt.Errorf("failed to select tender from database: %w", err))
 		return
 	}
 
-	respondJSON(w, http.StatusOK, JSON{Tenders: &tenders})
+	setPageLink(w, r, page)
+	respondJSON(w, http.StatusOK, JSON{Tenders: &tenders, NextCursor: page.NextCursor, Total: page.Total})
 }
 
 func (h *Handler) NewTender(w http.ResponseWriter, r *http.Request) {
@@ -64,43 +74,69 @@ func (h *Handler) NewTender(w http.ResponseWriter, r *http.Request) {
 
 	err := json.NewDecoder(r.Body).Decode(&tender)
 	if err != nil {
-		respondJSONError(w, http.StatusBadRequest, fmt.Sprintf("failed to parse JSON format: %v", err))
+		respondError(w, problem(codeInvalidJSON, err))
 		return
 	}
 
-	if tender.Name == "" {
-		respondJSONError(w, http.StatusBadRequest, "missing tender title")
+	tender.ID = uuid.New().String()
+
+	tender.Status = statusCreated
+
+	if tender.Visibility == "" {
+		tender.Visibility = visibilityPublic
+	}
+
+	if !validateModel(w, &tender) {
 		return
 	}
 
-	tender.ID = uuid.New().String()
+	if tender.Deadline != nil {
+		if !tender.Deadline.After(time.Now()) {
+			respondError(w, problem(codeInvalidDeadline))
+			return
+		}
+		deadline := tender.Deadline.UTC()
+		tender.Deadline = &deadline
+	}
 
-	tender.Status = statusCreated
+	serviceTypes := []string{tender.ServiceType}
+	for i := range tender.Lots {
+		tender.Lots[i].ID = uuid.New().String()
+		tender.Lots[i].TenderID = tender.ID
+		tender.Lots[i].Status = lotStatusOpen
+		tender.Lots[i].WinnerBidID = ""
+		serviceTypes = append(serviceTypes, tender.Lots[i].ServiceType)
+	}
 
-	if tender.CreatorUserName == "" {
-		respondJSONError(w, http.StatusBadRequest, "missing tender creatorUsername")
+	if !h.checkServiceTypes(w, r, serviceTypes...) {
 		return
 	}
 
-	organizationId, ok, err := h.repo.GetOrganizationIDByUsername(tender.CreatorUserName)
+	organizationId, ok, err := h.repository(r).GetOrganizationIDByUsername(tender.CreatorUserName)
 	if err != nil {
-		respondJSONError(w, http.StatusInternalServerError, fmt.Sprintf("failed to get organization: %v", err))
+		respondError(w, fmt.Errorf("failed to get organization: %w", err))
 		return
 	}
 
 	if !ok {
-		respondJSONError(w, http.StatusNotFound, "organization not found")
+		respondError(w, problem(codeOrganizationNotFound, tender.OrganizationID))
 		return
 	}
 
 	if tender.OrganizationID != organizationId {
-		respondJSONError(w, http.StatusForbidden, fmt.Sprintf("user %s does not belong to organization %s", tender.CreatorUserName, tender.OrganizationID))
+		respondError(w, problem(codeNotOrganizationMember, tender.CreatorUserName, tender.OrganizationID))
 		return
 	}
 
-	err = h.repo.NewTender(tender)
+	err = h.repository(r).Transaction(func(repo *connection.Repository) error {
+		err := repo.NewTender(tender)
+		if err != nil {
+			return fmt.Errorf("failed to save tender: %w", err)
+		}
+		return h.audit(r, repo, tender.CreatorUserName, auditActionCreate, auditEntityTender, tender.ID, nil, tender, tender.OrganizationID)
+	})
 	if err != nil {
-		respondJSONError(w, http.StatusInternalServerError, fmt.Sprintf("failed to save tender: %v", err))
+		respondError(w, err)
 		return
 	}
 
@@ -109,8 +145,7 @@ func (h *Handler) NewTender(w http.ResponseWriter, r *http.Request) {
 
 func (h *Handler) MyTenders(w http.ResponseWriter, r *http.Request) {
 	var (
-		limit    int
-		offset   int
+		filter   connection.TenderFilter
 		username string
 		err      error
 	)
@@ -118,31 +153,38 @@ func (h *Handler) MyTenders(w http.ResponseWriter, r *http.Request) {
 		switch name {
 		case "username":
 			username = vals[0]
-		case "limit":
-			limit, err = strconv.Atoi(vals[0])
+		case "status":
+			filter.Statuses, err = parseTenderStatuses(vals)
 			if err != nil {
-				respondJSONError(w, http.StatusBadRequest, fmt.Sprintf("invalid limit format: %v", err))
+				respondError(w, err)
 				return
 			}
-		case "offset":
-			offset, err = strconv.Atoi(vals[0])
+		default:
+			ok, err := applyTenderFilterParam(&filter, name, vals)
 			if err != nil {
-				respondJSONError(w, http.StatusBadRequest, fmt.Sprintf("invalid offset format: %v", err))
+				respondError(w, err)
+				return
+			}
+
+			if !ok {
+				respondError(w, problem(codeUnknownParameter, name))
 				return
 			}
-		default:
-			respondJSONError(w, http.StatusBadRequest, fmt.Sprintf("unknown parameter: %s", name))
-			return
 		}
 	}
 
-	tenders, err := h.repo.MyTendersList(username, limit, offset)
+	if !h.checkServiceTypes(w, r, filter.ServiceTypes...) {
+		return
+	}
+
+	tenders, page, err := h.repository(r).MyTendersList(username, filter)
 	if err != nil {
-		respondJSONError(w, http.StatusBadRequest, "failed to select tender from database")
+		respondError(w, fmt.Errorf("failed to select tender from database: %w", err))
 		return
 	}
 
-	respondJSON(w, http.StatusOK, JSON{Tenders: &tenders})
+	setPageLink(w, r, page)
+	respondJSON(w, http.StatusOK, JSON{Tenders: &tenders, NextCursor: page.NextCursor, Total: page.Total})
 }
 
 func (h *Handler) GetTenderStatus(w http.ResponseWriter, r *http.Request) {
@@ -150,18 +192,18 @@ func (h *Handler) GetTenderStatus(w http.ResponseWriter, r *http.Request) {
 
 	tenderID, err := uuid.Parse(vars["tenderId"])
 	if err != nil {
-		respondJSONError(w, http.StatusBadRequest, "invalid tenderID format")
+		respondError(w, problem(codeInvalidParameter, "tenderId"))
 		return
 	}
 
-	tender, ok, err := h.repo.GetTenderByID(tenderID)
+	tender, ok, err := h.repository(r).GetTenderByID(tenderID)
 	if err != nil {
-		respondJSONError(w, http.StatusInternalServerError, fmt.Sprintf("failed to get tender: %v", err))
+		respondError(w, fmt.Errorf("failed to get tender: %w", err))
 		return
 	}
 
 	if !ok {
-		respondJSONError(w, http.StatusNotFound, fmt.Sprintf("tender not found"))
+		respondError(w, problem(codeTenderNotFound))
 		return
 	}
 
@@ -173,7 +215,7 @@ func (h *Handler) EditTender(w http.ResponseWriter, r *http.Request) {
 
 	tenderID, err := uuid.Parse(vars["tenderId"])
 	if err != nil {
-		respondJSONError(w, http.StatusBadRequest, "invalid tenderID format")
+		respondError(w, problem(codeInvalidParameter, "tenderId"))
 		return
 	}
 
@@ -183,93 +225,112 @@ func (h *Handler) EditTender(w http.ResponseWriter, r *http.Request) {
 		case "username":
 			username = vals[0]
 		default:
-			respondJSONError(w, http.StatusBadRequest, fmt.Sprintf("unknown parameter: %s", name))
+			respondError(w, problem(codeUnknownParameter, name))
 			return
 		}
 	}
 
 	if username == "" {
-		respondJSONError(w, http.StatusBadRequest, "missing username")
+		respondError(w, problem(codeMissingUsername))
 		return
 	}
 
-	tender, ok, err := h.repo.GetTenderByID(tenderID)
+	tender, ok, err := h.repository(r).GetTenderByID(tenderID)
 	if err != nil {
-		respondJSONError(w, http.StatusInternalServerError, fmt.Sprintf("failed to get tender: %v", err))
+		respondError(w, fmt.Errorf("failed to get tender: %w", err))
 		return
 	}
 
 	if !ok {
-		respondJSONError(w, http.StatusNotFound, fmt.Sprintf("tender not found"))
+		respondError(w, problem(codeTenderNotFound))
 		return
 	}
 
-	organizationId, userFound, err := h.repo.GetOrganizationIDByUsername(username)
+	organizationId, userFound, err := h.repository(r).GetOrganizationIDByUsername(username)
 	if err != nil {
-		respondJSONError(w, http.StatusInternalServerError, fmt.Sprintf("failed to get organization by username: %v", err))
+		respondError(w, fmt.Errorf("failed to get organization by username: %w", err))
 		return
 	}
 
 	if !userFound {
-		respondJSONError(w, http.StatusUnauthorized, fmt.Sprintf("user not found: %s", username))
+		respondError(w, problem(codeUserNotFound, username))
 		return
 	}
 
 	if tender.OrganizationID != organizationId {
-		respondJSONError(w, http.StatusForbidden, fmt.Sprintf("user %s does not have permissions to this tender", username))
+		respondError(w, problem(codeTenderForbidden, username))
 		return
 	}
 
-	var updatedTender models.Tender
+	var updatedTender struct {
+		models.Tender
+		Reason string `json:"reason"`
+	}
 	err = json.NewDecoder(r.Body).Decode(&updatedTender)
 	if err != nil {
-		respondJSONError(w, http.StatusBadRequest, "invalid JSON format")
+		respondError(w, problem(codeInvalidJSON, err))
 		return
 	}
 
-	err = h.repo.AddTenderVersion(&models.TenderVersion{
-		TenderID:    tender.ID,
-		Name:        tender.Name,
-		Description: tender.Description,
-	})
-	if err != nil {
-		respondJSONError(w, http.StatusInternalServerError, fmt.Sprintf("failed to add tender version: %v", err))
+	if !validateModelUpdate(w, &updatedTender) {
+		return
+	}
+
+	if updatedTender.Deadline != nil && !updatedTender.Deadline.After(time.Now()) {
+		respondError(w, problem(codeInvalidDeadline))
 		return
 	}
 
+	previous := *tender
+
 	if updatedTender.Name != "" {
 		tender.Name = updatedTender.Name
 	}
 	if updatedTender.Description != "" {
 		tender.Description = updatedTender.Description
 	}
+	if updatedTender.Deadline != nil {
+		deadline := updatedTender.Deadline.UTC()
+		tender.Deadline = &deadline
+	}
 
-	err = h.repo.UpdateTender(tender)
-	if err != nil {
-		respondJSONError(w, http.StatusInternalServerError, fmt.Sprintf("failed to update tender: %v", err))
+	if tender.Status == statusPublished {
+		h.amendTender(w, r, auditActionEdit, previous, tender, username, updatedTender.Reason, nil)
 		return
 	}
 
-	respondJSON(w, http.StatusOK, tender)
-}
-
-func (h *Handler) validateSetTenderStatus(status string, username string) error {
-	if status == "" {
-		return fmt.Errorf("status is mandatory")
-	}
+	err = h.repository(r).Transaction(func(repo *connection.Repository) error {
+		err := repo.AddTenderVersion(&models.TenderVersion{
+			TenderID:    previous.ID,
+			Name:        previous.Name,
+			Description: previous.Description,
+			Deadline:    previous.Deadline,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to add tender version: %w", err)
+		}
 
-	if username == "" {
-		return fmt.Errorf("username is mandatory")
+		err = repo.UpdateTender(tender, tenderUpdatedEvent(tender))
+		if err != nil {
+			return fmt.Errorf("failed to update tender: %w", err)
+		}
+		return h.audit(r, repo, username, auditActionEdit, auditEntityTender, tender.ID, previous, tender, tender.OrganizationID)
+	})
+	if err != nil {
+		respondError(w, err)
+		return
 	}
+	h.outbox.Wake()
 
-	switch status {
-	case statusCreated, statusPublished, statusCancelled, statusClosed:
-		break
-	default:
-		return fmt.Errorf("invalid status: %s", status)
-	}
+	respondJSON(w, http.StatusOK, tender)
+}
 
-	return nil
+// tenderUpdatedEvent reports a new version of a tender that is not published. While the tender
+// is still being prepared, its stream shows the change to the owner only.
+func tenderUpdatedEvent(tender *models.Tender) models.Event {
+	event := connection.NewTenderEvent(connection.EventTenderUpdated, tender.ID, tender, tender.OrganizationID)
+	event.Restricted = tender.Status == statusCreated
+	return event
 }
 
 func (h *Handler) SetTenderStatus(w http.ResponseWriter, r *http.Request) {
@@ -286,56 +347,69 @@ func (h *Handler) SetTenderStatus(w http.ResponseWriter, r *http.Request) {
 		case "username":
 			username = vals[0]
 		default:
-			respondJSONError(w, http.StatusBadRequest, fmt.Sprintf("unknown parameter: %s", name))
+			respondError(w, problem(codeUnknownParameter, name))
 			return
 		}
 	}
 
-	if err := h.validateSetTenderStatus(status, username); err != nil {
-		respondJSONError(w, http.StatusBadRequest, fmt.Sprintf("validation error: %v", err))
-		return
-	}
-
 	tenderID, err := uuid.Parse(vars["tenderId"])
 	if err != nil {
-		respondJSONError(w, http.StatusBadRequest, "invalid tenderID format")
+		respondError(w, problem(codeInvalidParameter, "tenderId"))
 		return
 	}
 
-	tender, ok, err := h.repo.GetTenderByID(tenderID)
+	tender, ok, err := h.repository(r).GetTenderByID(tenderID)
 	if err != nil {
-		respondJSONError(w, http.StatusInternalServerError, fmt.Sprintf("failed to get tender: %v", err))
+		respondError(w, fmt.Errorf("failed to get tender: %w", err))
 		return
 	}
 
 	if !ok {
-		respondJSONError(w, http.StatusNotFound, fmt.Sprintf("tender not found"))
+		respondError(w, problem(codeTenderNotFound))
 		return
 	}
 
+	previous := *tender
 	tender.Status = status
 
-	organizationId, userFound, err := h.repo.GetOrganizationIDByUsername(username)
+	organizationId, userFound, err := h.repository(r).GetOrganizationIDByUsername(username)
 	if err != nil {
-		respondJSONError(w, http.StatusInternalServerError, fmt.Sprintf("failed to get organization by username: %v", err))
+		respondError(w, fmt.Errorf("failed to get organization by username: %w", err))
 		return
 	}
 
 	if !userFound {
-		respondJSONError(w, http.StatusUnauthorized, fmt.Sprintf("user not found: %s", username))
+		respondError(w, problem(codeUserNotFound, username))
 		return
 	}
 
 	if tender.OrganizationID != organizationId {
-		respondJSONError(w, http.StatusForbidden, fmt.Sprintf("user %s does not have permissions to this tender", username))
+		respondError(w, problem(codeTenderForbidden, username))
 		return
 	}
 
-	err = h.repo.UpdateTenderStatus(tender.ID, status)
+	var events []models.Event
+	switch status {
+	case statusPublished:
+		events = append(events, connection.NewTenderEvent(connection.EventTenderPublished, tender.ID, tender, tender.OrganizationID))
+	case statusClosed:
+		events = append(events, connection.NewTenderEvent(connection.EventTenderClosed, tender.ID, tender, tender.OrganizationID))
+	case statusCancelled:
+		events = append(events, connection.NewTenderEvent(connection.EventTenderCancelled, tender.ID, tender, tender.OrganizationID))
+	}
+
+	err = h.repository(r).Transaction(func(repo *connection.Repository) error {
+		err := repo.UpdateTenderStatus(tender.ID, status, events...)
+		if err != nil {
+			return fmt.Errorf("failed to update tender status: %w", err)
+		}
+		return h.audit(r, repo, username, auditActionStatus, auditEntityTender, tender.ID, previous, tender, tender.OrganizationID)
+	})
 	if err != nil {
-		respondJSONError(w, http.StatusInternalServerError, fmt.Sprintf("failed to update tender status: %v", err))
+		respondError(w, err)
 		return
 	}
+	h.outbox.Wake()
 
 	respondJSON(w, http.StatusOK, tender)
 }
@@ -345,7 +419,7 @@ func (h *Handler) RollbackTender(w http.ResponseWriter, r *http.Request) {
 
 	tenderID, err := uuid.Parse(vars["tenderId"])
 	if err != nil {
-		respondJSONError(w, http.StatusBadRequest, "invalid tenderID format")
+		respondError(w, problem(codeInvalidParameter, "tenderId"))
 		return
 	}
 
@@ -355,67 +429,113 @@ func (h *Handler) RollbackTender(w http.ResponseWriter, r *http.Request) {
 		case "username":
 			username = vals[0]
 		default:
-			respondJSONError(w, http.StatusBadRequest, fmt.Sprintf("unknown parameter: %s", name))
+			respondError(w, problem(codeUnknownParameter, name))
 			return
 		}
 	}
 
 	if username == "" {
-		respondJSONError(w, http.StatusBadRequest, "missing username")
+		respondError(w, problem(codeMissingUsername))
 		return
 	}
 
-	tender, ok, err := h.repo.GetTenderByID(tenderID)
+	tender, ok, err := h.repository(r).GetTenderByID(tenderID)
 	if err != nil {
-		respondJSONError(w, http.StatusInternalServerError, fmt.Sprintf("failed to get tender: %v", err))
+		respondError(w, fmt.Errorf("failed to get tender: %w", err))
 		return
 	}
 
 	if !ok {
-		respondJSONError(w, http.StatusNotFound, fmt.Sprintf("tender not found"))
+		respondError(w, problem(codeTenderNotFound))
 		return
 	}
 
-	organizationId, userFound, err := h.repo.GetOrganizationIDByUsername(username)
+	organizationId, userFound, err := h.repository(r).GetOrganizationIDByUsername(username)
 	if err != nil {
-		respondJSONError(w, http.StatusInternalServerError, fmt.Sprintf("failed to get organization by username: %v", err))
+		respondError(w, fmt.Errorf("failed to get organization by username: %w", err))
 		return
 	}
 
 	if !userFound {
-		respondJSONError(w, http.StatusUnauthorized, fmt.Sprintf("user not found: %s", username))
+		respondError(w, problem(codeUserNotFound, username))
 		return
 	}
 
 	if tender.OrganizationID != organizationId {
-		respondJSONError(w, http.StatusForbidden, fmt.Sprintf("user %s does not have permissions to this tender", username))
+		respondError(w, problem(codeTenderForbidden, username))
 		return
 	}
 
 	version, err := uuid.Parse(vars["version"])
 	if err != nil {
-		respondJSONError(w, http.StatusBadRequest, "invalid version format")
+		respondError(w, problem(codeInvalidParameter, "version"))
 		return
 	}
 
-	tenderVer, err := h.repo.GetTenderVersionByID(version)
+	tenderVer, err := h.repository(r).GetTenderVersionByID(version)
 	if err != nil {
-		respondJSONError(w, http.StatusNotFound, fmt.Sprintf("failed to get tender version: %v", err))
+		respondError(w, err)
 		return
 	}
 
 	if tenderVer.TenderID != tender.ID {
-		respondJSONError(w, http.StatusForbidden, "tender version does not belong to tender")
+		respondError(w, problem(codeTenderVersionMismatch))
 		return
 	}
 
+	previous := *tender
+
 	tender.Name = tenderVer.Name
 	tender.Description = tenderVer.Description
+	tender.Deadline = tenderVer.Deadline
+
+	attachments, err := h.repository(r).AttachmentsByEntity(attachmentEntityTender, tender.ID)
+	if err != nil {
+		respondError(w, fmt.Errorf("failed to select attachment from database: %w", err))
+		return
+	}
+
+	// The attachments are restored after the version replaced by the rollback has been kept, so
+	// that version records the attachments it had.
+	var (
+		alsoChanged []string
+		restore     func(repo *connection.Repository) error
+	)
+	if !sameAttachmentSet(attachments, tenderVer.AttachmentIDs) {
+		restore = func(repo *connection.Repository) error {
+			err := repo.RestoreAttachments(attachmentEntityTender, tender.ID, tenderVer.AttachmentIDs)
+			if err != nil {
+				return fmt.Errorf("failed to restore attachments: %w", err)
+			}
+			return nil
+		}
+		alsoChanged = append(alsoChanged, "attachments")
+	}
+
+	if tender.Status == statusPublished {
+		h.amendTender(w, r, auditActionRollback, previous, tender, username, fmt.Sprintf("rollback to version %s", tenderVer.ID), restore, alsoChanged...)
+		return
+	}
+
+	err = h.repository(r).Transaction(func(repo *connection.Repository) error {
+		err := repo.UpdateTender(tender, tenderUpdatedEvent(tender))
+		if err != nil {
+			return fmt.Errorf("failed to update tender: %w", err)
+		}
 
-	if err := h.repo.UpdateTender(tender); err != nil {
-		respondJSONError(w, http.StatusInternalServerError, fmt.Sprintf("failed to update tender: %v", err))
+		if restore != nil {
+			err = restore(repo)
+			if err != nil {
+				return err
+			}
+		}
+		return h.audit(r, repo, username, auditActionRollback, auditEntityTender, tender.ID, previous, tender, tender.OrganizationID)
+	})
+	if err != nil {
+		respondError(w, err)
 		return
 	}
+	h.outbox.Wake()
 
 	respondJSON(w, http.StatusOK, tender)
 }
@@ -0,0 +1,136 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/noctusha/tender/models"
+)
+
+// violation is a parameter or a body field that broke a rule of the OpenAPI document or of its
+// model. Its message is written in the language of the response; rules without a message keep
+// the English reason of the validator.
+type violation struct {
+	field  string
+	rule   string
+	limit  string
+	reason string
+}
+
+// violationMessages holds the messages of the rules, keyed by language and then by rule; limit
+// fills them in.
+var violationMessages = map[string]map[string]string{
+	languageEnglish: {
+		"required":  "value is required but missing",
+		"enum":      "value is not one of the allowed values %s",
+		"pattern":   "value does not match the pattern %s",
+		"format":    "value is not a valid %s",
+		"type":      "value must be of type %s",
+		"minLength": "minimum string length is %s",
+		"maxLength": "maximum string length is %s",
+		"minimum":   "number must be at least %s",
+		"maximum":   "number must be at most %s",
+		"minItems":  "minimum number of items is %s",
+		"maxItems":  "maximum number of items is %s",
+	},
+	languageRussian: {
+		"required":  "обязательное значение не указано",
+		"enum":      "значение не входит в список допустимых %s",
+		"pattern":   "значение не соответствует шаблону %s",
+		"format":    "значение не является корректным %s",
+		"type":      "значение должно иметь тип %s",
+		"minLength": "минимальная длина строки %s",
+		"maxLength": "максимальная длина строки %s",
+		"minimum":   "число должно быть не меньше %s",
+		"maximum":   "число должно быть не больше %s",
+		"minItems":  "минимальное число элементов %s",
+		"maxItems":  "максимальное число элементов %s",
+	},
+}
+
+// message is the text of the violation without its field.
+func (v violation) message(language string) string {
+	format, ok := violationMessages[language][v.rule]
+	if !ok {
+		return v.reason
+	}
+	if strings.Contains(format, "%s") {
+		return fmt.Sprintf(format, v.limit)
+	}
+	return format
+}
+
+func (v violation) localize(language string) string {
+	if v.field == "" {
+		return v.message(language)
+	}
+	return fmt.Sprintf("%s: %s", v.field, v.message(language))
+}
+
+// violations are all the rules a request broke. They are reported together: the detail of the
+// problem lists them, and its errors member keys them by field.
+type violations []violation
+
+func (vs violations) localize(language string) string {
+	messages := make([]string, len(vs))
+	for i, v := range vs {
+		messages[i] = v.localize(language)
+	}
+	return strings.Join(messages, "; ")
+}
+
+func validationProblem(vs []violation) error {
+	return problem(codeValidation, violations(vs))
+}
+
+// fieldError is a member of the errors list of a validation problem.
+type fieldError struct {
+	Field   string `json:"field,omitempty"`
+	Rule    string `json:"rule,omitempty"`
+	Message string `json:"message"`
+}
+
+// fieldErrors lists the violations of a validation problem, nil for any other error.
+func fieldErrors(err error, language string) []fieldError {
+	var apiErr *apiError
+	if !errors.As(err, &apiErr) || apiErr.code != codeValidation || len(apiErr.args) != 1 {
+		return nil
+	}
+
+	vs, ok := apiErr.args[0].(violations)
+	if !ok {
+		return nil
+	}
+
+	list := make([]fieldError, len(vs))
+	for i, v := range vs {
+		list[i] = fieldError{Field: v.field, Rule: v.rule, Message: v.message(language)}
+	}
+	return list
+}
+
+// validateModel checks a model against the rules of its validate tags. Like the other checks
+// it writes the error response itself and reports false.
+func validateModel(w http.ResponseWriter, model interface{}) bool {
+	return respondViolations(w, models.Validate(model))
+}
+
+// validateModelUpdate checks an update of a model, where empty fields keep their value.
+func validateModelUpdate(w http.ResponseWriter, model interface{}) bool {
+	return respondViolations(w, models.ValidateUpdate(model))
+}
+
+func respondViolations(w http.ResponseWriter, modelViolations []models.Violation) bool {
+	if len(modelViolations) == 0 {
+		return true
+	}
+
+	vs := make([]violation, len(modelViolations))
+	for i, v := range modelViolations {
+		vs[i] = violation{field: v.Field, rule: v.Rule, limit: v.Limit}
+	}
+	respondError(w, validationProblem(vs))
+	return false
+}
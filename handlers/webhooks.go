@@ -0,0 +1,379 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+
+	"github.com/noctusha/tender/connection"
+	"github.com/noctusha/tender/models"
+	"github.com/noctusha/tender/webhooks"
+)
+
+const (
+	webhookDeliveryPending   = "PENDING"
+	webhookDeliveryDelivered = "DELIVERED"
+	webhookDeliveryFailed    = "FAILED"
+)
+
+// bidOrganizationIDs returns the organizations a bid event concerns: the tender owner and the bidder.
+func (h *Handler) bidOrganizationIDs(r *http.Request, bid *models.Bid, tender *models.Tender) []string {
+	organizationIDs := []string{tender.OrganizationID}
+
+	switch bid.AuthorType {
+	case authorTypeOrganization:
+		organizationIDs = append(organizationIDs, bid.AuthorId)
+	case authorTypeUser:
+		organizationId, ok, err := h.repository(r).GetOrganizationIDByUserID(bid.AuthorId)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "failed to get organization of bid author", "bid_id", bid.ID, "error", err)
+		}
+		if ok {
+			organizationIDs = append(organizationIDs, organizationId)
+		}
+	}
+
+	return organizationIDs
+}
+
+type webhookRequest struct {
+	URL        *string  `json:"url"`
+	EventTypes []string `json:"eventTypes"`
+	Active     *bool    `json:"active"`
+}
+
+func validateWebhookURL(r *http.Request, rawURL string) error {
+	err := webhooks.CheckURL(r.Context(), rawURL)
+	switch {
+	case errors.Is(err, webhooks.ErrPrivateAddress):
+		return problem(codeWebhookURLNotPublic)
+	case err != nil:
+		return problem(codeInvalidWebhookURL)
+	}
+	return nil
+}
+
+func (h *Handler) ListWebhooks(w http.ResponseWriter, r *http.Request) {
+	organizationId, _, ok := h.organizationFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	subscriptions, err := h.repository(r).WebhookSubscriptionsByOrganizationID(organizationId)
+	if err != nil {
+		respondError(w, fmt.Errorf("failed to select webhook subscription from database: %w", err))
+		return
+	}
+
+	respondJSON(w, http.StatusOK, JSON{Webhooks: &subscriptions})
+}
+
+func (h *Handler) NewWebhook(w http.ResponseWriter, r *http.Request) {
+	organizationId, username, ok := h.organizationFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	var req webhookRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		respondError(w, problem(codeInvalidJSON, err))
+		return
+	}
+
+	if err := validateWebhookURL(r, *req.URL); err != nil {
+		respondError(w, err)
+		return
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		respondError(w, fmt.Errorf("failed to generate webhook secret: %w", err))
+		return
+	}
+
+	subscription := models.WebhookSubscription{
+		ID:             uuid.New().String(),
+		OrganizationID: organizationId,
+		URL:            *req.URL,
+		EventTypes:     req.EventTypes,
+		Secret:         hex.EncodeToString(secret),
+		Active:         req.Active == nil || *req.Active,
+		CreatedBy:      username,
+	}
+
+	err = h.repository(r).Transaction(func(repo *connection.Repository) error {
+		err := repo.NewWebhookSubscription(&subscription)
+		if err != nil {
+			return fmt.Errorf("failed to save webhook subscription: %w", err)
+		}
+		return h.audit(r, repo, username, auditActionCreate, auditEntityWebhook, subscription.ID, nil, auditedWebhook(subscription), organizationId)
+	})
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, subscription)
+}
+
+func (h *Handler) EditWebhook(w http.ResponseWriter, r *http.Request) {
+	subscription, ok := h.webhookFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	var req webhookRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		respondError(w, problem(codeInvalidJSON, err))
+		return
+	}
+
+	previous := *subscription
+
+	if req.URL != nil {
+		if err := validateWebhookURL(r, *req.URL); err != nil {
+			respondError(w, err)
+			return
+		}
+		subscription.URL = *req.URL
+	}
+	if req.EventTypes != nil {
+		subscription.EventTypes = req.EventTypes
+	}
+	if req.Active != nil {
+		subscription.Active = *req.Active
+	}
+
+	err = h.repository(r).Transaction(func(repo *connection.Repository) error {
+		err := repo.UpdateWebhookSubscription(subscription)
+		if err != nil {
+			return fmt.Errorf("failed to update webhook subscription: %w", err)
+		}
+		return h.audit(r, repo, r.URL.Query().Get("username"), auditActionEdit, auditEntityWebhook, subscription.ID,
+			auditedWebhook(previous), auditedWebhook(*subscription), subscription.OrganizationID)
+	})
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, subscription)
+}
+
+func (h *Handler) DeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	subscription, ok := h.webhookFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	err := h.repository(r).Transaction(func(repo *connection.Repository) error {
+		err := repo.DeleteWebhookSubscription(subscription.ID)
+		if err != nil {
+			return fmt.Errorf("failed to delete webhook subscription: %w", err)
+		}
+		return h.audit(r, repo, r.URL.Query().Get("username"), auditActionDelete, auditEntityWebhook, subscription.ID,
+			auditedWebhook(*subscription), nil, subscription.OrganizationID)
+	})
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, subscription)
+}
+
+// ListWebhookDeliveries is the delivery log of a subscription, newest first.
+func (h *Handler) ListWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	var (
+		status   string
+		username string
+		page     connection.Page
+	)
+	for name, vals := range r.URL.Query() {
+		switch name {
+		case "username":
+			username = vals[0]
+		case "status":
+			status = strings.ToUpper(vals[0])
+			switch status {
+			case webhookDeliveryPending, webhookDeliveryDelivered, webhookDeliveryFailed:
+				break
+			default:
+				respondError(w, problem(codeInvalidStatus, vals[0]))
+				return
+			}
+		default:
+			ok, err := applyPageParam(&page, name, vals)
+			if err != nil {
+				respondError(w, err)
+				return
+			}
+
+			if !ok {
+				respondError(w, problem(codeUnknownParameter, name))
+				return
+			}
+		}
+	}
+
+	organizationId, ok := h.organizationByUsername(w, r, username)
+	if !ok {
+		return
+	}
+
+	subscription, ok := h.organizationWebhook(w, r, organizationId)
+	if !ok {
+		return
+	}
+
+	deliveries, info, err := h.repository(r).WebhookDeliveries(subscription.ID, status, page)
+	if err != nil {
+		respondError(w, fmt.Errorf("failed to select webhook delivery from database: %w", err))
+		return
+	}
+
+	setPageLink(w, r, info)
+	respondJSON(w, http.StatusOK, JSON{WebhookDeliveries: &deliveries, NextCursor: info.NextCursor, Total: info.Total})
+}
+
+// ReplayWebhookDelivery queues a failed delivery again with the same payload and event id,
+// so subscribers can recognise a replay of an event they may have partly processed.
+func (h *Handler) ReplayWebhookDelivery(w http.ResponseWriter, r *http.Request) {
+	deliveryID, err := uuid.Parse(mux.Vars(r)["deliveryId"])
+	if err != nil {
+		respondError(w, problem(codeInvalidParameter, "deliveryId"))
+		return
+	}
+
+	subscription, ok := h.webhookFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	delivery, found, err := h.repository(r).GetWebhookDeliveryByID(deliveryID)
+	if err != nil {
+		respondError(w, fmt.Errorf("failed to get webhook delivery: %w", err))
+		return
+	}
+
+	if !found || delivery.SubscriptionID != subscription.ID {
+		respondError(w, problem(codeWebhookDeliveryNotFound))
+		return
+	}
+
+	previous := *delivery
+	err = h.repository(r).Transaction(func(repo *connection.Repository) error {
+		replayed, err := repo.ReplayWebhookDelivery(delivery.ID)
+		if err != nil {
+			return fmt.Errorf("failed to replay webhook delivery: %w", err)
+		}
+
+		if !replayed {
+			return problem(codeDeliveryNotFailed, delivery.Status)
+		}
+
+		delivery.Status = webhookDeliveryPending
+		delivery.Attempts = 0
+		return h.audit(r, repo, r.URL.Query().Get("username"), auditActionReplay, auditEntityWebhook, subscription.ID, previous, delivery, subscription.OrganizationID)
+	})
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+	h.webhooks.Wake()
+
+	respondJSON(w, http.StatusOK, delivery)
+}
+
+// organizationFromRequest resolves the organization whose webhooks the user named by the username
+// parameter, the only parameter accepted, manages. It writes the error response itself and reports
+// false on failure.
+func (h *Handler) organizationFromRequest(w http.ResponseWriter, r *http.Request) (string, string, bool) {
+	var username string
+	for name, vals := range r.URL.Query() {
+		switch name {
+		case "username":
+			username = vals[0]
+		default:
+			respondError(w, problem(codeUnknownParameter, name))
+			return "", "", false
+		}
+	}
+
+	organizationId, ok := h.organizationByUsername(w, r, username)
+	return organizationId, username, ok
+}
+
+// organizationByUsername resolves the organization the user administers. Webhooks receive every
+// event of the organization, signed as the service, so only its administrators manage them.
+func (h *Handler) organizationByUsername(w http.ResponseWriter, r *http.Request, username string) (string, bool) {
+	if username == "" {
+		respondError(w, problem(codeMissingUsername))
+		return "", false
+	}
+
+	_, userFound, err := h.repository(r).GetOrganizationIDByUsername(username)
+	if err != nil {
+		respondError(w, fmt.Errorf("failed to get organization by username: %w", err))
+		return "", false
+	}
+
+	if !userFound {
+		respondError(w, problem(codeUserNotFound, username))
+		return "", false
+	}
+
+	organizationId, organizationAdmin, err := h.repository(r).OrganizationAdminOf(username)
+	if err != nil {
+		respondError(w, fmt.Errorf("failed to check admin rights: %w", err))
+		return "", false
+	}
+
+	if !organizationAdmin {
+		respondError(w, problem(codeNotAdministrator, username))
+		return "", false
+	}
+
+	return organizationId, true
+}
+
+func (h *Handler) webhookFromRequest(w http.ResponseWriter, r *http.Request) (*models.WebhookSubscription, bool) {
+	organizationId, _, ok := h.organizationFromRequest(w, r)
+	if !ok {
+		return nil, false
+	}
+
+	return h.organizationWebhook(w, r, organizationId)
+}
+
+// organizationWebhook loads the subscription addressed by the route if it belongs to the organization.
+func (h *Handler) organizationWebhook(w http.ResponseWriter, r *http.Request, organizationId string) (*models.WebhookSubscription, bool) {
+	subscriptionID, err := uuid.Parse(mux.Vars(r)["webhookId"])
+	if err != nil {
+		respondError(w, problem(codeInvalidParameter, "webhookId"))
+		return nil, false
+	}
+
+	subscription, found, err := h.repository(r).GetWebhookSubscriptionByID(subscriptionID)
+	if err != nil {
+		respondError(w, fmt.Errorf("failed to get webhook subscription: %w", err))
+		return nil, false
+	}
+
+	if !found || subscription.OrganizationID != organizationId {
+		respondError(w, problem(codeWebhookNotFound))
+		return nil, false
+	}
+
+	return subscription, true
+}
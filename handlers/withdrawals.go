@@ -0,0 +1,217 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+
+	"github.com/noctusha/tender/connection"
+	"github.com/noctusha/tender/models"
+)
+
+type bidStatusRequest struct {
+	Reason string `json:"reason"`
+}
+
+// authorBidFromRequest loads the bid addressed by the route, and its tender, on behalf of its
+// author and makes sure the bid can still be changed: the tender is neither closed nor cancelled, its deadline
+// has not passed and the lot, if any, is still open. It writes the error response itself and
+// reports false on failure.
+func (h *Handler) authorBidFromRequest(w http.ResponseWriter, r *http.Request) (*models.Bid, *models.Tender, string, bool) {
+	vars := mux.Vars(r)
+
+	bidID, err := uuid.Parse(vars["bidId"])
+	if err != nil {
+		respondError(w, problem(codeInvalidParameter, "bidId"))
+		return nil, nil, "", false
+	}
+
+	var username string
+	for name, vals := range r.URL.Query() {
+		switch name {
+		case "username":
+			username = vals[0]
+		default:
+			respondError(w, problem(codeUnknownParameter, name))
+			return nil, nil, "", false
+		}
+	}
+
+	if username == "" {
+		respondError(w, problem(codeMissingUsername))
+		return nil, nil, "", false
+	}
+
+	bid, err := h.repository(r).GetBidByID(bidID)
+	if err != nil {
+		respondError(w, err)
+		return nil, nil, "", false
+	}
+
+	isAuthor, err := h.isBidAuthor(r, bid, username)
+	if err != nil {
+		respondError(w, fmt.Errorf("failed to check bid author: %w", err))
+		return nil, nil, "", false
+	}
+
+	if !isAuthor {
+		respondError(w, problem(codeNotBidAuthor, username))
+		return nil, nil, "", false
+	}
+
+	tenderID, err := uuid.Parse(bid.TenderID)
+	if err != nil {
+		respondError(w, fmt.Errorf("invalid tenderID format: %w", err))
+		return nil, nil, "", false
+	}
+
+	tender, ok, err := h.repository(r).GetTenderByID(tenderID)
+	if err != nil {
+		respondError(w, fmt.Errorf("failed to get tender: %w", err))
+		return nil, nil, "", false
+	}
+
+	if !ok {
+		respondError(w, problem(codeTenderNotFound))
+		return nil, nil, "", false
+	}
+
+	if tender.Status == statusClosed || tender.Status == statusCancelled {
+		respondError(w, problem(codeTenderStatusConflict, tender.Status))
+		return nil, nil, "", false
+	}
+
+	if deadlinePassed(tender) {
+		respondError(w, problem(codeDeadlinePassed))
+		return nil, nil, "", false
+	}
+
+	if bid.LotID != "" {
+		lotID, err := uuid.Parse(bid.LotID)
+		if err != nil {
+			respondError(w, fmt.Errorf("invalid lotID format: %w", err))
+			return nil, nil, "", false
+		}
+
+		lot, ok, err := h.repository(r).GetLotByID(lotID)
+		if err != nil {
+			respondError(w, fmt.Errorf("failed to get lot: %w", err))
+			return nil, nil, "", false
+		}
+
+		if !ok {
+			respondError(w, problem(codeLotNotFound))
+			return nil, nil, "", false
+		}
+
+		if lot.Status != lotStatusOpen {
+			respondError(w, problem(codeLotStatusConflict, lot.Status))
+			return nil, nil, "", false
+		}
+	}
+
+	return bid, tender, username, true
+}
+
+func (h *Handler) WithdrawBid(w http.ResponseWriter, r *http.Request) {
+	bid, tender, username, ok := h.authorBidFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	var req bidStatusRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		respondError(w, problem(codeInvalidJSON, err))
+		return
+	}
+
+	req.Reason = strings.TrimSpace(req.Reason)
+	if req.Reason == "" {
+		respondError(w, problem(codeReasonRequired))
+		return
+	}
+
+	if bid.Status != statusCreated {
+		respondError(w, problem(codeBidNotWithdrawable, bid.Status))
+		return
+	}
+
+	previous := *bid
+	bid.Status = bidStatusWithdrawn
+
+	event := connection.NewEvent(connection.EventBidWithdrawn, bid, h.bidOrganizationIDs(r, bid, tender)...)
+	err = h.repository(r).Transaction(func(repo *connection.Repository) error {
+		err := repo.ChangeBidStatus(bid, previous.Status, req.Reason, username, event)
+		if err != nil {
+			return fmt.Errorf("failed to withdraw bid: %w", err)
+		}
+		return h.audit(r, repo, username, auditActionStatus, auditEntityBid, bid.ID, previous, bid, event.OrganizationIDs...)
+	})
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+	h.outbox.Wake()
+
+	respondJSON(w, http.StatusOK, bid)
+}
+
+func (h *Handler) ResubmitBid(w http.ResponseWriter, r *http.Request) {
+	bid, tender, username, ok := h.authorBidFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	var req bidStatusRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		respondError(w, problem(codeInvalidJSON, err))
+		return
+	}
+
+	if bid.Status != bidStatusWithdrawn {
+		respondError(w, problem(codeBidNotWithdrawn))
+		return
+	}
+
+	previous := *bid
+	bid.Status = statusCreated
+
+	// The bid is back in the tender, so it is announced like a new one.
+	event := connection.NewEvent(connection.EventBidCreated, bid, h.bidOrganizationIDs(r, bid, tender)...)
+	err = h.repository(r).Transaction(func(repo *connection.Repository) error {
+		err := repo.ChangeBidStatus(bid, previous.Status, strings.TrimSpace(req.Reason), username, event)
+		if err != nil {
+			return fmt.Errorf("failed to resubmit bid: %w", err)
+		}
+		return h.audit(r, repo, username, auditActionStatus, auditEntityBid, bid.ID, previous, bid, event.OrganizationIDs...)
+	})
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+	h.outbox.Wake()
+
+	respondJSON(w, http.StatusOK, bid)
+}
+
+// BidHistory lists withdrawals and resubmissions of a bid to its author and the tender organization.
+func (h *Handler) BidHistory(w http.ResponseWriter, r *http.Request) {
+	bid, ok := h.bidReaderFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	history, err := h.repository(r).BidStatusHistory(bid.ID)
+	if err != nil {
+		respondError(w, fmt.Errorf("failed to select bid history from database: %w", err))
+		return
+	}
+
+	respondJSON(w, http.StatusOK, JSON{BidHistory: &history})
+}
@@ -0,0 +1,25 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestBidStatusRequest(t *testing.T) {
+	vars := map[string]string{"bidId": "7c9e6679-7425-40de-944b-e07fc1f90ae7"}
+	tests := []requestTest{
+		{name: "bid id", target: "/?username=user1", vars: map[string]string{"bidId": "42"},
+			wantStatus: http.StatusBadRequest, wantCode: codeInvalidParameter},
+		{name: "unknown parameter", target: "/?username=user1&reason=late", vars: vars,
+			wantStatus: http.StatusBadRequest, wantCode: codeUnknownParameter},
+		{name: "no username", target: "/", vars: vars, body: `{"reason":"late"}`,
+			wantStatus: http.StatusBadRequest, wantCode: codeMissingUsername},
+	}
+
+	t.Run("withdraw", func(t *testing.T) {
+		runRequestTests(t, http.MethodPut, func(h *Handler) http.HandlerFunc { return h.WithdrawBid }, tests)
+	})
+	t.Run("resubmit", func(t *testing.T) {
+		runRequestTests(t, http.MethodPut, func(h *Handler) http.HandlerFunc { return h.ResubmitBid }, tests)
+	})
+}
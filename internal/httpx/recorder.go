@@ -0,0 +1,32 @@
+// Package httpx holds what the HTTP middleware of the service share.
+package httpx
+
+import "net/http"
+
+// StatusRecorder passes a response through and keeps its status for the middleware that log,
+// measure and trace requests. The status is 200 until the handler writes another.
+type StatusRecorder struct {
+	http.ResponseWriter
+	Status int
+}
+
+func NewStatusRecorder(w http.ResponseWriter) *StatusRecorder {
+	return &StatusRecorder{ResponseWriter: w, Status: http.StatusOK}
+}
+
+func (rec *StatusRecorder) WriteHeader(status int) {
+	rec.Status = status
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+// Flush keeps the event stream working through the recorder.
+func (rec *StatusRecorder) Flush() {
+	if flusher, ok := rec.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Unwrap lets http.ResponseController reach the response behind the recorder.
+func (rec *StatusRecorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
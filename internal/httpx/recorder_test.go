@@ -0,0 +1,48 @@
+package httpx
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestStatusRecorder(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		want    int
+	}{
+		{name: "body only", handler: func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("ok")) }, want: http.StatusOK},
+		{name: "status", handler: func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusNotFound) }, want: http.StatusNotFound},
+		{name: "error", handler: func(w http.ResponseWriter, r *http.Request) { http.Error(w, "failed", http.StatusInternalServerError) }, want: http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			recorder := NewStatusRecorder(w)
+			tt.handler(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+
+			if recorder.Status != tt.want {
+				t.Errorf("Status = %d, want %d", recorder.Status, tt.want)
+			}
+			if w.Code != tt.want {
+				t.Errorf("response status = %d, want %d", w.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestStatusRecorderFlush(t *testing.T) {
+	w := httptest.NewRecorder()
+	recorder := NewStatusRecorder(w)
+
+	var flusher http.Flusher = recorder
+	flusher.Flush()
+	if !w.Flushed {
+		t.Error("Flush did not reach the response")
+	}
+
+	if err := http.NewResponseController(recorder).Flush(); err != nil {
+		t.Errorf("ResponseController.Flush: %v", err)
+	}
+}
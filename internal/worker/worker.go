@@ -0,0 +1,64 @@
+// Package worker holds what the background dispatchers share: the loop that polls for due work
+// and the backoff between failed attempts.
+package worker
+
+import (
+	"context"
+	"math/rand"
+	"time"
+)
+
+// Loop does a unit of work every interval, and also as soon as it is woken.
+type Loop struct {
+	interval time.Duration
+	wake     chan struct{}
+}
+
+func NewLoop(interval time.Duration) *Loop {
+	return &Loop{interval: interval, wake: make(chan struct{}, 1)}
+}
+
+// Wake makes the loop do its work without waiting for the next tick. It never blocks: wakes
+// coming before the loop got to the previous one are merged into it.
+func (l *Loop) Wake() {
+	select {
+	case l.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run does the work right away and then on every tick and wake until the context is cancelled.
+func (l *Loop) Run(ctx context.Context, work func(ctx context.Context)) {
+	ticker := time.NewTicker(l.interval)
+	defer ticker.Stop()
+
+	for {
+		work(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-l.wake:
+		}
+	}
+}
+
+// Backoff is the delay before the next attempt: Base after the first failure, doubling with
+// every attempt up to Max.
+type Backoff struct {
+	Base time.Duration
+	Max  time.Duration
+}
+
+// Delay returns the delay after the given failed attempt, counted from 1, plus up to a fifth
+// of it as jitter so that attempts failing together do not retry together.
+func (b Backoff) Delay(attempt int) time.Duration {
+	delay := b.Max
+	if attempt < 20 {
+		if d := b.Base << (attempt - 1); d < b.Max {
+			delay = d
+		}
+	}
+	return delay + time.Duration(rand.Int63n(int64(delay)/5+1))
+}
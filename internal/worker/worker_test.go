@@ -0,0 +1,51 @@
+package worker
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestBackoffDelay(t *testing.T) {
+	backoff := Backoff{Base: 5 * time.Second, Max: time.Minute}
+
+	tests := []struct {
+		attempt int
+		want    time.Duration
+	}{
+		{attempt: 1, want: 5 * time.Second},
+		{attempt: 2, want: 10 * time.Second},
+		{attempt: 4, want: 40 * time.Second},
+		{attempt: 5, want: time.Minute},
+		{attempt: 19, want: time.Minute},
+		{attempt: 100, want: time.Minute},
+	}
+	for _, tt := range tests {
+		got := backoff.Delay(tt.attempt)
+		if got < tt.want || got > tt.want+tt.want/5 {
+			t.Errorf("Delay(%d) = %v, want %v plus at most a fifth", tt.attempt, got, tt.want)
+		}
+	}
+}
+
+func TestLoopWake(t *testing.T) {
+	loop := NewLoop(time.Hour)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	runs := make(chan struct{})
+	go loop.Run(ctx, func(context.Context) { runs <- struct{}{} })
+
+	<-runs
+	loop.Wake()
+	select {
+	case <-runs:
+	case <-time.After(time.Second):
+		t.Fatal("woken loop did not run")
+	}
+
+	// Wakes never block, even while the loop is busy.
+	loop.Wake()
+	loop.Wake()
+	<-runs
+}
@@ -1,8 +1,9 @@
 package main
 
 import (
+	"context"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
 	"os"
 
@@ -10,46 +11,196 @@ import (
 
 	"github.com/noctusha/tender/connection"
 	"github.com/noctusha/tender/handlers"
+	"github.com/noctusha/tender/metrics"
+	"github.com/noctusha/tender/notifications"
+	"github.com/noctusha/tender/openapi"
+	"github.com/noctusha/tender/outbox"
+	"github.com/noctusha/tender/receipts"
+	"github.com/noctusha/tender/storage"
+	"github.com/noctusha/tender/tracing"
+	"github.com/noctusha/tender/webhooks"
 )
 
 func main() {
+	slog.SetDefault(slog.New(handlers.LogHandler{
+		Handler: slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: logLevel()}),
+	}))
+
+	err := run()
+	if err != nil {
+		slog.Error("server stopped", "error", err)
+		os.Exit(1)
+	}
+}
+
+// run starts the server and returns once it stops. Deferred cleanup, such as flushing the spans
+// of tracing, runs before main exits.
+func run() error {
+	shutdownTracing, err := tracing.Setup(context.Background())
+	if err != nil {
+		return fmt.Errorf("failed to init tracing: %w", err)
+	}
+	defer shutdownTracing(context.Background())
 
 	repo, err := connection.NewRepository()
 	if err != nil {
-		log.Fatalf("failed to connect to database: %v", err)
+		return fmt.Errorf("failed to connect to database: %w", err)
 	}
 	defer repo.Close()
 
 	err = repo.InitSchema()
 	if err != nil {
-		log.Fatalf("failed to init database: %v", err)
+		return fmt.Errorf("failed to init database: %w", err)
+	}
+
+	blobs, err := storage.NewBlobStorage()
+	if err != nil {
+		return fmt.Errorf("failed to init attachment storage: %w", err)
+	}
+
+	hooks := webhooks.NewDispatcher(repo)
+	go hooks.Run(context.Background())
+
+	mailer, err := notifications.NewNotifier(repo, notifications.NewSMTPSender())
+	if err != nil {
+		return fmt.Errorf("failed to init notifications: %w", err)
+	}
+
+	go notifications.NewReminder(repo).Run(context.Background())
+
+	broker := outbox.NewBroker()
+	sinks, err := outbox.SelectSinks(outbox.LogSink{}, hooks, broker, mailer, notifications.NewInbox(repo))
+	if err != nil {
+		return fmt.Errorf("failed to configure outbox: %w", err)
+	}
+
+	events := outbox.NewDispatcher(repo, sinks...)
+	go events.Run(context.Background())
+
+	signer, err := receipts.NewSigner()
+	if err != nil {
+		return fmt.Errorf("failed to init receipts: %w", err)
+	}
+
+	stats := metrics.New(repo)
+	go stats.Run(context.Background())
+
+	doc, err := openapi.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load openapi document: %w", err)
 	}
 
-	handler := handlers.NewHandler(repo)
+	handler := handlers.NewHandler(repo, blobs, events, hooks, broker, signer)
 
 	router := mux.NewRouter()
+	router.NotFoundHandler = http.HandlerFunc(handlers.NotFound)
+	router.MethodNotAllowedHandler = http.HandlerFunc(handlers.MethodNotAllowed)
+	router.Use(handlers.RequestID)
+	router.Use(tracing.Middleware)
+	router.Use(handlers.AccessLog)
+	router.Use(stats.Middleware)
+	router.Use(handlers.Language)
+	router.Use(handlers.ValidateRequests(doc))
 
 	router.Methods(http.MethodGet).Path("/api/ping").HandlerFunc(handler.PingHandler)
+	router.Methods(http.MethodGet).Path("/api/openapi.json").HandlerFunc(handler.OpenAPI)
+	router.Methods(http.MethodGet).Path("/metrics").Handler(stats.Handler())
+
+	router.Methods(http.MethodGet).Path("/api/audit").HandlerFunc(handler.ListAudit)
+	router.Methods(http.MethodGet).Path("/api/audit/verify").HandlerFunc(handler.VerifyAudit)
+	router.Methods(http.MethodGet).Path("/api/receipts/key").HandlerFunc(handler.ReceiptKey)
+
+	router.Methods(http.MethodGet).Path("/api/employees/signing_key").HandlerFunc(handler.GetSigningKey)
+	router.Methods(http.MethodPut).Path("/api/employees/signing_key").HandlerFunc(handler.UpdateSigningKey)
+
+	router.Methods(http.MethodGet).Path("/api/service_types").HandlerFunc(handler.ListServiceTypes)
+	router.Methods(http.MethodPost).Path("/api/service_types/new").HandlerFunc(handler.NewServiceType)
+	router.Methods(http.MethodPatch).Path("/api/service_types/{serviceTypeId}/edit").HandlerFunc(handler.EditServiceType)
+	router.Methods(http.MethodDelete).Path("/api/service_types/{serviceTypeId}").HandlerFunc(handler.DeleteServiceType)
 
 	router.Methods(http.MethodGet).Path("/api/tenders").HandlerFunc(handler.ListTenders)
+	router.Methods(http.MethodGet).Path("/api/tenders/search").HandlerFunc(handler.SearchTenders)
 	router.Methods(http.MethodPost).Path("/api/tenders/new").HandlerFunc(handler.NewTender)
 	router.Methods(http.MethodGet).Path("/api/tenders/my").HandlerFunc(handler.MyTenders)
 	router.Methods(http.MethodGet).Path("/api/tenders/{tenderId}/status").HandlerFunc(handler.GetTenderStatus)
+	router.Methods(http.MethodGet).Path("/api/tenders/{tenderId}/events").HandlerFunc(handler.TenderEvents)
+	router.Methods(http.MethodGet).Path("/api/tenders/{tenderId}/verify").HandlerFunc(handler.VerifyTender)
 	router.Methods(http.MethodPut).Path("/api/tenders/{tenderId}/status").HandlerFunc(handler.SetTenderStatus)
 	router.Methods(http.MethodPatch).Path("/api/tenders/{tenderId}/edit").HandlerFunc(handler.EditTender)
 	router.Methods(http.MethodPut).Path("/api/tenders/{tenderId}/rollback/{version}").HandlerFunc(handler.RollbackTender)
 
+	router.Methods(http.MethodGet).Path("/api/tenders/{tenderId}/lots").HandlerFunc(handler.ListLots)
+	router.Methods(http.MethodPost).Path("/api/tenders/{tenderId}/lots/new").HandlerFunc(handler.NewLot)
+	router.Methods(http.MethodPut).Path("/api/tenders/{tenderId}/lots/{lotId}/status").HandlerFunc(handler.SetLotStatus)
+
+	router.Methods(http.MethodGet).Path("/api/tenders/{tenderId}/attachments").HandlerFunc(handler.ListTenderAttachments)
+	router.Methods(http.MethodPost).Path("/api/tenders/{tenderId}/attachments").HandlerFunc(handler.UploadTenderAttachment)
+	router.Methods(http.MethodGet).Path("/api/tenders/{tenderId}/attachments/{attachmentId}").HandlerFunc(handler.DownloadTenderAttachment)
+	router.Methods(http.MethodDelete).Path("/api/tenders/{tenderId}/attachments/{attachmentId}").HandlerFunc(handler.DeleteTenderAttachment)
+
+	router.Methods(http.MethodGet).Path("/api/tenders/{tenderId}/amendments").HandlerFunc(handler.ListAmendments)
+
+	router.Methods(http.MethodGet).Path("/api/tenders/{tenderId}/questions").HandlerFunc(handler.ListQuestions)
+	router.Methods(http.MethodPost).Path("/api/tenders/{tenderId}/questions").HandlerFunc(handler.NewQuestion)
+	router.Methods(http.MethodPut).Path("/api/tenders/{tenderId}/questions/{questionId}/answer").HandlerFunc(handler.AnswerQuestion)
+
+	router.Methods(http.MethodGet).Path("/api/invitations/my").HandlerFunc(handler.MyInvitations)
+	router.Methods(http.MethodGet).Path("/api/tenders/{tenderId}/invitations").HandlerFunc(handler.ListInvitations)
+	router.Methods(http.MethodPost).Path("/api/tenders/{tenderId}/invitations").HandlerFunc(handler.NewInvitation)
+	router.Methods(http.MethodDelete).Path("/api/tenders/{tenderId}/invitations/{invitationId}").HandlerFunc(handler.DeleteInvitation)
+	router.Methods(http.MethodPut).Path("/api/tenders/{tenderId}/invitations/{invitationId}/accept").HandlerFunc(handler.AcceptInvitation)
+	router.Methods(http.MethodPut).Path("/api/tenders/{tenderId}/invitations/{invitationId}/decline").HandlerFunc(handler.DeclineInvitation)
+
+	router.Methods(http.MethodGet).Path("/api/notifications").HandlerFunc(handler.ListNotifications)
+	router.Methods(http.MethodPut).Path("/api/notifications/read").HandlerFunc(handler.MarkNotificationsRead)
+	router.Methods(http.MethodPut).Path("/api/notifications/{notificationId}/read").HandlerFunc(handler.MarkNotificationRead)
+	router.Methods(http.MethodGet).Path("/api/notifications/preferences").HandlerFunc(handler.GetNotificationPreferences)
+	router.Methods(http.MethodPut).Path("/api/notifications/preferences").HandlerFunc(handler.UpdateNotificationPreferences)
+
+	router.Methods(http.MethodGet).Path("/api/webhooks").HandlerFunc(handler.ListWebhooks)
+	router.Methods(http.MethodPost).Path("/api/webhooks/new").HandlerFunc(handler.NewWebhook)
+	router.Methods(http.MethodPatch).Path("/api/webhooks/{webhookId}/edit").HandlerFunc(handler.EditWebhook)
+	router.Methods(http.MethodDelete).Path("/api/webhooks/{webhookId}").HandlerFunc(handler.DeleteWebhook)
+	router.Methods(http.MethodGet).Path("/api/webhooks/{webhookId}/deliveries").HandlerFunc(handler.ListWebhookDeliveries)
+	router.Methods(http.MethodPut).Path("/api/webhooks/{webhookId}/deliveries/{deliveryId}/replay").HandlerFunc(handler.ReplayWebhookDelivery)
+
 	router.Methods(http.MethodPost).Path("/api/bids/new").HandlerFunc(handler.NewBid)
 	router.Methods(http.MethodGet).Path("/api/bids/my").HandlerFunc(handler.MyBids)
 	router.Methods(http.MethodGet).Path("/api/bids/{tenderId}/list").HandlerFunc(handler.ListBidsByTenderId)
 	router.Methods(http.MethodPatch).Path("/api/bids/{bidId}/edit").HandlerFunc(handler.EditBid)
 	router.Methods(http.MethodPut).Path("/api/bids/{bidId}/rollback/{version}").HandlerFunc(handler.RollbackBid)
+	router.Methods(http.MethodPut).Path("/api/bids/{bidId}/withdraw").HandlerFunc(handler.WithdrawBid)
+	router.Methods(http.MethodPut).Path("/api/bids/{bidId}/resubmit").HandlerFunc(handler.ResubmitBid)
+	router.Methods(http.MethodGet).Path("/api/bids/{bidId}/history").HandlerFunc(handler.BidHistory)
+	router.Methods(http.MethodGet).Path("/api/bids/{bidId}/receipt").HandlerFunc(handler.BidReceipt)
+	router.Methods(http.MethodGet).Path("/api/bids/{bidId}/signature").HandlerFunc(handler.VerifyBidSignature)
+	router.Methods(http.MethodGet).Path("/api/bids/{bidId}/attachments").HandlerFunc(handler.ListBidAttachments)
+	router.Methods(http.MethodPost).Path("/api/bids/{bidId}/attachments").HandlerFunc(handler.UploadBidAttachment)
+	router.Methods(http.MethodGet).Path("/api/bids/{bidId}/attachments/{attachmentId}").HandlerFunc(handler.DownloadBidAttachment)
+	router.Methods(http.MethodDelete).Path("/api/bids/{bidId}/attachments/{attachmentId}").HandlerFunc(handler.DeleteBidAttachment)
+	router.Methods(http.MethodPut).Path("/api/bids/{bidId}/reconfirm").HandlerFunc(handler.ReconfirmBid)
+	router.Methods(http.MethodPut).Path("/api/bids/{bidId}/submit_decision").HandlerFunc(handler.SubmitBidDecision)
+
+	err = openapi.CheckRoutes(doc, router)
+	if err != nil {
+		return fmt.Errorf("failed to check routes: %w", err)
+	}
 
-	fmt.Println("server is running")
+	slog.Info("server is running", "address", os.Getenv("SERVER_ADDRESS"))
 
 	err = http.ListenAndServe(os.Getenv("SERVER_ADDRESS"), router)
 	if err != nil {
-		log.Fatalf("error handling Listen and Serve: %v", err)
+		return fmt.Errorf("failed to listen and serve: %w", err)
 	}
+	return nil
+}
 
+// logLevel reads LOG_LEVEL (debug, info, warn or error); the level is info by default.
+func logLevel() slog.Level {
+	var level slog.Level
+	err := level.UnmarshalText([]byte(os.Getenv("LOG_LEVEL")))
+	if err != nil {
+		return slog.LevelInfo
+	}
+	return level
 }
@@ -0,0 +1,162 @@
+// Package metrics exposes the Prometheus metrics of the service: HTTP requests by route, the
+// database connection pool and the state of the tender pipeline.
+package metrics
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"github.com/noctusha/tender/connection"
+	"github.com/noctusha/tender/internal/httpx"
+)
+
+const (
+	namespace = "tender"
+
+	defaultCollectInterval = 30 * time.Second
+)
+
+// Metrics holds the metrics of the service and periodically collects the pipeline gauges,
+// which take a query to count.
+type Metrics struct {
+	repo     *connection.Repository
+	registry *prometheus.Registry
+	interval time.Duration
+
+	requests         *prometheus.CounterVec
+	requestDuration  *prometheus.HistogramVec
+	tenders          *prometheus.GaugeVec
+	bids             *prometheus.GaugeVec
+	pendingDecisions prometheus.Gauge
+	collected        prometheus.Gauge
+}
+
+// New reads METRICS_COLLECT_INTERVAL, how often to count tenders and bids (a Go duration).
+func New(repo *connection.Repository) *Metrics {
+	interval := defaultCollectInterval
+	if v, err := time.ParseDuration(os.Getenv("METRICS_COLLECT_INTERVAL")); err == nil && v > 0 {
+		interval = v
+	}
+
+	m := &Metrics{
+		repo:     repo,
+		registry: prometheus.NewRegistry(),
+		interval: interval,
+
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "http_requests_total",
+			Help:      "HTTP requests by route template and status code.",
+		}, []string{"method", "route", "status"}),
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "Latency of HTTP requests by route template and status code.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method", "route", "status"}),
+		tenders: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "tenders",
+			Help:      "Tenders by status.",
+		}, []string{"status"}),
+		bids: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "bids",
+			Help:      "Bids by status.",
+		}, []string{"status"}),
+		pendingDecisions: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "pending_decisions",
+			Help:      "Bids of published tenders waiting for the decision of the tender owner.",
+		}),
+		collected: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "pipeline_collected_timestamp_seconds",
+			Help:      "Time the tender and bid gauges were last collected.",
+		}),
+	}
+
+	m.registry.MustRegister(
+		m.requests,
+		m.requestDuration,
+		m.tenders,
+		m.bids,
+		m.pendingDecisions,
+		m.collected,
+		newDBStatsCollector(repo),
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+	return m
+}
+
+// Handler serves the metrics in the Prometheus text format.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// Middleware counts the requests of every route and times them. Routes are labelled by their
+// template, so /api/tenders/{tenderId}/edit is one series whatever the tender.
+func (m *Metrics) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		recorder := httpx.NewStatusRecorder(w)
+
+		next.ServeHTTP(recorder, r)
+
+		var route string
+		if current := mux.CurrentRoute(r); current != nil {
+			route, _ = current.GetPathTemplate()
+		}
+
+		labels := prometheus.Labels{"method": r.Method, "route": route, "status": strconv.Itoa(recorder.Status)}
+		m.requests.With(labels).Inc()
+		m.requestDuration.With(labels).Observe(time.Since(start).Seconds())
+	})
+}
+
+// Run collects the pipeline gauges until ctx is done.
+func (m *Metrics) Run(ctx context.Context) {
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+
+	for {
+		m.collectPipeline()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (m *Metrics) collectPipeline() {
+	stats, err := m.repo.PipelineStats()
+	if err != nil {
+		slog.Error("failed to collect pipeline metrics", "error", err)
+		return
+	}
+
+	m.tenders.Reset()
+	for status, count := range stats.TendersByStatus {
+		m.tenders.WithLabelValues(status).Set(float64(count))
+	}
+
+	m.bids.Reset()
+	for status, count := range stats.BidsByStatus {
+		m.bids.WithLabelValues(status).Set(float64(count))
+	}
+
+	m.pendingDecisions.Set(float64(stats.PendingDecisions))
+	m.collected.SetToCurrentTime()
+}
@@ -0,0 +1,72 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestMiddlewareLabels(t *testing.T) {
+	m := New(nil)
+
+	router := mux.NewRouter()
+	router.Use(m.Middleware)
+	router.Methods(http.MethodPatch).Path("/api/tenders/{tenderId}/edit").HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if mux.Vars(r)["tenderId"] == "missing" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte("{}"))
+	})
+	router.Methods(http.MethodGet).Path("/api/ping").HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	})
+	unrouted := m.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	}))
+
+	requests := []struct {
+		handler http.Handler
+		method  string
+		target  string
+	}{
+		{router, http.MethodPatch, "/api/tenders/550e8400-e29b-41d4-a716-446655440000/edit"},
+		{router, http.MethodPatch, "/api/tenders/6ba7b810-9dad-11d1-80b4-00c04fd430c8/edit"},
+		{router, http.MethodPatch, "/api/tenders/missing/edit"},
+		{router, http.MethodGet, "/api/ping"},
+		{unrouted, http.MethodGet, "/anything"},
+	}
+	for _, req := range requests {
+		req.handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(req.method, req.target, nil))
+	}
+
+	tests := []struct {
+		method string
+		route  string
+		status string
+		want   float64
+	}{
+		{method: http.MethodPatch, route: "/api/tenders/{tenderId}/edit", status: "200", want: 2},
+		{method: http.MethodPatch, route: "/api/tenders/{tenderId}/edit", status: "404", want: 1},
+		{method: http.MethodGet, route: "/api/ping", status: "200", want: 1},
+		{method: http.MethodGet, route: "", status: "418", want: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.route+" "+tt.status, func(t *testing.T) {
+			got := testutil.ToFloat64(m.requests.WithLabelValues(tt.method, tt.route, tt.status))
+			if got != tt.want {
+				t.Errorf("requests = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if got := testutil.CollectAndCount(m.requests); got != len(tests) {
+		t.Errorf("request series = %d, want %d: one per route template and status", got, len(tests))
+	}
+	if got := testutil.CollectAndCount(m.requestDuration); got != len(tests) {
+		t.Errorf("duration series = %d, want %d", got, len(tests))
+	}
+}
@@ -1,31 +1,107 @@
 package models
 
+import (
+	"encoding/json"
+	"time"
+)
+
 type Tender struct {
-	ID              string `json:"id"`
-	Name            string `json:"name"`
-	Description     string `json:"description"`
-	ServiceType     string `json:"serviceType"`
-	Status          string `json:"status"`
-	OrganizationID  string `json:"organizationId"`
-	CreatorUserName string `json:"creatorUsername"`
+	ID              string     `json:"id"`
+	Name            string     `json:"name" validate:"required,max=100"`
+	Description     string     `json:"description"`
+	ServiceType     string     `json:"serviceType" validate:"required,max=100"`
+	Status          string     `json:"status"`
+	OrganizationID  string     `json:"organizationId" validate:"required,uuid"`
+	CreatorUserName string     `json:"creatorUsername" validate:"required,max=50"`
+	Visibility      string     `json:"visibility" validate:"enum=PUBLIC|INVITE_ONLY"`
+	Deadline        *time.Time `json:"deadline,omitempty"`
+	Lots            []Lot      `json:"lots,omitempty"`
+}
+
+type TenderSearchResult struct {
+	Tender
+	Rank          float64 `json:"rank"`
+	NameHighlight string  `json:"nameHighlight"`
+	Snippet       string  `json:"snippet"`
+}
+
+type Lot struct {
+	ID          string  `json:"id"`
+	TenderID    string  `json:"tenderId"`
+	Description string  `json:"description" validate:"required"`
+	Quantity    int     `json:"quantity"`
+	Budget      float64 `json:"budget"`
+	ServiceType string  `json:"serviceType" validate:"required,max=100"`
+	Status      string  `json:"status"`
+	WinnerBidID string  `json:"winnerBidId,omitempty"`
+}
+
+type TenderInvitation struct {
+	ID             string `json:"id"`
+	TenderID       string `json:"tenderId"`
+	OrganizationID string `json:"organizationId" validate:"required,uuid"`
+	Status         string `json:"status"`
+	CreatedAt      string `json:"createdAt"`
+	UpdatedAt      string `json:"updatedAt"`
+}
+
+type TenderQuestion struct {
+	ID             string `json:"id"`
+	TenderID       string `json:"tenderId"`
+	Question       string `json:"question"`
+	AuthorUsername string `json:"authorUsername,omitempty"`
+	OrganizationID string `json:"organizationId,omitempty"`
+	Answer         string `json:"answer,omitempty"`
+	AnsweredBy     string `json:"answeredBy,omitempty"`
+	Public         bool   `json:"public"`
+	CreatedAt      string `json:"createdAt"`
+	AnsweredAt     string `json:"answeredAt,omitempty"`
 }
 
 type TenderVersion struct {
-	ID          string `json:"id"`
-	TenderID    string `json:"tender_id"`
-	Name        string `json:"name"`
-	Description string `json:"description"`
+	ID          string     `json:"id"`
+	TenderID    string     `json:"tender_id"`
+	Name        string     `json:"name"`
+	Description string     `json:"description"`
+	Deadline    *time.Time `json:"deadline,omitempty"`
+
+	AttachmentIDs []string `json:"attachmentIds"`
+}
+
+type TenderAmendment struct {
+	ID              string   `json:"id"`
+	TenderID        string   `json:"tenderId"`
+	TenderVersionID string   `json:"tenderVersionId"`
+	Reason          string   `json:"reason,omitempty"`
+	ChangedFields   []string `json:"changedFields"`
+	AffectedBids    int      `json:"affectedBids"`
+	CreatedBy       string   `json:"createdBy"`
+	CreatedAt       string   `json:"createdAt"`
 }
 
 type Bid struct {
 	ID              string `json:"id"`
-	Name            string `json:"name"`
+	Name            string `json:"name" validate:"required,max=100"`
 	Description     string `json:"description"`
 	Status          string `json:"status"`
-	TenderID        string `json:"tenderId"`
+	TenderID        string `json:"tenderId" validate:"required,uuid"`
 	CreatorUserName string `json:"creatorUsername,omitempty"`
-	AuthorType      string `json:"authorType"`
-	AuthorId        string `json:"authorId"`
+	AuthorType      string `json:"authorType" validate:"required,enum=User|Organization"`
+	AuthorId        string `json:"authorId" validate:"required,uuid"`
+	LotID           string `json:"lotId,omitempty" validate:"uuid"`
+	// Version counts the versions of a bid from 1; every edit and rollback makes a new one.
+	Version int `json:"version"`
+
+	NeedsReconfirmation bool `json:"needsReconfirmation"`
+}
+
+type BidStatusChange struct {
+	ID        string `json:"id"`
+	BidID     string `json:"bidId"`
+	Status    string `json:"status"`
+	Reason    string `json:"reason,omitempty"`
+	ChangedBy string `json:"changedBy"`
+	CreatedAt string `json:"createdAt"`
 }
 
 type BidVersion struct {
@@ -33,6 +109,32 @@ type BidVersion struct {
 	BidID       string `json:"bid_id"`
 	Name        string `json:"name"`
 	Description string `json:"description"`
+
+	AttachmentIDs []string      `json:"attachmentIds"`
+	Signature     *BidSignature `json:"signature,omitempty"`
+}
+
+// BidSignature is the Ed25519 signature of a version of a bid by the employee SignedBy, with the
+// public key it was made with.
+type BidSignature struct {
+	SignedBy  string `json:"signedBy"`
+	PublicKey string `json:"publicKey"`
+	Signature string `json:"signature"`
+	// Version is the version of the bid that was signed.
+	Version int `json:"version"`
+}
+
+type Attachment struct {
+	ID          string `json:"id"`
+	EntityType  string `json:"entityType"`
+	EntityID    string `json:"entityId"`
+	FileName    string `json:"fileName"`
+	ContentType string `json:"contentType"`
+	Size        int64  `json:"size"`
+	SHA256      string `json:"sha256"`
+	StorageKey  string `json:"-"`
+	UploadedBy  string `json:"uploadedBy"`
+	CreatedAt   string `json:"createdAt"`
 }
 
 type Employee struct {
@@ -58,3 +160,140 @@ type OrganizationResponsible struct {
 	OrganizationID string `json:"organizationId"`
 	UserID         string `json:"userId"`
 }
+
+type ServiceType struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	ParentID    string `json:"parentId,omitempty"`
+	CreatedAt   string `json:"createdAt"`
+	UpdatedAt   string `json:"updatedAt"`
+}
+
+type WebhookSubscription struct {
+	ID             string   `json:"id"`
+	OrganizationID string   `json:"organizationId"`
+	URL            string   `json:"url"`
+	EventTypes     []string `json:"eventTypes"`
+	// Secret signs the payloads. It is only shown when the subscription is created.
+	Secret    string `json:"secret,omitempty"`
+	Active    bool   `json:"active"`
+	CreatedBy string `json:"createdBy"`
+	CreatedAt string `json:"createdAt"`
+	UpdatedAt string `json:"updatedAt"`
+}
+
+type WebhookDelivery struct {
+	ID             string          `json:"id"`
+	SubscriptionID string          `json:"subscriptionId"`
+	EventID        string          `json:"eventId"`
+	EventType      string          `json:"eventType"`
+	Payload        json.RawMessage `json:"payload"`
+	Status         string          `json:"status"`
+	Attempts       int             `json:"attempts"`
+	LastStatusCode int             `json:"lastStatusCode,omitempty"`
+	LastError      string          `json:"lastError,omitempty"`
+	NextAttemptAt  string          `json:"nextAttemptAt,omitempty"`
+	DeliveredAt    string          `json:"deliveredAt,omitempty"`
+	CreatedAt      string          `json:"createdAt"`
+}
+
+// Event is a domain event recorded in the outbox together with the change it describes.
+type Event struct {
+	// ID identifies the event across redeliveries, so consumers can drop duplicates.
+	ID       string `json:"id"`
+	Sequence int64  `json:"sequence"`
+	Type     string `json:"type"`
+	// OrganizationIDs are the organizations the event concerns.
+	OrganizationIDs []string `json:"-"`
+	// TenderID is set on events shown in the tender event stream. A restricted event is shown
+	// there only to OrganizationIDs.
+	TenderID   string      `json:"-"`
+	Restricted bool        `json:"-"`
+	Data       interface{} `json:"data"`
+	CreatedAt  string      `json:"createdAt"`
+}
+
+// NotificationPreferences control which notifications a user receives and in which language.
+type NotificationPreferences struct {
+	Email        string   `json:"email"`
+	EmailEnabled bool     `json:"emailEnabled"`
+	Language     string   `json:"language"`
+	MutedEvents  []string `json:"mutedEvents"`
+	UpdatedAt    string   `json:"updatedAt,omitempty"`
+}
+
+// Notification is an entry of an employee's in-app inbox.
+type Notification struct {
+	ID        string          `json:"id"`
+	Kind      string          `json:"kind"`
+	TenderID  string          `json:"tenderId,omitempty"`
+	BidID     string          `json:"bidId,omitempty"`
+	Data      json.RawMessage `json:"data"`
+	Read      bool            `json:"read"`
+	ReadAt    string          `json:"readAt,omitempty"`
+	CreatedAt string          `json:"createdAt"`
+}
+
+// NotificationRecipient is a user to notify about an event, with their preferred language.
+type NotificationRecipient struct {
+	Username  string
+	FirstName string
+	Email     string
+	Language  string
+}
+
+// AuditEvent records who changed what, with the entity before and after the change.
+type AuditEvent struct {
+	ID              string          `json:"id"`
+	Actor           string          `json:"actor"`
+	OrganizationIDs []string        `json:"organizationIds"`
+	Action          string          `json:"action"`
+	EntityType      string          `json:"entityType"`
+	EntityID        string          `json:"entityId"`
+	Before          json.RawMessage `json:"before,omitempty"`
+	After           json.RawMessage `json:"after,omitempty"`
+	RequestID       string          `json:"requestId,omitempty"`
+	CreatedAt       string          `json:"createdAt"`
+	// PrevHash and Hash chain the record to the previous one, see BidSeal.
+	PrevHash string `json:"prevHash,omitempty"`
+	Hash     string `json:"hash,omitempty"`
+}
+
+// BidSeal is a link of the hash chain of the bids of a tender: a version of a bid and the hash
+// of it together with the previous link.
+type BidSeal struct {
+	ID        string `json:"id"`
+	Sequence  int64  `json:"sequence"`
+	TenderID  string `json:"tenderId"`
+	BidID     string `json:"bidId"`
+	Action    string `json:"action"`
+	Content   string `json:"content"`
+	PrevHash  string `json:"prevHash"`
+	Hash      string `json:"hash"`
+	CreatedAt string `json:"createdAt"`
+}
+
+// BidReceipt proves that a version of a bid was sealed. Signature is the Ed25519 signature of the
+// receipt encoded as JSON without it.
+type BidReceipt struct {
+	BidID     string `json:"bidId"`
+	TenderID  string `json:"tenderId"`
+	Sequence  int64  `json:"sequence"`
+	Hash      string `json:"hash"`
+	SealedAt  string `json:"sealedAt"`
+	Signature string `json:"signature,omitempty"`
+}
+
+type ChainVerification struct {
+	Valid      bool        `json:"valid"`
+	Checked    int         `json:"checked"`
+	LastHash   string      `json:"lastHash,omitempty"`
+	BrokenLink *BrokenLink `json:"brokenLink,omitempty"`
+}
+
+type BrokenLink struct {
+	Sequence int64  `json:"sequence"`
+	ID       string `json:"id"`
+	Reason   string `json:"reason"`
+}
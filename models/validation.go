@@ -0,0 +1,122 @@
+package models
+
+import (
+	"encoding/json"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+)
+
+// Validation rules are declared in the validate tag of a field, separated by commas:
+//
+//	required       the value must not be blank
+//	max=N          the value must not be longer than N characters
+//	enum=A|B       the value must be one of A and B
+//	uuid           the value must be a UUID
+//
+// Only required applies to an empty value. Fields of embedded structs and of structs in slices
+// are checked too.
+
+// Violation is a field of a model that breaks one of its rules. Field is the JSON name of the
+// field, e.g. lots.0.description; Rule is required, maxLength, enum or format, and Limit the
+// value of the rule: the length, the allowed values or the format.
+type Violation struct {
+	Field string
+	Rule  string
+	Limit string
+}
+
+// Validate checks every field of v, a model or a pointer to one, and returns all the
+// violations.
+func Validate(v interface{}) []Violation {
+	return validateStruct(reflect.Indirect(reflect.ValueOf(v)), "", false)
+}
+
+// ValidateUpdate checks an update of a model. An empty field keeps its current value, so it is
+// not a violation of required.
+func ValidateUpdate(v interface{}) []Violation {
+	return validateStruct(reflect.Indirect(reflect.ValueOf(v)), "", true)
+}
+
+func validateStruct(value reflect.Value, prefix string, update bool) []Violation {
+	if value.Kind() != reflect.Struct {
+		return nil
+	}
+
+	var violations []Violation
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Type().Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		if field.Anonymous {
+			violations = append(violations, validateStruct(reflect.Indirect(value.Field(i)), prefix, update)...)
+			continue
+		}
+
+		name := prefix + jsonName(field)
+		switch fieldValue := value.Field(i); fieldValue.Kind() {
+		case reflect.String:
+			violations = append(violations, validateString(name, fieldValue.String(), field.Tag.Get("validate"), update)...)
+		case reflect.Slice:
+			for j := 0; j < fieldValue.Len(); j++ {
+				violations = append(violations, validateStruct(reflect.Indirect(fieldValue.Index(j)), name+"."+strconv.Itoa(j)+".", update)...)
+			}
+		}
+	}
+	return violations
+}
+
+func validateString(field, value, tag string, update bool) []Violation {
+	if tag == "" {
+		return nil
+	}
+
+	var violations []Violation
+	for _, rule := range strings.Split(tag, ",") {
+		name, limit, _ := strings.Cut(rule, "=")
+
+		if value == "" {
+			if name == "required" && !update {
+				violations = append(violations, Violation{Field: field, Rule: "required"})
+			}
+			continue
+		}
+
+		switch name {
+		case "required":
+			if strings.TrimSpace(value) == "" {
+				violations = append(violations, Violation{Field: field, Rule: "required"})
+			}
+		case "max":
+			max, err := strconv.Atoi(limit)
+			if err == nil && utf8.RuneCountInString(value) > max {
+				violations = append(violations, Violation{Field: field, Rule: "maxLength", Limit: limit})
+			}
+		case "enum":
+			allowed := strings.Split(limit, "|")
+			if !slices.Contains(allowed, value) {
+				values, _ := json.Marshal(allowed)
+				violations = append(violations, Violation{Field: field, Rule: "enum", Limit: string(values)})
+			}
+		case "uuid":
+			if _, err := uuid.Parse(value); err != nil {
+				violations = append(violations, Violation{Field: field, Rule: "format", Limit: "uuid"})
+			}
+		}
+	}
+	return violations
+}
+
+func jsonName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	if name == "" {
+		return field.Name
+	}
+	return name
+}
@@ -0,0 +1,85 @@
+package models
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func validBid() Bid {
+	return Bid{
+		Name:       "Поставка труб",
+		TenderID:   "550e8400-e29b-41d4-a716-446655440000",
+		AuthorType: "User",
+		AuthorId:   "a1b2c3d4-e5f6-7890-abcd-ef1234567890",
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name string
+		bid  func(bid *Bid)
+		want []Violation
+	}{
+		{name: "valid"},
+		{name: "name missing", bid: func(bid *Bid) { bid.Name = "" },
+			want: []Violation{{Field: "name", Rule: "required"}}},
+		{name: "name blank", bid: func(bid *Bid) { bid.Name = " \t" },
+			want: []Violation{{Field: "name", Rule: "required"}}},
+		{name: "name of 100 letters", bid: func(bid *Bid) { bid.Name = strings.Repeat("я", 100) }},
+		{name: "name too long", bid: func(bid *Bid) { bid.Name = strings.Repeat("я", 101) },
+			want: []Violation{{Field: "name", Rule: "maxLength", Limit: "100"}}},
+		{name: "unknown author type", bid: func(bid *Bid) { bid.AuthorType = "Employee" },
+			want: []Violation{{Field: "authorType", Rule: "enum", Limit: `["User","Organization"]`}}},
+		{name: "author id not a uuid", bid: func(bid *Bid) { bid.AuthorId = "user1" },
+			want: []Violation{{Field: "authorId", Rule: "format", Limit: "uuid"}}},
+		{name: "optional lot id", bid: func(bid *Bid) { bid.LotID = "not a uuid" },
+			want: []Violation{{Field: "lotId", Rule: "format", Limit: "uuid"}}},
+		{name: "all violations together", bid: func(bid *Bid) {
+			*bid = Bid{AuthorType: "Employee", AuthorId: "user1"}
+		}, want: []Violation{
+			{Field: "name", Rule: "required"},
+			{Field: "tenderId", Rule: "required"},
+			{Field: "authorType", Rule: "enum", Limit: `["User","Organization"]`},
+			{Field: "authorId", Rule: "format", Limit: "uuid"},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bid := validBid()
+			if tt.bid != nil {
+				tt.bid(&bid)
+			}
+
+			if got := Validate(&bid); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Validate = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateUpdate(t *testing.T) {
+	tests := []struct {
+		name   string
+		tender Tender
+		want   []Violation
+	}{
+		{name: "nothing changed"},
+		{name: "name changed", tender: Tender{Name: "Поставка бумаги"}},
+		{name: "name blanked", tender: Tender{Name: " "},
+			want: []Violation{{Field: "name", Rule: "required"}}},
+		{name: "name too long", tender: Tender{Name: strings.Repeat("a", 101)},
+			want: []Violation{{Field: "name", Rule: "maxLength", Limit: "100"}}},
+		{name: "unknown visibility", tender: Tender{Visibility: "PRIVATE"},
+			want: []Violation{{Field: "visibility", Rule: "enum", Limit: `["PUBLIC","INVITE_ONLY"]`}}},
+		{name: "lots", tender: Tender{Lots: []Lot{{Description: "Лот 1"}, {ServiceType: strings.Repeat("a", 101)}}},
+			want: []Violation{{Field: "lots.1.serviceType", Rule: "maxLength", Limit: "100"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ValidateUpdate(tt.tender); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ValidateUpdate = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
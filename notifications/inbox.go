@@ -0,0 +1,112 @@
+package notifications
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/noctusha/tender/connection"
+	"github.com/noctusha/tender/models"
+)
+
+const (
+	defaultReminderInterval = 10 * time.Minute
+	defaultReminderBefore   = 24 * time.Hour
+)
+
+// Inbox is an outbox sink that fills the in-app inboxes: bid authors learn about the decision
+// on their bids and tender owners about new bids. Other events are ignored.
+type Inbox struct {
+	repo *connection.Repository
+}
+
+func NewInbox(repo *connection.Repository) *Inbox {
+	return &Inbox{repo: repo}
+}
+
+func (i *Inbox) Name() string {
+	return "inbox"
+}
+
+// Publish stores the notifications of the event. The event id is their dedup key, so publishing
+// the event again adds nothing.
+func (i *Inbox) Publish(_ context.Context, event models.Event) error {
+	switch event.Type {
+	case connection.EventBidApproved, connection.EventBidRejected, connection.EventBidCreated:
+	default:
+		return nil
+	}
+
+	var bid models.Bid
+	err := decodeEventData(event, &bid)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(bid)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s notification: %w", event.Type, err)
+	}
+
+	notification := models.Notification{
+		Kind:     event.Type,
+		TenderID: bid.TenderID,
+		BidID:    bid.ID,
+		Data:     data,
+	}
+
+	if event.Type == connection.EventBidCreated {
+		_, err = i.repo.NotifyTenderOwner(notification, event.ID)
+	} else {
+		_, err = i.repo.NotifyBidAuthor(notification, event.ID)
+	}
+	return err
+}
+
+// Reminder periodically puts deadline reminders into the inboxes of bidders whose bids are
+// still pending when a tender is about to close.
+type Reminder struct {
+	repo     *connection.Repository
+	interval time.Duration
+	before   time.Duration
+}
+
+// NewReminder reads NOTIFICATION_REMINDER_INTERVAL, how often to look for closing tenders, and
+// NOTIFICATION_REMINDER_BEFORE, how long before the deadline to remind (Go durations).
+func NewReminder(repo *connection.Repository) *Reminder {
+	reminder := &Reminder{
+		repo:     repo,
+		interval: defaultReminderInterval,
+		before:   defaultReminderBefore,
+	}
+	if v, err := time.ParseDuration(os.Getenv("NOTIFICATION_REMINDER_INTERVAL")); err == nil && v > 0 {
+		reminder.interval = v
+	}
+	if v, err := time.ParseDuration(os.Getenv("NOTIFICATION_REMINDER_BEFORE")); err == nil && v > 0 {
+		reminder.before = v
+	}
+	return reminder
+}
+
+func (r *Reminder) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		n, err := r.repo.CreateDeadlineReminders(r.before)
+		if err != nil {
+			slog.Error("failed to create deadline reminders", "error", err)
+		} else if n > 0 {
+			slog.Info("created deadline reminders", "count", n)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
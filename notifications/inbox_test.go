@@ -0,0 +1,32 @@
+package notifications
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/noctusha/tender/connection"
+	"github.com/noctusha/tender/models"
+)
+
+func TestInboxPublish(t *testing.T) {
+	// The inbox has no repository, so only events it drops before storing anything can be published.
+	tests := []struct {
+		name    string
+		event   models.Event
+		wantErr bool
+	}{
+		{name: "tender event", event: models.Event{Type: connection.EventTenderAmended, Data: json.RawMessage(`{}`)}},
+		{name: "withdrawn bid", event: models.Event{Type: connection.EventBidWithdrawn, Data: json.RawMessage(`{}`)}},
+		{name: "undecodable bid", event: models.Event{Type: connection.EventBidApproved, Data: json.RawMessage(`[]`)}, wantErr: true},
+		{name: "undecodable new bid", event: models.Event{Type: connection.EventBidCreated, Data: json.RawMessage(`"bid"`)}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := NewInbox(nil).Publish(context.Background(), tt.event)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Publish() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
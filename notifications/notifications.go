@@ -0,0 +1,246 @@
+package notifications
+
+import (
+	"bytes"
+	"context"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"log/slog"
+	"strings"
+	texttemplate "text/template"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/noctusha/tender/connection"
+	"github.com/noctusha/tender/models"
+)
+
+//go:embed templates
+var templateFiles embed.FS
+
+// Languages lists the languages notifications are written in; the first one is the default.
+var Languages = []string{"ru", "en"}
+
+// notifiedEvents are the event types that have templates.
+var notifiedEvents = []string{
+	connection.EventTenderAmended,
+	connection.EventTenderClosed,
+	connection.EventTenderCancelled,
+	connection.EventBidCreated,
+}
+
+func IsLanguage(language string) bool {
+	for _, l := range Languages {
+		if l == language {
+			return true
+		}
+	}
+	return false
+}
+
+var templateFuncs = map[string]interface{}{
+	"join": strings.Join,
+	"date": func(t time.Time) string {
+		return t.UTC().Format("2006-01-02 15:04 UTC")
+	},
+}
+
+type templateData struct {
+	Recipient models.NotificationRecipient
+	Tender    models.Tender
+	Amendment *models.TenderAmendment
+	Bid       *models.Bid
+}
+
+// Notifier is an outbox sink that emails bidders when a tender they bid on is amended, closed
+// or cancelled, and tender owners when a bid arrives. Other events are ignored.
+type Notifier struct {
+	repo   *connection.Repository
+	sender Sender
+
+	text map[string]*texttemplate.Template
+	html map[string]*htmltemplate.Template
+}
+
+// NewNotifier parses the templates of every notified event in every language.
+func NewNotifier(repo *connection.Repository, sender Sender) (*Notifier, error) {
+	n := &Notifier{
+		repo:   repo,
+		sender: sender,
+		text:   make(map[string]*texttemplate.Template),
+		html:   make(map[string]*htmltemplate.Template),
+	}
+
+	for _, language := range Languages {
+		for _, eventType := range notifiedEvents {
+			name := language + "/" + eventType
+
+			text, err := texttemplate.New(eventType).Funcs(templateFuncs).ParseFS(templateFiles, "templates/"+name+".txt")
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse template %s: %w", name, err)
+			}
+			n.text[name] = text
+
+			html, err := htmltemplate.New(eventType).Funcs(templateFuncs).ParseFS(templateFiles, "templates/"+name+".html")
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse template %s: %w", name, err)
+			}
+			n.html[name] = html
+		}
+	}
+
+	return n, nil
+}
+
+func (n *Notifier) Name() string {
+	return "email"
+}
+
+// Publish emails everyone the event concerns who was not emailed about it yet. A failure for
+// one recipient does not stop the others, but fails the event, so the outbox sends it again to
+// the recipients it did not reach. Addresses the server refuses for good are logged and skipped.
+func (n *Notifier) Publish(ctx context.Context, event models.Event) error {
+	var (
+		data       templateData
+		recipients []models.NotificationRecipient
+		err        error
+	)
+
+	switch event.Type {
+	case connection.EventTenderAmended:
+		var amended struct {
+			Tender    models.Tender          `json:"tender"`
+			Amendment models.TenderAmendment `json:"amendment"`
+		}
+		err = decodeEventData(event, &amended)
+		if err != nil {
+			return err
+		}
+		data.Tender = amended.Tender
+		data.Amendment = &amended.Amendment
+		recipients, err = n.repo.TenderBidderRecipients(data.Tender.ID, event.Type)
+	case connection.EventTenderClosed, connection.EventTenderCancelled:
+		err = decodeEventData(event, &data.Tender)
+		if err != nil {
+			return err
+		}
+		recipients, err = n.repo.TenderBidderRecipients(data.Tender.ID, event.Type)
+	case connection.EventBidCreated:
+		data.Bid = &models.Bid{}
+		err = decodeEventData(event, data.Bid)
+		if err != nil {
+			return err
+		}
+		var (
+			tender *models.Tender
+			found  bool
+		)
+		tender, found, err = n.tender(data.Bid.TenderID)
+		if err != nil || !found {
+			return err
+		}
+		data.Tender = *tender
+		recipients, err = n.repo.OrganizationRecipients(data.Tender.OrganizationID, event.Type)
+	default:
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	delivered, err := n.repo.EmailDeliveries(event.ID)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, recipient := range recipients {
+		if delivered[recipient.Username] {
+			continue
+		}
+		data.Recipient = recipient
+
+		message, err := n.render(event.Type, data)
+		if err != nil {
+			return err
+		}
+		message.ID = event.ID + "." + recipient.Username
+
+		var failure string
+		err = n.sender.Send(ctx, message)
+		if err != nil {
+			if !permanent(err) {
+				errs = append(errs, err)
+				continue
+			}
+			slog.Warn("dropped email notification", "event", event.ID, "recipient", recipient.Username, "error", err)
+			failure = err.Error()
+		}
+
+		err = n.repo.RecordEmailDelivery(event.ID, recipient.Username, failure)
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (n *Notifier) tender(tenderID string) (*models.Tender, bool, error) {
+	id, err := uuid.Parse(tenderID)
+	if err != nil {
+		return nil, false, fmt.Errorf("invalid tender id %q: %w", tenderID, err)
+	}
+	return n.repo.GetTenderByID(id)
+}
+
+func (n *Notifier) render(eventType string, data templateData) (Message, error) {
+	language := data.Recipient.Language
+	if !IsLanguage(language) {
+		language = Languages[0]
+	}
+	name := language + "/" + eventType
+
+	var subject, text, html bytes.Buffer
+
+	err := n.text[name].ExecuteTemplate(&subject, "subject", data)
+	if err != nil {
+		return Message{}, fmt.Errorf("failed to render template %s: %w", name, err)
+	}
+	err = n.text[name].ExecuteTemplate(&text, "body", data)
+	if err != nil {
+		return Message{}, fmt.Errorf("failed to render template %s: %w", name, err)
+	}
+	err = n.html[name].ExecuteTemplate(&html, "body", data)
+	if err != nil {
+		return Message{}, fmt.Errorf("failed to render template %s: %w", name, err)
+	}
+
+	return Message{
+		To:      data.Recipient.Email,
+		Subject: strings.TrimSpace(subject.String()),
+		Text:    strings.TrimSpace(text.String()) + "\n",
+		HTML:    strings.TrimSpace(html.String()) + "\n",
+	}, nil
+}
+
+// decodeEventData reads the data of an event claimed from the outbox, or of one built in process.
+func decodeEventData(event models.Event, v interface{}) error {
+	raw, ok := event.Data.(json.RawMessage)
+	if !ok {
+		var err error
+		raw, err = json.Marshal(event.Data)
+		if err != nil {
+			return fmt.Errorf("failed to encode %s event: %w", event.Type, err)
+		}
+	}
+
+	err := json.Unmarshal(raw, v)
+	if err != nil {
+		return fmt.Errorf("failed to decode %s event: %w", event.Type, err)
+	}
+	return nil
+}
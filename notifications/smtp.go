@@ -0,0 +1,138 @@
+package notifications
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"os"
+	"time"
+)
+
+// Message is a rendered notification with plain text and HTML alternatives.
+type Message struct {
+	// ID becomes the Message-ID, so a notification sent again for the same event can be recognized.
+	ID      string
+	To      string
+	Subject string
+	Text    string
+	HTML    string
+}
+
+type Sender interface {
+	Send(ctx context.Context, message Message) error
+}
+
+// SMTPSender sends messages through an SMTP server. Any local fake server, such as MailHog
+// on port 1025, is enough to try notifications out.
+type SMTPSender struct {
+	host     string
+	port     string
+	username string
+	password string
+	from     string
+}
+
+// NewSMTPSender reads SMTP_HOST, SMTP_PORT, SMTP_USERNAME, SMTP_PASSWORD and SMTP_FROM.
+// Without a username the server is used without authentication.
+func NewSMTPSender() *SMTPSender {
+	sender := &SMTPSender{
+		host:     os.Getenv("SMTP_HOST"),
+		port:     os.Getenv("SMTP_PORT"),
+		username: os.Getenv("SMTP_USERNAME"),
+		password: os.Getenv("SMTP_PASSWORD"),
+		from:     os.Getenv("SMTP_FROM"),
+	}
+	if sender.host == "" {
+		sender.host = "localhost"
+	}
+	if sender.port == "" {
+		sender.port = "1025"
+	}
+	if sender.from == "" {
+		sender.from = "tender@localhost"
+	}
+	return sender
+}
+
+// permanent reports whether the server refused a message with a 5xx reply, such as an unknown
+// mailbox, so sending it again cannot succeed.
+func permanent(err error) bool {
+	var reply *textproto.Error
+	return errors.As(err, &reply) && reply.Code >= 500
+}
+
+func (s *SMTPSender) Send(_ context.Context, message Message) error {
+	body, err := s.format(message)
+	if err != nil {
+		return err
+	}
+
+	var auth smtp.Auth
+	if s.username != "" {
+		auth = smtp.PlainAuth("", s.username, s.password, s.host)
+	}
+
+	err = smtp.SendMail(net.JoinHostPort(s.host, s.port), auth, s.from, []string{message.To}, body)
+	if err != nil {
+		return fmt.Errorf("failed to send email to %s: %w", message.To, err)
+	}
+	return nil
+}
+
+// format builds a multipart/alternative message with quoted-printable text and HTML parts.
+func (s *SMTPSender) format(message Message) ([]byte, error) {
+	var parts bytes.Buffer
+	writer := multipart.NewWriter(&parts)
+
+	for _, part := range []struct {
+		contentType string
+		content     string
+	}{
+		{"text/plain; charset=UTF-8", message.Text},
+		{"text/html; charset=UTF-8", message.HTML},
+	} {
+		w, err := writer.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to build email: %w", err)
+		}
+
+		qp := quotedprintable.NewWriter(w)
+		_, err = qp.Write([]byte(part.content))
+		if err != nil {
+			return nil, fmt.Errorf("failed to build email: %w", err)
+		}
+		err = qp.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to build email: %w", err)
+		}
+	}
+
+	err := writer.Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build email: %w", err)
+	}
+
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", s.from)
+	fmt.Fprintf(&msg, "To: %s\r\n", message.To)
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("UTF-8", message.Subject))
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	if message.ID != "" {
+		fmt.Fprintf(&msg, "Message-ID: <%s@%s>\r\n", message.ID, s.host)
+	}
+	fmt.Fprintf(&msg, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&msg, "Content-Type: multipart/alternative; boundary=%q\r\n\r\n", writer.Boundary())
+	msg.Write(parts.Bytes())
+
+	return msg.Bytes(), nil
+}
@@ -0,0 +1,235 @@
+package notifications
+
+import (
+	"context"
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeSMTP is a minimal SMTP server: it accepts mail for any recipient except those it was told
+// to refuse, with the reply code given for them, and keeps what it received.
+type fakeSMTP struct {
+	listener net.Listener
+	refused  map[string]int
+
+	mu       sync.Mutex
+	received []receivedMail
+}
+
+type receivedMail struct {
+	from string
+	to   []string
+	data []byte
+}
+
+func newFakeSMTP(t *testing.T, refused map[string]int) *fakeSMTP {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	f := &fakeSMTP{listener: listener, refused: refused}
+	go f.serve()
+	return f
+}
+
+func (f *fakeSMTP) sender() *SMTPSender {
+	host, port, _ := net.SplitHostPort(f.listener.Addr().String())
+	return &SMTPSender{host: host, port: port, from: "tender@localhost"}
+}
+
+func (f *fakeSMTP) serve() {
+	for {
+		conn, err := f.listener.Accept()
+		if err != nil {
+			return
+		}
+		go f.handle(textproto.NewConn(conn))
+	}
+}
+
+func (f *fakeSMTP) handle(conn *textproto.Conn) {
+	defer conn.Close()
+
+	var current receivedMail
+	conn.PrintfLine("220 fake ESMTP")
+	for {
+		line, err := conn.ReadLine()
+		if err != nil {
+			return
+		}
+		verb, arg, _ := strings.Cut(line, " ")
+
+		switch strings.ToUpper(verb) {
+		case "EHLO", "HELO":
+			conn.PrintfLine("250 fake")
+		case "MAIL":
+			current = receivedMail{from: addressOf(arg)}
+			conn.PrintfLine("250 OK")
+		case "RCPT":
+			to := addressOf(arg)
+			if code, ok := f.refused[to]; ok {
+				conn.PrintfLine("%d mailbox %s unavailable", code, to)
+				continue
+			}
+			current.to = append(current.to, to)
+			conn.PrintfLine("250 OK")
+		case "DATA":
+			conn.PrintfLine("354 end data with <CR><LF>.<CR><LF>")
+			current.data, err = conn.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			f.mu.Lock()
+			f.received = append(f.received, current)
+			f.mu.Unlock()
+			conn.PrintfLine("250 OK")
+		case "RSET", "NOOP":
+			conn.PrintfLine("250 OK")
+		case "QUIT":
+			conn.PrintfLine("221 bye")
+			return
+		default:
+			conn.PrintfLine("502 command not implemented")
+		}
+	}
+}
+
+func (f *fakeSMTP) mails() []receivedMail {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]receivedMail(nil), f.received...)
+}
+
+// addressOf reads the address of a "FROM:<address>" or "TO:<address>" argument.
+func addressOf(arg string) string {
+	_, address, _ := strings.Cut(arg, "<")
+	address, _, _ = strings.Cut(address, ">")
+	return address
+}
+
+func TestSMTPSenderSend(t *testing.T) {
+	server := newFakeSMTP(t, nil)
+
+	message := Message{
+		ID:      "6f1c1a52-4a5e-4a7e-9d57-1f1f1f1f1f1f.user123",
+		To:      "user123@example.com",
+		Subject: "Тендер «Поставка бумаги» изменён",
+		Text:    "Срок подачи предложений перенесён.\n",
+		HTML:    "<p>Срок подачи предложений перенесён.</p>\n",
+	}
+	err := server.sender().Send(context.Background(), message)
+	if err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	mails := server.mails()
+	if len(mails) != 1 {
+		t.Fatalf("received %d emails, want 1", len(mails))
+	}
+	received := mails[0]
+	if received.from != "tender@localhost" || len(received.to) != 1 || received.to[0] != message.To {
+		t.Errorf("envelope = %s -> %v, want tender@localhost -> [%s]", received.from, received.to, message.To)
+	}
+
+	msg, err := mail.ReadMessage(strings.NewReader(string(received.data)))
+	if err != nil {
+		t.Fatalf("read message: %v", err)
+	}
+
+	subject, err := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	if err != nil || subject != message.Subject {
+		t.Errorf("Subject = %q (%v), want %q", subject, err, message.Subject)
+	}
+	if got, want := msg.Header.Get("Message-ID"), "<"+message.ID+"@127.0.0.1>"; got != want {
+		t.Errorf("Message-ID = %q, want %q", got, want)
+	}
+	if got := msg.Header.Get("To"); got != message.To {
+		t.Errorf("To = %q, want %q", got, message.To)
+	}
+
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/alternative" {
+		t.Fatalf("Content-Type = %q (%v), want multipart/alternative", msg.Header.Get("Content-Type"), err)
+	}
+
+	want := []struct {
+		contentType string
+		content     string
+	}{
+		{"text/plain; charset=UTF-8", message.Text},
+		{"text/html; charset=UTF-8", message.HTML},
+	}
+	reader := multipart.NewReader(msg.Body, params["boundary"])
+	for _, w := range want {
+		part, err := reader.NextPart()
+		if err != nil {
+			t.Fatalf("next part: %v", err)
+		}
+		if got := part.Header.Get("Content-Type"); got != w.contentType {
+			t.Errorf("part Content-Type = %q, want %q", got, w.contentType)
+		}
+		// NextPart decodes quoted-printable parts.
+		content, err := io.ReadAll(part)
+		if err != nil {
+			t.Fatalf("read part: %v", err)
+		}
+		if strings.ReplaceAll(string(content), "\r\n", "\n") != w.content {
+			t.Errorf("part %s = %q, want %q", w.contentType, content, w.content)
+		}
+	}
+	if _, err := reader.NextPart(); !errors.Is(err, io.EOF) {
+		t.Errorf("unexpected part after text and HTML: %v", err)
+	}
+}
+
+func TestSMTPSenderFailures(t *testing.T) {
+	server := newFakeSMTP(t, map[string]int{
+		"unknown@example.com": 550,
+		"full@example.com":    452,
+	})
+
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	host, port, _ := net.SplitHostPort(closed.Addr().String())
+	closed.Close()
+
+	tests := []struct {
+		name          string
+		sender        *SMTPSender
+		to            string
+		wantPermanent bool
+	}{
+		{name: "unknown mailbox", sender: server.sender(), to: "unknown@example.com", wantPermanent: true},
+		{name: "mailbox full", sender: server.sender(), to: "full@example.com", wantPermanent: false},
+		{name: "server down", sender: &SMTPSender{host: host, port: port, from: "tender@localhost"}, to: "user123@example.com", wantPermanent: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.sender.Send(context.Background(), Message{To: tt.to, Subject: "subject", Text: "text", HTML: "html"})
+			if err == nil {
+				t.Fatal("Send succeeded, want an error")
+			}
+			if got := permanent(err); got != tt.wantPermanent {
+				t.Errorf("permanent(%v) = %v, want %v", err, got, tt.wantPermanent)
+			}
+		})
+	}
+
+	if mails := server.mails(); len(mails) != 0 {
+		t.Errorf("received %d emails, want none", len(mails))
+	}
+}
@@ -0,0 +1,95 @@
+// Package openapi holds the OpenAPI 3 document of the API, the contract that requests are
+// validated against.
+package openapi
+
+import (
+	"context"
+	_ "embed"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+//go:embed openapi.json
+var document []byte
+
+// Document returns the OpenAPI document as served to clients.
+func Document() []byte {
+	return document
+}
+
+// Load parses and validates the OpenAPI document.
+func Load() (*openapi3.T, error) {
+	openapi3.DefineStringFormatCallback("uuid", func(value string) error {
+		_, err := uuid.Parse(value)
+		return err
+	})
+
+	doc, err := openapi3.NewLoader().LoadFromData(document)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load openapi document: %w", err)
+	}
+
+	err = doc.Validate(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("invalid openapi document: %w", err)
+	}
+
+	return doc, nil
+}
+
+// CheckRoutes reports the routes of router that the document does not describe, so a route
+// cannot be added without its contract.
+func CheckRoutes(doc *openapi3.T, router *mux.Router) error {
+	var missing []string
+	err := router.Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+		path, err := route.GetPathTemplate()
+		if err != nil {
+			return nil
+		}
+
+		methods, err := route.GetMethods()
+		if err != nil {
+			return nil
+		}
+
+		item := doc.Paths.Find(path)
+		for _, method := range methods {
+			if item == nil || item.GetOperation(method) == nil {
+				missing = append(missing, method+" "+path)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to walk routes: %w", err)
+	}
+
+	if len(missing) > 0 {
+		return fmt.Errorf("routes missing from openapi document: %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// Operation finds the operation of the document serving a request routed by mux.
+func Operation(doc *openapi3.T, r *http.Request) (string, *openapi3.PathItem, *openapi3.Operation) {
+	route := mux.CurrentRoute(r)
+	if route == nil {
+		return "", nil, nil
+	}
+
+	path, err := route.GetPathTemplate()
+	if err != nil {
+		return "", nil, nil
+	}
+
+	item := doc.Paths.Find(path)
+	if item == nil {
+		return path, nil, nil
+	}
+	return path, item, item.GetOperation(r.Method)
+}
@@ -0,0 +1,152 @@
+package outbox
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/noctusha/tender/connection"
+	"github.com/noctusha/tender/internal/worker"
+	"github.com/noctusha/tender/models"
+)
+
+// Sink receives published domain events. Delivery is at least once: an event is offered
+// again until every sink has accepted it, so sinks use the event id to drop duplicates.
+type Sink interface {
+	Name() string
+	Publish(ctx context.Context, event models.Event) error
+}
+
+const (
+	defaultPollInterval = 2 * time.Second
+	baseRetryDelay      = 5 * time.Second
+	maxRetryDelay       = 10 * time.Minute
+	claimBatch          = 50
+	claimLease          = time.Minute
+
+	defaultSinks = "webhook,channel,inbox"
+	emailSink    = "email"
+)
+
+// Events are never given up on: the sinks are ours, so a failure is an outage to wait out.
+var retryBackoff = worker.Backoff{Base: baseRetryDelay, Max: maxRetryDelay}
+
+// Dispatcher publishes the events recorded in the outbox to the sinks.
+type Dispatcher struct {
+	repo  *connection.Repository
+	sinks []Sink
+	loop  *worker.Loop
+}
+
+// NewDispatcher reads OUTBOX_POLL_INTERVAL (a Go duration such as "2s").
+func NewDispatcher(repo *connection.Repository, sinks ...Sink) *Dispatcher {
+	pollInterval := defaultPollInterval
+	if v, err := time.ParseDuration(os.Getenv("OUTBOX_POLL_INTERVAL")); err == nil && v > 0 {
+		pollInterval = v
+	}
+
+	return &Dispatcher{
+		repo:  repo,
+		sinks: sinks,
+		loop:  worker.NewLoop(pollInterval),
+	}
+}
+
+// SelectSinks picks the sinks named in OUTBOX_SINKS, a comma-separated list of sink names, out of
+// the available ones. By default these are "webhook,channel,inbox", and "email", which sends
+// notifications, once SMTP_HOST names a mail server.
+func SelectSinks(available ...Sink) ([]Sink, error) {
+	names := os.Getenv("OUTBOX_SINKS")
+	if names == "" {
+		names = defaultSinks
+		if os.Getenv("SMTP_HOST") != "" {
+			names += "," + emailSink
+		}
+	}
+
+	sinks := []Sink{}
+	for _, name := range strings.Split(names, ",") {
+		name = strings.TrimSpace(name)
+		found := false
+		for _, sink := range available {
+			if sink.Name() == name {
+				sinks = append(sinks, sink)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown outbox sink: %s", name)
+		}
+	}
+	return sinks, nil
+}
+
+// Wake makes the dispatcher look for new events without waiting for the next poll.
+// Handlers call it after a change that recorded events.
+func (d *Dispatcher) Wake() {
+	d.loop.Wake()
+}
+
+// Run publishes events until the context is cancelled.
+func (d *Dispatcher) Run(ctx context.Context) {
+	d.loop.Run(ctx, d.publishDue)
+}
+
+func (d *Dispatcher) publishDue(ctx context.Context) {
+	for {
+		events, err := d.repo.ClaimOutboxEvents(claimBatch, claimLease)
+		if err != nil {
+			slog.Error("failed to claim outbox events", "error", err)
+			return
+		}
+
+		for _, event := range events {
+			if ctx.Err() != nil {
+				return
+			}
+			d.publish(ctx, event)
+		}
+
+		if len(events) < claimBatch {
+			return
+		}
+	}
+}
+
+func (d *Dispatcher) publish(ctx context.Context, event connection.OutboxEvent) {
+	var failures []string
+	for _, sink := range d.sinks {
+		if slices.Contains(event.PublishedSinks, sink.Name()) {
+			continue
+		}
+
+		err := sink.Publish(ctx, event.Event)
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %v", sink.Name(), err))
+			continue
+		}
+
+		err = d.repo.MarkOutboxSinkPublished(event.Event.ID, sink.Name())
+		if err != nil {
+			slog.Error("failed to mark outbox sink published", "event_id", event.Event.ID, "sink", sink.Name(), "error", err)
+		}
+	}
+
+	if len(failures) == 0 {
+		err := d.repo.MarkOutboxEventPublished(event.Event.ID)
+		if err != nil {
+			slog.Error("failed to mark outbox event published", "event_id", event.Event.ID, "error", err)
+		}
+		return
+	}
+
+	err := d.repo.MarkOutboxEventFailed(event.Event.ID, strings.Join(failures, "; "), time.Now().Add(retryBackoff.Delay(event.Attempts+1)))
+	if err != nil {
+		slog.Error("failed to mark outbox event failed", "event_id", event.Event.ID, "error", err)
+	}
+}
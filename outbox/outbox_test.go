@@ -0,0 +1,58 @@
+package outbox
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/noctusha/tender/models"
+)
+
+type namedSink string
+
+func (s namedSink) Name() string {
+	return string(s)
+}
+
+func (namedSink) Publish(context.Context, models.Event) error {
+	return nil
+}
+
+func TestSelectSinks(t *testing.T) {
+	available := []Sink{LogSink{}, namedSink("webhook"), namedSink("channel"), namedSink("email"), namedSink("inbox")}
+
+	tests := []struct {
+		name     string
+		sinks    string
+		smtpHost string
+		want     []string
+		wantErr  bool
+	}{
+		{name: "default", want: []string{"webhook", "channel", "inbox"}},
+		{name: "default with smtp", smtpHost: "mail.example.com", want: []string{"webhook", "channel", "inbox", "email"}},
+		{name: "listed", sinks: "log, inbox", smtpHost: "mail.example.com", want: []string{"log", "inbox"}},
+		{name: "unknown", sinks: "webhook,sms", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("OUTBOX_SINKS", tt.sinks)
+			t.Setenv("SMTP_HOST", tt.smtpHost)
+
+			sinks, err := SelectSinks(available...)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("SelectSinks() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			names := []string{}
+			for _, sink := range sinks {
+				names = append(names, sink.Name())
+			}
+			if !reflect.DeepEqual(names, tt.want) {
+				t.Errorf("SelectSinks() = %v, want %v", names, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,71 @@
+package outbox
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+
+	"github.com/noctusha/tender/models"
+)
+
+// LogSink writes every event to the default logger.
+type LogSink struct{}
+
+func (LogSink) Name() string {
+	return "log"
+}
+
+func (LogSink) Publish(_ context.Context, event models.Event) error {
+	slog.Info("event", "event_id", event.ID, "sequence", event.Sequence, "type", event.Type, "organization_ids", event.OrganizationIDs)
+	return nil
+}
+
+const subscriberBuffer = 64
+
+// Broker fans events out to in-process subscribers. A subscriber that does not keep up
+// loses events rather than holding up the others, and can catch up from the outbox.
+type Broker struct {
+	mu          sync.Mutex
+	subscribers map[chan models.Event]struct{}
+}
+
+func NewBroker() *Broker {
+	return &Broker{subscribers: make(map[chan models.Event]struct{})}
+}
+
+func (b *Broker) Name() string {
+	return "channel"
+}
+
+func (b *Broker) Publish(_ context.Context, event models.Event) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for ch := range b.subscribers {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+	return nil
+}
+
+// Subscribe returns a channel receiving every event published from now on and a function
+// that ends the subscription and closes the channel.
+func (b *Broker) Subscribe() (<-chan models.Event, func()) {
+	ch := make(chan models.Event, subscriberBuffer)
+
+	b.mu.Lock()
+	b.subscribers[ch] = struct{}{}
+	b.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			b.mu.Lock()
+			delete(b.subscribers, ch)
+			b.mu.Unlock()
+			close(ch)
+		})
+	}
+}
@@ -0,0 +1,81 @@
+package receipts
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+
+	"github.com/noctusha/tender/models"
+)
+
+// Signer signs the receipts handed to bidders, so a bidder can prove what the service sealed.
+type Signer struct {
+	key ed25519.PrivateKey
+}
+
+// NewSigner reads RECEIPT_SIGNING_KEY, a base64 Ed25519 seed (32 bytes) or private key (64 bytes).
+// Without it a key is generated, and receipts signed before a restart can no longer be checked
+// against the published public key.
+func NewSigner() (*Signer, error) {
+	encoded := os.Getenv("RECEIPT_SIGNING_KEY")
+	if encoded == "" {
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate receipt signing key: %w", err)
+		}
+		slog.Warn("RECEIPT_SIGNING_KEY is not set, receipts are signed with a temporary key")
+		return &Signer{key: key}, nil
+	}
+
+	raw, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode RECEIPT_SIGNING_KEY: %w", err)
+	}
+
+	switch len(raw) {
+	case ed25519.SeedSize:
+		return &Signer{key: ed25519.NewKeyFromSeed(raw)}, nil
+	case ed25519.PrivateKeySize:
+		return &Signer{key: ed25519.PrivateKey(raw)}, nil
+	default:
+		return nil, fmt.Errorf("invalid RECEIPT_SIGNING_KEY: expected %d or %d bytes, got %d", ed25519.SeedSize, ed25519.PrivateKeySize, len(raw))
+	}
+}
+
+func (s *Signer) PublicKey() ed25519.PublicKey {
+	return s.key.Public().(ed25519.PublicKey)
+}
+
+// Receipt is the signed receipt of a sealed version of a bid.
+func (s *Signer) Receipt(seal *models.BidSeal) (models.BidReceipt, error) {
+	receipt := models.BidReceipt{
+		BidID:    seal.BidID,
+		TenderID: seal.TenderID,
+		Sequence: seal.Sequence,
+		Hash:     seal.Hash,
+		SealedAt: seal.CreatedAt,
+	}
+
+	payload, err := Payload(receipt)
+	if err != nil {
+		return receipt, err
+	}
+
+	receipt.Signature = base64.StdEncoding.EncodeToString(ed25519.Sign(s.key, payload))
+	return receipt, nil
+}
+
+// Payload is what the signature of a receipt covers: the receipt as compact JSON, without the
+// signature, with the fields in the order bidId, tenderId, sequence, hash, sealedAt.
+func Payload(receipt models.BidReceipt) ([]byte, error) {
+	receipt.Signature = ""
+	payload, err := json.Marshal(receipt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode receipt: %w", err)
+	}
+	return payload, nil
+}
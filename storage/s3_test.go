@@ -0,0 +1,230 @@
+package storage
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+const (
+	testBucket    = "attachments"
+	testAccessKey = "AKIDEXAMPLE"
+	testSecretKey = "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY"
+	testRegion    = "eu-central-1"
+)
+
+// fakeS3 stands in for an S3-compatible service: it keeps objects in memory and rejects requests
+// whose signature does not match the secret key.
+type fakeS3 struct {
+	mu      sync.Mutex
+	objects map[string]fakeObject
+}
+
+type fakeObject struct {
+	body        string
+	contentType string
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !validSignature(r) {
+		http.Error(w, "<Error><Code>SignatureDoesNotMatch</Code></Error>", http.StatusForbidden)
+		return
+	}
+
+	key, ok := strings.CutPrefix(r.URL.Path, "/"+testBucket+"/")
+	if !ok {
+		http.Error(w, "<Error><Code>NoSuchBucket</Code></Error>", http.StatusNotFound)
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	switch r.Method {
+	case http.MethodPut:
+		body, _ := io.ReadAll(r.Body)
+		if int64(len(body)) != r.ContentLength {
+			http.Error(w, "<Error><Code>IncompleteBody</Code></Error>", http.StatusBadRequest)
+			return
+		}
+		f.objects[key] = fakeObject{body: string(body), contentType: r.Header.Get("Content-Type")}
+	case http.MethodGet:
+		object, ok := f.objects[key]
+		if !ok {
+			http.Error(w, "<Error><Code>NoSuchKey</Code></Error>", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", object.contentType)
+		io.WriteString(w, object.body)
+	case http.MethodDelete:
+		delete(f.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// validSignature checks the Signature Version 4 of a request the way S3 does, from the headers
+// it received.
+func validSignature(r *http.Request) bool {
+	amzDate := r.Header.Get("x-amz-date")
+	if len(amzDate) != len("20060102T150405Z") || r.Header.Get("x-amz-content-sha256") != unsignedPayload {
+		return false
+	}
+	date := amzDate[:8]
+	scope := date + "/" + testRegion + "/s3/aws4_request"
+
+	canonicalRequest := r.Method + "\n" + r.URL.EscapedPath() + "\n" + r.URL.RawQuery + "\n" +
+		"host:" + r.Host + "\n" + "x-amz-content-sha256:" + unsignedPayload + "\n" + "x-amz-date:" + amzDate + "\n\n" +
+		"host;x-amz-content-sha256;x-amz-date\n" + unsignedPayload
+	hashed := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(hashed[:])
+
+	key := []byte("AWS4" + testSecretKey)
+	for _, part := range []string{date, testRegion, "s3", "aws4_request"} {
+		mac := hmac.New(sha256.New, key)
+		mac.Write([]byte(part))
+		key = mac.Sum(nil)
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(stringToSign))
+
+	expected := "AWS4-HMAC-SHA256 Credential=" + testAccessKey + "/" + scope +
+		", SignedHeaders=host;x-amz-content-sha256;x-amz-date, Signature=" + hex.EncodeToString(mac.Sum(nil))
+	return r.Header.Get("Authorization") == expected
+}
+
+func newTestS3Storage(t *testing.T, secretKey string) (*S3Storage, *fakeS3) {
+	t.Helper()
+
+	fake := &fakeS3{objects: map[string]fakeObject{}}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	s, err := NewS3Storage(S3Config{
+		Endpoint:        server.URL,
+		Region:          testRegion,
+		Bucket:          testBucket,
+		AccessKeyID:     testAccessKey,
+		SecretAccessKey: secretKey,
+	})
+	if err != nil {
+		t.Fatalf("NewS3Storage: %v", err)
+	}
+	return s, fake
+}
+
+func TestS3StorageRoundTrip(t *testing.T) {
+	s, fake := newTestS3Storage(t, testSecretKey)
+	ctx := context.Background()
+
+	tests := []struct {
+		name        string
+		key         string
+		body        string
+		contentType string
+	}{
+		{name: "text", key: "attachments/1", body: "technical specification", contentType: "text/plain"},
+		{name: "binary", key: "attachments/2", body: "%PDF-1.7\x00\x01\x02", contentType: "application/pdf"},
+		{name: "key with spaces", key: "attachments/terms of reference.txt", body: "scope", contentType: "text/plain"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := s.Put(ctx, tt.key, strings.NewReader(tt.body), int64(len(tt.body)), tt.contentType)
+			if err != nil {
+				t.Fatalf("Put: %v", err)
+			}
+			if got := fake.objects[tt.key].contentType; got != tt.contentType {
+				t.Errorf("stored content type = %q, want %q", got, tt.contentType)
+			}
+
+			body, err := s.Get(ctx, tt.key)
+			if err != nil {
+				t.Fatalf("Get: %v", err)
+			}
+			data, err := io.ReadAll(body)
+			body.Close()
+			if err != nil {
+				t.Fatalf("read body: %v", err)
+			}
+			if string(data) != tt.body {
+				t.Errorf("Get = %q, want %q", data, tt.body)
+			}
+
+			err = s.Delete(ctx, tt.key)
+			if err != nil {
+				t.Fatalf("Delete: %v", err)
+			}
+
+			_, err = s.Get(ctx, tt.key)
+			if !errors.Is(err, ErrNotFound) {
+				t.Errorf("Get after Delete = %v, want ErrNotFound", err)
+			}
+		})
+	}
+}
+
+func TestS3StorageErrors(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("missing object", func(t *testing.T) {
+		s, _ := newTestS3Storage(t, testSecretKey)
+
+		_, err := s.Get(ctx, "attachments/missing")
+		if !errors.Is(err, ErrNotFound) {
+			t.Errorf("Get = %v, want ErrNotFound", err)
+		}
+
+		err = s.Delete(ctx, "attachments/missing")
+		if err != nil {
+			t.Errorf("Delete = %v, want nil", err)
+		}
+	})
+
+	t.Run("wrong secret key", func(t *testing.T) {
+		s, _ := newTestS3Storage(t, "not-the-secret")
+
+		err := s.Put(ctx, "attachments/1", strings.NewReader("body"), 4, "text/plain")
+		if err == nil || !strings.Contains(err.Error(), "status 403") {
+			t.Errorf("Put = %v, want a 403 error", err)
+		}
+
+		_, err = s.Get(ctx, "attachments/1")
+		if err == nil || errors.Is(err, ErrNotFound) {
+			t.Errorf("Get = %v, want a request error", err)
+		}
+	})
+}
+
+func TestNewS3StorageConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     S3Config
+		wantErr bool
+	}{
+		{name: "complete", cfg: S3Config{Endpoint: "http://minio:9000", Bucket: "b", AccessKeyID: "a", SecretAccessKey: "s"}},
+		{name: "no endpoint", cfg: S3Config{Bucket: "b", AccessKeyID: "a", SecretAccessKey: "s"}, wantErr: true},
+		{name: "no bucket", cfg: S3Config{Endpoint: "http://minio:9000", AccessKeyID: "a", SecretAccessKey: "s"}, wantErr: true},
+		{name: "no credentials", cfg: S3Config{Endpoint: "http://minio:9000", Bucket: "b"}, wantErr: true},
+		{name: "endpoint without host", cfg: S3Config{Endpoint: "minio", Bucket: "b", AccessKeyID: "a", SecretAccessKey: "s"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := NewS3Storage(tt.cfg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewS3Storage error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && s.cfg.Region != "us-east-1" {
+				t.Errorf("default region = %q, want us-east-1", s.cfg.Region)
+			}
+		})
+	}
+}
@@ -0,0 +1,40 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+var ErrNotFound = errors.New("blob not found")
+
+// BlobStorage keeps attachment contents outside of the database, addressed by a key.
+type BlobStorage interface {
+	Put(ctx context.Context, key string, body io.Reader, size int64, contentType string) error
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// NewBlobStorage picks the implementation configured by ATTACHMENT_STORAGE: "local" (default) or "s3".
+func NewBlobStorage() (BlobStorage, error) {
+	switch kind := os.Getenv("ATTACHMENT_STORAGE"); kind {
+	case "", "local":
+		dir := os.Getenv("ATTACHMENT_DIR")
+		if dir == "" {
+			dir = "attachments"
+		}
+		return NewLocalStorage(dir)
+	case "s3":
+		return NewS3Storage(S3Config{
+			Endpoint:        os.Getenv("S3_ENDPOINT"),
+			Region:          os.Getenv("S3_REGION"),
+			Bucket:          os.Getenv("S3_BUCKET"),
+			AccessKeyID:     os.Getenv("S3_ACCESS_KEY_ID"),
+			SecretAccessKey: os.Getenv("S3_SECRET_ACCESS_KEY"),
+		})
+	default:
+		return nil, fmt.Errorf("unknown attachment storage: %s", kind)
+	}
+}
@@ -0,0 +1,99 @@
+package webhooks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"net/url"
+	"syscall"
+)
+
+var (
+	ErrInvalidURL     = errors.New("webhook url must be an absolute http or https URL")
+	ErrPrivateAddress = errors.New("webhook url must resolve to public addresses only")
+)
+
+// reservedPrefixes are ranges that are neither private nor public internet addresses but may
+// still reach the network of the service: "this network", shared address space of carrier-grade
+// NAT, IETF protocol assignments, benchmarking, reserved, and NAT64 to any of them.
+var reservedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+	netip.MustParsePrefix("64:ff9b::/96"),
+	netip.MustParsePrefix("64:ff9b:1::/48"),
+}
+
+// PublicAddress reports whether webhooks may be posted to addr. Loopback, private, link-local
+// (the cloud metadata service at 169.254.169.254 among them), multicast and reserved addresses
+// are refused, so a subscription cannot make the service call into its own network.
+func PublicAddress(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if !addr.IsGlobalUnicast() || addr.IsPrivate() {
+		return false
+	}
+
+	for _, prefix := range reservedPrefixes {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
+
+// CheckURL checks that a webhook URL is an absolute http or https URL whose host resolves to
+// public addresses only. The dispatcher checks the address again when it connects, since the
+// host may resolve differently by then.
+func CheckURL(ctx context.Context, rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil || !u.IsAbs() || u.Hostname() == "" {
+		return ErrInvalidURL
+	}
+
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return ErrInvalidURL
+	}
+
+	addrs, err := resolve(ctx, u.Hostname())
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidURL, err)
+	}
+
+	for _, addr := range addrs {
+		if !PublicAddress(addr) {
+			return ErrPrivateAddress
+		}
+	}
+	return nil
+}
+
+func resolve(ctx context.Context, host string) ([]netip.Addr, error) {
+	if addr, err := netip.ParseAddr(host); err == nil {
+		return []netip.Addr{addr}, nil
+	}
+
+	addrs, err := net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve %s: %w", host, err)
+	}
+	return addrs, nil
+}
+
+// dialControl refuses connections to addresses that are not public. It runs after the host of a
+// webhook has been resolved, so a name that resolved to a public address when the subscription
+// was saved cannot be pointed at the network of the service later.
+func dialControl(_ string, address string, _ syscall.RawConn) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return fmt.Errorf("invalid address %s: %w", address, err)
+	}
+
+	if !PublicAddress(addrPort.Addr()) {
+		return fmt.Errorf("%w: %s", ErrPrivateAddress, addrPort.Addr())
+	}
+	return nil
+}
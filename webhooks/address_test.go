@@ -0,0 +1,88 @@
+package webhooks
+
+import (
+	"context"
+	"errors"
+	"net/netip"
+	"testing"
+)
+
+func TestPublicAddress(t *testing.T) {
+	tests := []struct {
+		addr string
+		want bool
+	}{
+		{addr: "93.184.216.34", want: true},
+		{addr: "2606:2800:220:1:248:1893:25c8:1946", want: true},
+		{addr: "127.0.0.1", want: false},
+		{addr: "::1", want: false},
+		{addr: "10.1.2.3", want: false},
+		{addr: "172.16.0.1", want: false},
+		{addr: "192.168.1.10", want: false},
+		{addr: "fd00::1", want: false},
+		{addr: "169.254.169.254", want: false},
+		{addr: "fe80::1", want: false},
+		{addr: "0.0.0.0", want: false},
+		{addr: "0.1.2.3", want: false},
+		{addr: "100.64.0.1", want: false},
+		{addr: "224.0.0.1", want: false},
+		{addr: "255.255.255.255", want: false},
+		{addr: "::ffff:127.0.0.1", want: false},
+		{addr: "::ffff:169.254.169.254", want: false},
+		{addr: "64:ff9b::a9fe:a9fe", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.addr, func(t *testing.T) {
+			if got := PublicAddress(netip.MustParseAddr(tt.addr)); got != tt.want {
+				t.Errorf("PublicAddress(%s) = %v, want %v", tt.addr, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckURL(t *testing.T) {
+	tests := []struct {
+		url  string
+		want error
+	}{
+		{url: "https://93.184.216.34/hooks", want: nil},
+		{url: "http://[2606:2800:220:1:248:1893:25c8:1946]:8080/hooks", want: nil},
+		{url: "http://127.0.0.1:8080/hooks", want: ErrPrivateAddress},
+		{url: "http://169.254.169.254/latest/meta-data/", want: ErrPrivateAddress},
+		{url: "http://[::1]/hooks", want: ErrPrivateAddress},
+		{url: "https://10.0.0.5/hooks", want: ErrPrivateAddress},
+		{url: "ftp://93.184.216.34/hooks", want: ErrInvalidURL},
+		{url: "/hooks", want: ErrInvalidURL},
+		{url: "https://", want: ErrInvalidURL},
+		{url: "http://%zz", want: ErrInvalidURL},
+	}
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			err := CheckURL(context.Background(), tt.url)
+			if !errors.Is(err, tt.want) || (tt.want == nil && err != nil) {
+				t.Errorf("CheckURL(%q) = %v, want %v", tt.url, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestDialControl(t *testing.T) {
+	tests := []struct {
+		address string
+		wantErr bool
+	}{
+		{address: "93.184.216.34:443"},
+		{address: "127.0.0.1:5432", wantErr: true},
+		{address: "169.254.169.254:80", wantErr: true},
+		{address: "[fd00::1]:80", wantErr: true},
+		{address: "localhost:80", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.address, func(t *testing.T) {
+			err := dialControl("tcp", tt.address, nil)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("dialControl(%s) = %v, wantErr %v", tt.address, err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,193 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/noctusha/tender/connection"
+	"github.com/noctusha/tender/internal/worker"
+	"github.com/noctusha/tender/models"
+)
+
+const (
+	defaultMaxAttempts  = 8
+	defaultPollInterval = 5 * time.Second
+	baseRetryDelay      = 30 * time.Second
+	maxRetryDelay       = 6 * time.Hour
+	claimBatch          = 20
+	claimLease          = 2 * time.Minute
+	requestTimeout      = 10 * time.Second
+)
+
+var retryBackoff = worker.Backoff{Base: baseRetryDelay, Max: maxRetryDelay}
+
+// Dispatcher is the outbox sink queueing events for the subscribed organizations. It posts them
+// in the background, retrying failed deliveries with exponential backoff.
+type Dispatcher struct {
+	repo   *connection.Repository
+	client *http.Client
+	loop   *worker.Loop
+
+	maxAttempts int
+}
+
+// NewDispatcher reads WEBHOOK_MAX_ATTEMPTS and WEBHOOK_POLL_INTERVAL (a Go duration such as "5s").
+func NewDispatcher(repo *connection.Repository) *Dispatcher {
+	maxAttempts := defaultMaxAttempts
+	if v, err := strconv.Atoi(os.Getenv("WEBHOOK_MAX_ATTEMPTS")); err == nil && v > 0 {
+		maxAttempts = v
+	}
+
+	pollInterval := defaultPollInterval
+	if v, err := time.ParseDuration(os.Getenv("WEBHOOK_POLL_INTERVAL")); err == nil && v > 0 {
+		pollInterval = v
+	}
+
+	return &Dispatcher{
+		repo: repo,
+		client: &http.Client{
+			Timeout: requestTimeout,
+			// Subscribers are called directly, never through a proxy, and only at public addresses.
+			Transport: &http.Transport{
+				DialContext:         (&net.Dialer{Timeout: requestTimeout, Control: dialControl}).DialContext,
+				TLSHandshakeTimeout: requestTimeout,
+				MaxIdleConnsPerHost: 4,
+				IdleConnTimeout:     90 * time.Second,
+			},
+			// A subscriber is called at the URL it registered, redirects are reported as failures.
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		loop:        worker.NewLoop(pollInterval),
+		maxAttempts: maxAttempts,
+	}
+}
+
+func (d *Dispatcher) Name() string {
+	return "webhook"
+}
+
+// Publish queues the event for the webhooks of the organizations it concerns. An event
+// offered again by the outbox is only queued once per subscription.
+func (d *Dispatcher) Publish(_ context.Context, event models.Event) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to encode event: %w", err)
+	}
+
+	n, err := d.repo.EnqueueWebhookDeliveries(event.ID, event.Type, payload, event.OrganizationIDs)
+	if err != nil {
+		return err
+	}
+
+	if n > 0 {
+		d.Wake()
+	}
+	return nil
+}
+
+// Wake makes the dispatcher look for due deliveries without waiting for the next poll.
+func (d *Dispatcher) Wake() {
+	d.loop.Wake()
+}
+
+// Run sends due deliveries until the context is cancelled.
+func (d *Dispatcher) Run(ctx context.Context) {
+	d.loop.Run(ctx, d.dispatchDue)
+}
+
+func (d *Dispatcher) dispatchDue(ctx context.Context) {
+	for {
+		dispatches, err := d.repo.ClaimWebhookDeliveries(claimBatch, claimLease)
+		if err != nil {
+			slog.Error("failed to claim webhook deliveries", "error", err)
+			return
+		}
+
+		for _, dispatch := range dispatches {
+			if ctx.Err() != nil {
+				return
+			}
+			d.deliver(ctx, dispatch)
+		}
+
+		if len(dispatches) < claimBatch {
+			return
+		}
+	}
+}
+
+func (d *Dispatcher) deliver(ctx context.Context, dispatch connection.WebhookDispatch) {
+	delivery := dispatch.Delivery
+
+	statusCode, err := d.post(ctx, dispatch)
+	if err == nil {
+		err = d.repo.MarkWebhookDelivered(delivery.ID, statusCode)
+		if err != nil {
+			slog.Error("failed to mark webhook delivered", "delivery_id", delivery.ID, "error", err)
+		}
+		return
+	}
+
+	var retryAt *time.Time
+	if attempt := delivery.Attempts + 1; attempt < d.maxAttempts {
+		t := time.Now().Add(retryBackoff.Delay(attempt))
+		retryAt = &t
+	}
+
+	err = d.repo.MarkWebhookAttemptFailed(delivery.ID, statusCode, err.Error(), retryAt)
+	if err != nil {
+		slog.Error("failed to record failed webhook attempt", "delivery_id", delivery.ID, "error", err)
+	}
+}
+
+func (d *Dispatcher) post(ctx context.Context, dispatch connection.WebhookDispatch) (int, error) {
+	delivery := dispatch.Delivery
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, dispatch.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "tender-webhooks/1.0")
+	req.Header.Set("X-Webhook-Id", delivery.ID)
+	req.Header.Set("X-Webhook-Event", delivery.EventType)
+	req.Header.Set("X-Webhook-Timestamp", timestamp)
+	req.Header.Set("X-Webhook-Signature", "sha256="+Sign(dispatch.Secret, timestamp, delivery.Payload))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("unexpected response status: %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+// Sign computes the hex HMAC-SHA256 of "<timestamp>.<payload>" with the subscription secret.
+// Subscribers recompute it to check that a request comes from this service and was not altered.
+func Sign(secret string, timestamp string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,37 @@
+package webhooks
+
+import "testing"
+
+func TestSign(t *testing.T) {
+	// The expected signatures were computed with openssl dgst -sha256 -hmac secret.
+	tests := []struct {
+		name      string
+		secret    string
+		timestamp string
+		payload   string
+		want      string
+	}{
+		{
+			name:      "event",
+			secret:    "secret",
+			timestamp: "1700000000",
+			payload:   `{"type":"tender.published"}`,
+			want:      "c6550c303836da4e4e48ce3d4df5982266a4f86691ad6ab1b3ba4be2369f53c0",
+		},
+		{
+			name:      "empty payload",
+			secret:    "secret",
+			timestamp: "1700000000",
+			payload:   "",
+			want:      "4bc5f74d868b97888288889c5d9d65df02526f94c1592a79fdf4fe8b26e311e5",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Sign(tt.secret, tt.timestamp, []byte(tt.payload))
+			if got != tt.want {
+				t.Errorf("Sign = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}